@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
+	"main/internal/loggers"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type HealthHandler struct {
@@ -35,27 +39,81 @@ func (h *HealthHandler) Status(c *fiber.Ctx) error {
 	})
 }
 
-// HandleWebSocket handles WebSocket connections
-func HandleWebSocket(c *fiber.Ctx) error {
-	// Check if the connection is WebSocket
-	if websocket.IsWebSocketUpgrade(c) {
+// logStreamWriteTimeout bounds sending one message to a log stream client
+const logStreamWriteTimeout = 10 * time.Second
+
+// logStreamPingInterval keeps idle log stream connections from being closed by proxies
+const logStreamPingInterval = 30 * time.Second
+
+// HandleLogStream upgrades to a WebSocket that receives log entries as JSON
+// messages as they are written. ?level= keeps entries at or above that level
+// and ?contains= those whose encoded entry contains the substring. A client
+// that falls behind is disconnected with a 1013 (try again later) close.
+func HandleLogStream(stream *loggers.Broadcaster) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.NewError(fiber.StatusUpgradeRequired, "WebSocket upgrade required")
+		}
+
+		minLevel := zapcore.DebugLevel
+		if value := c.Query("level"); value != "" {
+			if err := minLevel.UnmarshalText([]byte(value)); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "level must be debug, info, warn or error")
+			}
+		}
+		contains := c.Query("contains")
+
 		return websocket.New(func(ws *websocket.Conn) {
-			for {
-				mt, msg, err := ws.ReadMessage()
-				if err != nil {
-					if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			subscription := stream.Subscribe()
+			defer stream.Unsubscribe(subscription)
+
+			// Nothing is expected from the client; reading notices when it goes away
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				for {
+					if _, _, err := ws.ReadMessage(); err != nil {
 						return
 					}
-					return
 				}
+			}()
+
+			ping := time.NewTicker(logStreamPingInterval)
+			defer ping.Stop()
 
-				// Echo message back
-				if err := ws.WriteMessage(mt, msg); err != nil {
+			for {
+				select {
+				case entry, ok := <-subscription.Entries():
+					if !ok {
+						if subscription.Dropped() {
+							_ = ws.WriteControl(websocket.CloseMessage,
+								websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"),
+								time.Now().Add(logStreamWriteTimeout))
+						}
+						return
+					}
+
+					var level zapcore.Level
+					if err := level.UnmarshalText([]byte(entry.Level)); err == nil && level < minLevel {
+						continue
+					}
+					message, err := json.Marshal(entry)
+					if err != nil || (contains != "" && !strings.Contains(string(message), contains)) {
+						continue
+					}
+
+					_ = ws.SetWriteDeadline(time.Now().Add(logStreamWriteTimeout))
+					if err := ws.WriteMessage(websocket.TextMessage, message); err != nil {
+						return
+					}
+				case <-ping.C:
+					if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(logStreamWriteTimeout)); err != nil {
+						return
+					}
+				case <-closed:
 					return
 				}
 			}
 		})(c)
 	}
-
-	return fiber.NewError(fiber.StatusUpgradeRequired, "WebSocket upgrade required")
 }
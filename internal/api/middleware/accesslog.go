@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"fmt"
+	"main/internal/config"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// accessLogFields are the fields the access log can emit. The request ID is
+// always present through the request logger.
+var accessLogFields = []string{
+	"method", "route", "path", "status", "ip", "user_id",
+	"bytes_in", "bytes_out", "latency_ms", "upstream", "upstream_latency_ms",
+}
+
+// SetUpstreamDuration records how long the upstream call took, for the access log
+func SetUpstreamDuration(c *fiber.Ctx, d time.Duration) {
+	c.Locals("upstream_duration", d)
+}
+
+// AccessLogFiber logs one line per request once the handler chain has finished.
+// Errors are rendered here so the logged status is the one the client gets.
+// Server errors and requests slower than cfg.SlowRequestThreshold are always
+// logged; other requests to cfg.AccessLogSamplePaths are logged at
+// cfg.AccessLogSampleRate and the rest at cfg.AccessLogSuccessSampleRate.
+func AccessLogFiber(cfg config.LoggingConfig, log *zap.Logger) (fiber.Handler, error) {
+	level, err := zapcore.ParseLevel(cfg.AccessLogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(accessLogFields))
+	if len(cfg.AccessLogFields) == 0 {
+		for _, field := range accessLogFields {
+			enabled[field] = true
+		}
+	}
+	for _, field := range cfg.AccessLogFields {
+		if !slices.Contains(accessLogFields, field) {
+			return nil, fmt.Errorf("unknown access log field %q", field)
+		}
+		enabled[field] = true
+	}
+
+	slow := cfg.SlowRequestThreshold
+
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		latency := time.Since(c.Context().Time())
+		if c.Response().StatusCode() < fiber.StatusInternalServerError && (slow <= 0 || latency < slow) {
+			rate := cfg.AccessLogSuccessSampleRate
+			if sampledPath(c.Path(), cfg.AccessLogSamplePaths) {
+				rate = cfg.AccessLogSampleRate
+			}
+			if rand.Float64() >= rate {
+				return nil
+			}
+		}
+
+		fields := make([]zap.Field, 0, len(enabled))
+		add := func(name string, field func() zap.Field) {
+			if enabled[name] {
+				fields = append(fields, field())
+			}
+		}
+		add("method", func() zap.Field { return zap.String("method", c.Method()) })
+		add("route", func() zap.Field { return zap.String("route", c.Route().Path) })
+		add("path", func() zap.Field { return zap.String("path", c.Path()) })
+		add("status", func() zap.Field { return zap.Int("status", c.Response().StatusCode()) })
+		add("ip", func() zap.Field { return zap.String("ip", ClientIP(c)) })
+		add("user_id", func() zap.Field { return zap.String("user_id", UserIDFromLocals(c)) })
+		add("bytes_in", func() zap.Field { return zap.Int("bytes_in", len(c.Request().Body())) })
+		add("bytes_out", func() zap.Field { return zap.Int("bytes_out", responseSize(c)) })
+		add("latency_ms", func() zap.Field {
+			return zap.Float64("latency_ms", float64(latency.Microseconds())/1000)
+		})
+		add("upstream", func() zap.Field {
+			service, _ := c.Locals("upstream_service").(string)
+			return zap.String("upstream", service)
+		})
+		add("upstream_latency_ms", func() zap.Field {
+			d, _ := c.Locals("upstream_duration").(time.Duration)
+			return zap.Float64("upstream_latency_ms", float64(d.Microseconds())/1000)
+		})
+
+		if ce := RequestLogger(c, log).Check(level, "Request completed"); ce != nil {
+			ce.Write(fields...)
+		}
+		return nil
+	}, nil
+}
+
+// responseSize is the response body length; streamed bodies report their
+// Content-Length, or -1 when it is unknown
+func responseSize(c *fiber.Ctx) int {
+	if c.Response().IsBodyStream() {
+		return c.Response().Header.ContentLength()
+	}
+	return len(c.Response().Body())
+}
+
+func sampledPath(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"main/internal/config"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newAccessLogApp(t *testing.T, cfg config.LoggingConfig) (*fiber.App, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(zap.DebugLevel)
+	handler, err := AccessLogFiber(cfg, zap.New(core))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Immutable so the paths the observer keeps are not reused buffers
+	app := fiber.New(fiber.Config{Immutable: true})
+	app.Use(handler)
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/fail", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusBadGateway, "upstream down")
+	})
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		time.Sleep(20 * time.Millisecond)
+		return c.SendString("ok")
+	})
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	return app, logs
+}
+
+func TestAccessLogSamplesSuccessfulRequests(t *testing.T) {
+	app, logs := newAccessLogApp(t, config.LoggingConfig{
+		AccessLogLevel:             "info",
+		AccessLogSamplePaths:       []string{"/health"},
+		AccessLogSampleRate:        1,
+		AccessLogSuccessSampleRate: 0,
+		SlowRequestThreshold:       10 * time.Millisecond,
+	})
+
+	for _, path := range []string{"/ok", "/ok", "/fail", "/slow", "/health"} {
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fast successes are dropped at rate 0; the error, the slow request and
+	// the sample path, which has its own rate, are logged
+	var logged []string
+	for _, entry := range logs.All() {
+		logged = append(logged, entry.ContextMap()["path"].(string))
+	}
+	want := []string{"/fail", "/slow", "/health"}
+	if len(logged) != len(want) {
+		t.Fatalf("logged %v, want %v", logged, want)
+	}
+	for i := range want {
+		if logged[i] != want[i] {
+			t.Fatalf("logged %v, want %v", logged, want)
+		}
+	}
+	if status := logs.All()[0].ContextMap()["status"]; status != int64(fiber.StatusBadGateway) {
+		t.Errorf("logged status %v, want 502", status)
+	}
+}
+
+func TestAccessLogFullRateLogsEverything(t *testing.T) {
+	app, logs := newAccessLogApp(t, config.LoggingConfig{
+		AccessLogLevel:             "info",
+		AccessLogSuccessSampleRate: 1,
+	})
+
+	for range 3 {
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/ok", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := logs.Len(); n != 3 {
+		t.Fatalf("%d access log lines, want 3", n)
+	}
+}
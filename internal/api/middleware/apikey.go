@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"main/internal/auth"
+	"main/internal/config"
+	"main/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// APIKeyHeader carries the static key of service-to-service callers
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyIdentityHeaders carry an API key's identity to the backend
+var apiKeyIdentityHeaders = []string{"X-User-ID", "X-Username", "X-User-Role", "X-User-Email"}
+
+// APIKeyFiber authenticates requests carrying an X-API-Key header and marks them
+// so the JWT middleware can be skipped. Requests without the header, or on
+// public routes, pass through.
+func APIKeyFiber(validator *auth.APIKeyValidator, log *zap.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(APIKeyHeader)
+		if key == "" || RouteAuth(c) == config.RouteAuthPublic {
+			return c.Next()
+		}
+
+		identity, err := validator.ValidateKey(key)
+		if err != nil {
+			return NewError(fiber.StatusUnauthorized, models.ErrCodeAuthInvalidAPIKey, "invalid API key")
+		}
+
+		// Populate the same headers downstream services get for JWT users
+		c.Request().Header.Set("X-User-ID", identity.ClientID)
+		c.Request().Header.Set("X-Username", identity.ClientID)
+		c.Request().Header.Set("X-User-Role", identity.Role)
+		c.Request().Header.Del("X-User-Email")
+
+		c.Locals("api_client", identity)
+
+		RequestLogger(c, log).Debug("API key validated",
+			zap.String("client_id", identity.ClientID),
+			zap.String("role", identity.Role),
+		)
+
+		return c.Next()
+	}
+}
+
+// HasAPIKeyIdentity reports whether the request was authenticated by API key
+func HasAPIKeyIdentity(c *fiber.Ctx) bool {
+	_, ok := c.Locals("api_client").(*auth.APIKeyIdentity)
+	return ok
+}
+
+// RequireAPIKeyRole rejects requests not authenticated by an API key with the given role.
+// It must run after APIKeyFiber.
+func RequireAPIKeyRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		identity, ok := c.Locals("api_client").(*auth.APIKeyIdentity)
+		if !ok {
+			return NewError(fiber.StatusUnauthorized, models.ErrCodeUnauthorized, "unauthorized")
+		}
+		if identity.Role != role {
+			return NewError(fiber.StatusForbidden, models.ErrCodeForbidden, "forbidden")
+		}
+		return c.Next()
+	}
+}
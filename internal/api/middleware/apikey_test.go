@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"encoding/json"
+	"main/internal/auth"
+	"main/internal/config"
+	"main/internal/models"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+func TestAPIKeyFiber(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.APIKeys.Keys = []config.APIKeyEntry{{Key: "key-billing", ClientID: "billing", Role: "service"}}
+	validator := auth.NewAPIKeyValidator(cfg, zap.NewNop())
+
+	tests := map[string]struct {
+		key      string
+		public   bool
+		status   int
+		identity bool
+		// wantHeaders are the identity headers the handler sees; the caller
+		// always sends spoofed ones
+		wantHeaders map[string]string
+	}{
+		"valid key": {key: "key-billing", status: 200, identity: true, wantHeaders: map[string]string{
+			"X-User-ID": "billing", "X-Username": "billing", "X-User-Role": "service", "X-User-Email": "",
+		}},
+		"invalid key":       {key: "key-other", status: 401},
+		"no key":            {status: 200},
+		"public route":      {key: "key-other", public: true, status: 200},
+		"valid key, public": {key: "key-billing", public: true, status: 200},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandlerFiber})
+			if tt.public {
+				app.Use(func(c *fiber.Ctx) error {
+					SetRoute(c, &config.RouteConfig{Auth: config.RouteAuthPublic}, nil)
+					return c.Next()
+				})
+			}
+			app.Use(APIKeyFiber(validator, zap.NewNop()))
+			var identity bool
+			seen := make(map[string]string)
+			app.Get("/", func(c *fiber.Ctx) error {
+				identity = HasAPIKeyIdentity(c)
+				for _, header := range apiKeyIdentityHeaders {
+					seen[header] = c.Get(header)
+				}
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			for _, header := range apiKeyIdentityHeaders {
+				req.Header.Set(header, "spoofed")
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == fiber.StatusUnauthorized {
+				var body models.ErrorResponse
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Code != models.ErrCodeAuthInvalidAPIKey {
+					t.Errorf("error %+v (%v), want %s", body, err, models.ErrCodeAuthInvalidAPIKey)
+				}
+				return
+			}
+			if identity != tt.identity {
+				t.Errorf("API key identity = %v, want %v", identity, tt.identity)
+			}
+			for header, want := range tt.wantHeaders {
+				if seen[header] != want {
+					t.Errorf("%s = %q, want %q", header, seen[header], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"errors"
+	"main/internal/audit"
+	"main/internal/auth"
+	"main/internal/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// auditEntry describes the request as an audit log entry
+func auditEntry(c *fiber.Ctx, event, level string, status int) models.LogEntry {
+	entry := models.LogEntry{
+		Timestamp: time.Now().UTC(),
+		Level:     level,
+		Message:   event,
+		Fields: map[string]any{
+			"request_id": RequestID(c),
+			"method":     c.Method(),
+			"path":       c.Path(),
+			"ip":         ClientIP(c),
+			"status":     status,
+		},
+	}
+	if identity, ok := c.Locals("api_client").(*auth.APIKeyIdentity); ok {
+		entry.AppID = identity.ClientID
+	}
+	if userID := UserIDFromLocals(c); userID != "" {
+		entry.Fields["user_id"] = userID
+	}
+	return entry
+}
+
+// AuditAuthFiber records the 401 and 403 errors returned by the authentication
+// middleware registered after it
+func AuditAuthFiber(log *audit.Log) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		var gatewayErr *Error
+		if errors.As(err, &gatewayErr) &&
+			(gatewayErr.Status == fiber.StatusUnauthorized || gatewayErr.Status == fiber.StatusForbidden) {
+			entry := auditEntry(c, audit.EventAuthFailure, "warn", gatewayErr.Status)
+			entry.Fields["reason"] = gatewayErr.Message
+			log.Record(entry)
+		}
+		return err
+	}
+}
+
+// AuditResponsesFiber records event for every response with at least
+// minStatus. Errors are rendered here so the recorded status is final.
+func AuditResponsesFiber(log *audit.Log, event string, minStatus int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		status := c.Response().StatusCode()
+		if status < minStatus {
+			return nil
+		}
+		level := "info"
+		if status >= fiber.StatusBadRequest {
+			level = "warn"
+		}
+		log.Record(auditEntry(c, event, level, status))
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"main/internal/config"
+	"main/internal/redact"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// BodyLoggerFiber logs request and response bodies of the configured path prefixes.
+// JSON bodies are logged with sensitive fields redacted, anything else is omitted.
+func BodyLoggerFiber(cfg config.LoggingConfig, log *zap.Logger) fiber.Handler {
+	fields := redact.FieldSet(cfg.BodyLogRedactFields)
+
+	return func(c *fiber.Ctx) error {
+		if !hasPathPrefix(c.Path(), cfg.BodyLogPaths) {
+			return c.Next()
+		}
+
+		requestBody := redact.Body(c.Body(), string(c.Request().Header.ContentType()), fields, cfg.BodyLogMaxBytes)
+
+		// Render errors here so the logged response is the one the client gets
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		// Reading a streamed body would buffer it, defeating the stream
+		responseBody := "[omitted: streamed body]"
+		if !c.Response().IsBodyStream() {
+			responseBody = redact.Body(c.Response().Body(), string(c.Response().Header.ContentType()), fields, cfg.BodyLogMaxBytes)
+		}
+
+		RequestLogger(c, log).Info("Request body logged",
+			zap.String("method", c.Method()),
+			zap.String("path", c.Path()),
+			zap.Int("status", c.Response().StatusCode()),
+			zap.String("request_body", requestBody),
+			zap.String("response_body", responseBody),
+		)
+
+		return nil
+	}
+}
+
+// hasPathPrefix reports whether path starts with any of the prefixes
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
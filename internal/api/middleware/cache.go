@@ -0,0 +1,520 @@
+package middleware
+
+import (
+	"context"
+	"main/internal/cache"
+	"main/internal/config"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+const (
+	CacheHeader = "X-Cache"
+	CacheHit    = "HIT"
+	CacheMiss   = "MISS"
+	CacheBypass = "BYPASS"
+	// CacheCoalesced marks a miss answered with a concurrent request's fetch
+	CacheCoalesced = "COALESCED"
+	// CacheRefresh marks a response refetched on an admin's X-Cache-Refresh request
+	CacheRefresh = "REFRESH"
+	// CacheHitNegative marks a cached 404 or 410
+	CacheHitNegative = "HIT-NEGATIVE"
+
+	// CacheRefreshHeader forces a full refetch; honoured for admin identities only
+	CacheRefreshHeader = "X-Cache-Refresh"
+)
+
+// uncachedHeaders are response headers that describe a single exchange and are never stored
+var uncachedHeaders = map[string]bool{
+	fiber.HeaderSetCookie:        true,
+	fiber.HeaderDate:             true,
+	fiber.HeaderConnection:       true,
+	fiber.HeaderTransferEncoding: true,
+	fiber.HeaderRetryAfter:       true,
+	fiber.HeaderAge:              true,
+	CacheHeader:                  true,
+	fiber.HeaderXRequestID:       true,
+}
+
+// CacheFiber serves GET/HEAD responses of the configured path prefixes from store.
+// Requests carrying a bearer token or API key bypass the cache unless their path
+// is listed in cfg.AuthPaths. Successful writes invalidate the affected entries.
+// Requests with Cache-Control or Pragma no-cache revalidate against the upstream,
+// and admins may force a full refetch with X-Cache-Refresh: true; both update
+// the cache with the fresh response. Bodies over cfg.MaxObjectBytes are never
+// cached; streamed bodies are copied into the cache as they are sent.
+func CacheFiber(store cache.Cache, cfg config.CacheConfig, log *zap.Logger) fiber.Handler {
+	// Concurrent misses for one key share a single upstream fetch
+	flights := newCoalescer()
+
+	return func(c *fiber.Ctx) error {
+		log := RequestLogger(c, log)
+		var stored *cache.Entry
+		var storedKey string
+		var finish func(*cache.Entry, string)
+
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			if err := c.Next(); err != nil {
+				return err
+			}
+			if isWrite(c.Method()) && c.Response().StatusCode() < fiber.StatusMultipleChoices {
+				invalidate(store, cfg, c.Path(), log)
+			}
+			return nil
+		}
+		path := c.Path()
+		if !hasPathPrefix(path, cfg.Paths) {
+			return c.Next()
+		}
+		if hasCredentials(c) && !hasPathPrefix(path, cfg.AuthPaths) {
+			cacheCounters.bypass.Add(1)
+			c.Set(CacheHeader, CacheBypass)
+			return c.Next()
+		}
+
+		baseKey := CacheKey(c, keyHeaders(path, cfg))
+		key := baseKey
+		ctx := context.Background()
+
+		// Other clients sending the refresh header are served normally
+		if refresh, _ := strconv.ParseBool(c.Get(CacheRefreshHeader)); refresh && RoleFromLocals(c) == "admin" {
+			cacheCounters.bypass.Add(1)
+			if err := c.Next(); err != nil {
+				return err
+			}
+			c.Set(CacheHeader, CacheRefresh)
+			storeResponse(ctx, c, store, baseKey, cfg, log, nil)
+			return nil
+		}
+		noCache := requestNoCache(c)
+
+		// Lookups are bounded by the request's deadline; writes are not
+		entry, found, err := store.Get(c.UserContext(), key)
+		if found && len(entry.Variants) > 0 {
+			// The response varies on request headers: look up this request's variant
+			key = variantKey(c, baseKey, entry.Variants)
+			entry, found, err = store.Get(c.UserContext(), key)
+		}
+		if err != nil {
+			log.Warn("Cache lookup failed", zap.String("key", key), zap.Error(err))
+		}
+		if found && negativeStatus(entry.StatusCode) && !negativeCacheable(c, cfg) {
+			// Cached 404s are not shared with requests that can't have stored them
+			found = false
+		}
+		if found && !entry.Revalidate && !noCache {
+			if negativeStatus(entry.StatusCode) {
+				cacheCounters.negative.Add(1)
+			} else {
+				cacheCounters.hits.Add(1)
+			}
+			return serveEntry(c, entry)
+		}
+
+		if found {
+			// no-cache entries are only served after the upstream confirms them
+			if entry.Revalidate {
+				cacheCounters.stale.Add(1)
+			}
+			var headers fasthttp.ResponseHeader
+			c.Response().Header.CopyTo(&headers)
+
+			restore := setValidators(c, entry)
+			err := c.Next()
+			restore()
+			if err != nil {
+				return err
+			}
+			if c.Response().StatusCode() == fiber.StatusNotModified {
+				headers.CopyTo(&c.Response().Header)
+				if noCache {
+					cacheCounters.bypass.Add(1)
+					err := serveEntry(c, entry)
+					c.Set(CacheHeader, CacheBypass)
+					return err
+				}
+				cacheCounters.hits.Add(1)
+				return serveEntry(c, entry)
+			}
+		} else {
+			// no-cache requests must reach the upstream themselves
+			if !noCache {
+				call, leader := flights.join(key)
+				if leader {
+					// Waiters get whatever the leader stored, or fetch for themselves
+					finish = func(entry *cache.Entry, storedKey string) { flights.finish(key, call, entry, storedKey) }
+					defer func() {
+						if finish != nil {
+							finish(stored, storedKey)
+						}
+					}()
+				} else if shared, sharedKey := call.wait(cfg.CoalesceTimeout); shared != nil &&
+					entryKey(c, baseKey, shared) == sharedKey {
+					// Only shared when the leader's response is the variant this request selects
+					cacheCounters.coalesced.Add(1)
+					err := serveEntry(c, shared)
+					c.Set(CacheHeader, CacheCoalesced)
+					return err
+				}
+			}
+			if err := c.Next(); err != nil {
+				return err
+			}
+		}
+		if noCache {
+			cacheCounters.bypass.Add(1)
+			c.Set(CacheHeader, CacheBypass)
+		} else {
+			cacheCounters.misses.Add(1)
+			c.Set(CacheHeader, CacheMiss)
+		}
+
+		var streaming bool
+		stored, storedKey, streaming = storeResponse(ctx, c, store, baseKey, cfg, log, finish)
+		if streaming {
+			// The leader finishes once the body has been streamed
+			finish = nil
+		}
+		return nil
+	}
+}
+
+// storeResponse caches the current response if it may be cached and returns the
+// stored entry and the key it was stored under. A streamed body is stored once
+// it has been sent in full; streaming is then true and streamed is called with
+// the result instead.
+func storeResponse(ctx context.Context, c *fiber.Ctx, store cache.Cache, baseKey string, cfg config.CacheConfig, log *zap.Logger,
+	streamed func(*cache.Entry, string)) (entry *cache.Entry, key string, streaming bool) {
+	pending := pendingEntry(c, baseKey, cfg)
+	if pending == nil {
+		return nil, "", false
+	}
+
+	if c.Response().IsBodyStream() {
+		tee := &cacheTee{limit: cfg.MaxObjectBytes, done: func(body []byte) {
+			var entry *cache.Entry
+			var key string
+			if body != nil {
+				entry, key = pending.save(ctx, store, body, log)
+			}
+			if streamed != nil {
+				streamed(entry, key)
+			}
+		}}
+		tee.body = c.Response().BodyStream()
+		c.Response().SetBodyStream(tee, c.Response().Header.ContentLength())
+		return nil, "", true
+	}
+
+	body := c.Response().Body()
+	if len(body) > cfg.MaxObjectBytes {
+		return nil, "", false
+	}
+	entry, key = pending.save(ctx, store, append([]byte(nil), body...), log)
+	return entry, key, false
+}
+
+// cacheWrite is a cacheable response waiting for its body
+type cacheWrite struct {
+	entry   *cache.Entry
+	baseKey string
+	key     string
+	vary    []string
+	ttl     time.Duration
+}
+
+// pendingEntry prepares the current response for caching, or returns nil when it
+// may not be cached. Keys are derived now, as the request is gone once a streamed
+// body completes.
+func pendingEntry(c *fiber.Ctx, baseKey string, cfg config.CacheConfig) *cacheWrite {
+	path := c.Path()
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusInternalServerError || status == fiber.StatusNotModified {
+		return nil
+	}
+	negative := negativeStatus(status)
+	if negative && !negativeCacheable(c, cfg) {
+		return nil
+	}
+	// A declared length over the cap skips the copy altogether
+	if c.Response().Header.ContentLength() > cfg.MaxObjectBytes {
+		return nil
+	}
+
+	directives := parseCacheControl(string(c.Response().Header.Peek(fiber.HeaderCacheControl)))
+	if !directives.Storable() {
+		return nil
+	}
+
+	vary := parseVary(string(c.Response().Header.Peek(fiber.HeaderVary)))
+	if slices.Contains(vary, "*") {
+		return nil
+	}
+
+	ttl := directives.TTL(cacheTTL(path, cfg))
+	revalidate := directives.NoCache || ttl == 0
+	if revalidate {
+		// Entries that need revalidation are kept for the configured TTL,
+		// and only if they carry a validator to revalidate with
+		ttl = cacheTTL(path, cfg)
+		if len(c.Response().Header.Peek(fiber.HeaderETag)) == 0 &&
+			len(c.Response().Header.Peek(fiber.HeaderLastModified)) == 0 {
+			return nil
+		}
+	}
+	if negative {
+		// Missing resources are remembered briefly, so one that is created shows up soon
+		ttl, revalidate = min(ttl, cfg.NegativeTTL), false
+	}
+
+	entry := &cache.Entry{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Revalidate: revalidate,
+	}
+	c.Response().Header.VisitAll(func(k, v []byte) {
+		name := http.CanonicalHeaderKey(string(k))
+		if uncachedHeaders[name] || strings.HasPrefix(name, "X-Ratelimit-") {
+			return
+		}
+		entry.Header.Add(name, string(v))
+	})
+
+	write := &cacheWrite{entry: entry, baseKey: baseKey, key: baseKey, vary: vary, ttl: ttl}
+	if len(vary) > 0 {
+		write.key = variantKey(c, baseKey, vary)
+	}
+	return write
+}
+
+// save stores the entry with body and returns it with the key it was stored under
+func (w *cacheWrite) save(ctx context.Context, store cache.Cache, body []byte, log *zap.Logger) (*cache.Entry, string) {
+	w.entry.Body = body
+	w.entry.StoredAt = time.Now()
+
+	if len(w.vary) > 0 {
+		// The base key records which headers select the variant
+		variants := &cache.Entry{StatusCode: w.entry.StatusCode, StoredAt: w.entry.StoredAt, Variants: w.vary}
+		if err := store.Set(ctx, w.baseKey, variants, w.ttl); err != nil {
+			log.Warn("Cache store failed", zap.String("key", w.baseKey), zap.Error(err))
+		}
+	}
+
+	// Entries are never served stale, so must-revalidate needs no extra handling
+	if err := store.Set(ctx, w.key, w.entry, w.ttl); err != nil {
+		log.Warn("Cache store failed", zap.String("key", w.key), zap.Error(err))
+	}
+	return w.entry, w.key
+}
+
+// negativeStatus reports whether status is cached as a negative entry
+func negativeStatus(status int) bool {
+	return status == fiber.StatusNotFound || status == fiber.StatusGone
+}
+
+// negativeCacheable reports whether a 404 or 410 may be cached for this request.
+// Requests with credentials may address per-user resources, so they need a
+// route listed in cfg.AuthPaths.
+func negativeCacheable(c *fiber.Ctx, cfg config.CacheConfig) bool {
+	if !cfg.NegativeEnabled || cfg.NegativeTTL <= 0 {
+		return false
+	}
+	if hasCredentials(c) {
+		return hasPathPrefix(c.Path(), cfg.AuthPaths)
+	}
+	return true
+}
+
+// hasCredentials reports whether the request carries a bearer token or API key
+func hasCredentials(c *fiber.Ctx) bool {
+	return c.Get(fiber.HeaderAuthorization) != "" || c.Get(APIKeyHeader) != ""
+}
+
+// requestNoCache reports whether the client asked for a response validated by the upstream
+func requestNoCache(c *fiber.Ctx) bool {
+	if parseCacheControl(c.Get(fiber.HeaderCacheControl)).NoCache {
+		return true
+	}
+	return strings.Contains(strings.ToLower(c.Get(fiber.HeaderPragma)), "no-cache")
+}
+
+// keyHeaders returns the request headers every cache key of path includes:
+// Accept-Encoding, the configured key headers and those of the longest matching route
+func keyHeaders(path string, cfg config.CacheConfig) []string {
+	headers := append([]string{fiber.HeaderAcceptEncoding}, cfg.KeyHeaders...)
+
+	var route []string
+	matched := -1
+	for prefix, names := range cfg.RouteKeyHeaders {
+		if strings.HasPrefix(path, prefix) && len(prefix) > matched {
+			route, matched = names, len(prefix)
+		}
+	}
+	return append(headers, route...)
+}
+
+// parseVary returns the canonical header names listed in a Vary header
+func parseVary(header string) []string {
+	var names []string
+	for _, name := range strings.Split(header, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	return names
+}
+
+// variantKey extends baseKey with the request's values of the Vary headers
+func variantKey(c *fiber.Ctx, baseKey string, vary []string) string {
+	var b strings.Builder
+	b.WriteString(baseKey)
+	b.WriteString("|vary")
+	for _, name := range vary {
+		b.WriteByte('|')
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(c.Get(name))
+	}
+	return b.String()
+}
+
+// entryKey returns the key entry is stored under for this request
+func entryKey(c *fiber.Ctx, baseKey string, entry *cache.Entry) string {
+	if vary := parseVary(entry.Header.Get(fiber.HeaderVary)); len(vary) > 0 {
+		return variantKey(c, baseKey, vary)
+	}
+	return baseKey
+}
+
+// serveEntry writes a cached response
+func serveEntry(c *fiber.Ctx, entry *cache.Entry) error {
+	for name, values := range entry.Header {
+		for _, value := range values {
+			c.Response().Header.Add(name, value)
+		}
+	}
+	if negativeStatus(entry.StatusCode) {
+		c.Set(CacheHeader, CacheHitNegative)
+	} else {
+		c.Set(CacheHeader, CacheHit)
+	}
+	c.Set(fiber.HeaderAge, strconv.Itoa(int(entry.Age().Seconds())))
+	c.Status(entry.StatusCode)
+	return c.Send(entry.Body)
+}
+
+// setValidators makes the request conditional on the cached entry and returns a
+// function restoring the client's own conditional headers
+func setValidators(c *fiber.Ctx, entry *cache.Entry) func() {
+	ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch)
+	ifModifiedSince := c.Get(fiber.HeaderIfModifiedSince)
+
+	c.Request().Header.Del(fiber.HeaderIfNoneMatch)
+	c.Request().Header.Del(fiber.HeaderIfModifiedSince)
+	if etag := entry.Header.Get(fiber.HeaderETag); etag != "" {
+		c.Request().Header.Set(fiber.HeaderIfNoneMatch, etag)
+	} else {
+		c.Request().Header.Set(fiber.HeaderIfModifiedSince, entry.Header.Get(fiber.HeaderLastModified))
+	}
+
+	return func() {
+		c.Request().Header.Del(fiber.HeaderIfNoneMatch)
+		c.Request().Header.Del(fiber.HeaderIfModifiedSince)
+		if ifNoneMatch != "" {
+			c.Request().Header.Set(fiber.HeaderIfNoneMatch, ifNoneMatch)
+		}
+		if ifModifiedSince != "" {
+			c.Request().Header.Set(fiber.HeaderIfModifiedSince, ifModifiedSince)
+		}
+	}
+}
+
+// PurgePath removes the cached GET and HEAD responses of every path under prefix
+func PurgePath(ctx context.Context, store cache.Cache, prefix string) (int, error) {
+	total := 0
+	for _, method := range []string{fiber.MethodGet, fiber.MethodHead} {
+		removed, err := store.DeletePrefix(ctx, method+" "+prefix)
+		if err != nil {
+			return total, err
+		}
+		total += removed
+	}
+	return total, nil
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// invalidate purges the prefixes mapped to the longest matching write prefix in
+// cfg.Invalidations, or else the longest cached path prefix containing path
+func invalidate(store cache.Cache, cfg config.CacheConfig, path string, log *zap.Logger) {
+	var targets []string
+	matched := -1
+	for prefix, mapped := range cfg.Invalidations {
+		if strings.HasPrefix(path, prefix) && len(prefix) > matched {
+			targets, matched = mapped, len(prefix)
+		}
+	}
+	if targets == nil {
+		for _, prefix := range cfg.Paths {
+			if strings.HasPrefix(path, prefix) && len(prefix) > matched {
+				targets, matched = []string{prefix}, len(prefix)
+			}
+		}
+	}
+
+	for _, target := range targets {
+		removed, err := PurgePath(context.Background(), store, target)
+		if err != nil {
+			log.Warn("Cache invalidation failed", zap.String("prefix", target), zap.Error(err))
+			continue
+		}
+		log.Debug("Cache invalidated",
+			zap.String("path", path),
+			zap.String("prefix", target),
+			zap.Int("removed", removed),
+		)
+	}
+}
+
+// CacheKey builds the cache key from method, path, query and the given request headers
+func CacheKey(c *fiber.Ctx, headers []string) string {
+	var b strings.Builder
+	b.WriteString(c.Method())
+	b.WriteByte(' ')
+	b.WriteString(c.Path())
+	if query := c.Request().URI().QueryString(); len(query) > 0 {
+		b.WriteByte('?')
+		b.Write(query)
+	}
+	for _, header := range headers {
+		b.WriteByte('|')
+		b.WriteString(header)
+		b.WriteByte('=')
+		b.WriteString(c.Get(header))
+	}
+	return b.String()
+}
+
+// cacheTTL returns the TTL of the longest matching path override, or the default TTL
+func cacheTTL(path string, cfg config.CacheConfig) time.Duration {
+	ttl, matched := cfg.TTL, 0
+	for prefix, override := range cfg.PathTTLs {
+		if strings.HasPrefix(path, prefix) && len(prefix) > matched {
+			ttl, matched = override, len(prefix)
+		}
+	}
+	return ttl
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"io"
+	"main/internal/cache"
+	"main/internal/config"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+func newCachedApp(cfg config.CacheConfig, calls *int) *fiber.App {
+	app := fiber.New()
+	app.Use(CacheFiber(cache.NewMemoryCache(100), cfg, zap.NewNop()))
+	app.Get("/catalog/items", func(c *fiber.Ctx) error {
+		*calls++
+		return c.SendString("items for " + c.Get(APIKeyHeader))
+	})
+	return app
+}
+
+func TestCacheBypassesCredentials(t *testing.T) {
+	cfg := config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 100, MaxObjectBytes: 1 << 20, Paths: []string{"/catalog"}}
+
+	for _, header := range []string{fiber.HeaderAuthorization, APIKeyHeader} {
+		t.Run(header, func(t *testing.T) {
+			calls := 0
+			app := newCachedApp(cfg, &calls)
+			for _, value := range []string{"tenant-a", "tenant-b"} {
+				req := httptest.NewRequest(fiber.MethodGet, "/catalog/items", nil)
+				req.Header.Set(header, value)
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := resp.Header.Get(CacheHeader); got != CacheBypass {
+					t.Errorf("X-Cache = %q, want %s", got, CacheBypass)
+				}
+			}
+			if calls != 2 {
+				t.Fatalf("handler called %d times, want every credentialed request forwarded", calls)
+			}
+		})
+	}
+}
+
+func TestCacheServesAnonymousHits(t *testing.T) {
+	cfg := config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 100, MaxObjectBytes: 1 << 20, Paths: []string{"/catalog"}}
+	calls := 0
+	app := newCachedApp(cfg, &calls)
+
+	var statuses []string
+	for i := 0; i < 2; i++ {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/catalog/items", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses = append(statuses, resp.Header.Get(CacheHeader))
+	}
+	if calls != 1 || statuses[0] != CacheMiss || statuses[1] != CacheHit {
+		t.Fatalf("calls = %d, X-Cache = %v; want one call, then MISS and HIT", calls, statuses)
+	}
+}
+
+func TestCacheKeyHeaders(t *testing.T) {
+	cfg := config.CacheConfig{
+		Paths:           []string{"/catalog"},
+		KeyHeaders:      []string{"X-Region"},
+		RouteKeyHeaders: map[string][]string{"/catalog": {"X-Tier"}, "/catalog/items": {"X-Currency"}},
+	}
+	app := fiber.New()
+	var key string
+	app.Get("/*", func(c *fiber.Ctx) error {
+		key = CacheKey(c, keyHeaders(c.Path(), cfg))
+		return nil
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/catalog/items?page=2", nil)
+	req.Header.Set(fiber.HeaderAcceptEncoding, "gzip")
+	req.Header.Set("X-Region", "eu")
+	req.Header.Set("X-Tier", "gold")
+	req.Header.Set("X-Currency", "EUR")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	// The longest route's headers apply, not every matching route's
+	want := "GET /catalog/items?page=2|Accept-Encoding=gzip|X-Region=eu|X-Currency=EUR"
+	if key != want {
+		t.Fatalf("key = %q, want %q", key, want)
+	}
+}
+
+func TestCacheStoresVariants(t *testing.T) {
+	cfg := config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 100, MaxObjectBytes: 1 << 20, Paths: []string{"/catalog"}}
+	calls := 0
+	app := fiber.New()
+	app.Use(CacheFiber(cache.NewMemoryCache(100), cfg, zap.NewNop()))
+	app.Get("/catalog/items", func(c *fiber.Ctx) error {
+		calls++
+		c.Set(fiber.HeaderVary, "Accept-Language")
+		return c.SendString("items in " + c.Get(fiber.HeaderAcceptLanguage))
+	})
+
+	get := func(language, encoding string) (string, string) {
+		req := httptest.NewRequest(fiber.MethodGet, "/catalog/items", nil)
+		req.Header.Set(fiber.HeaderAcceptLanguage, language)
+		req.Header.Set(fiber.HeaderAcceptEncoding, encoding)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.Header.Get(CacheHeader), string(body)
+	}
+
+	steps := []struct {
+		language, encoding, status, body string
+	}{
+		{"en", "", CacheMiss, "items in en"},
+		{"fr", "", CacheMiss, "items in fr"},
+		{"en", "", CacheHit, "items in en"},
+		{"fr", "", CacheHit, "items in fr"},
+		// Accept-Encoding is always part of the key
+		{"en", "gzip", CacheMiss, "items in en"},
+	}
+	for i, step := range steps {
+		status, body := get(step.language, step.encoding)
+		if status != step.status || body != step.body {
+			t.Fatalf("step %d: %s %q, want %s %q", i, status, body, step.status, step.body)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("handler called %d times, want 3", calls)
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheDirectives holds the Cache-Control response directives the gateway cache honours.
+// MaxAge and SMaxAge are -1 when absent.
+type cacheDirectives struct {
+	NoStore        bool
+	NoCache        bool
+	Private        bool
+	MustRevalidate bool
+	MaxAge         time.Duration
+	SMaxAge        time.Duration
+}
+
+func parseCacheControl(header string) cacheDirectives {
+	d := cacheDirectives{MaxAge: -1, SMaxAge: -1}
+
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		value = strings.Trim(value, `"`)
+
+		switch strings.ToLower(name) {
+		case "no-store":
+			d.NoStore = true
+		case "no-cache":
+			d.NoCache = true
+		case "private":
+			d.Private = true
+		case "must-revalidate", "proxy-revalidate":
+			d.MustRevalidate = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+				d.MaxAge = time.Duration(seconds) * time.Second
+			}
+		case "s-maxage":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+				d.SMaxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return d
+}
+
+// Storable reports whether a shared cache may keep the response at all
+func (d cacheDirectives) Storable() bool {
+	return !d.NoStore && !d.Private
+}
+
+// TTL returns the freshness lifetime: s-maxage wins over max-age, which wins
+// over the configured default
+func (d cacheDirectives) TTL(defaultTTL time.Duration) time.Duration {
+	if d.SMaxAge >= 0 {
+		return d.SMaxAge
+	}
+	if d.MaxAge >= 0 {
+		return d.MaxAge
+	}
+	return defaultTTL
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"main/internal/cache"
+	"main/internal/config"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		header   string
+		storable bool
+		noCache  bool
+		ttl      time.Duration
+	}{
+		{"", true, false, time.Minute},
+		{"no-store", false, false, time.Minute},
+		{"private, max-age=30", false, false, 30 * time.Second},
+		{"public, max-age=30", true, false, 30 * time.Second},
+		{`max-age="30", s-maxage=90`, true, false, 90 * time.Second},
+		{"No-Cache", true, true, time.Minute},
+		{"max-age=-5", true, false, time.Minute},
+	}
+	for _, tt := range tests {
+		d := parseCacheControl(tt.header)
+		if d.Storable() != tt.storable || d.NoCache != tt.noCache || d.TTL(time.Minute) != tt.ttl {
+			t.Errorf("%q: storable %v, no-cache %v, ttl %v; want %v, %v, %v",
+				tt.header, d.Storable(), d.NoCache, d.TTL(time.Minute), tt.storable, tt.noCache, tt.ttl)
+		}
+	}
+}
+
+func TestCacheHonoursResponseCacheControl(t *testing.T) {
+	cfg := config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 100, MaxObjectBytes: 1 << 20, Paths: []string{"/catalog"}}
+
+	tests := []struct {
+		cacheControl string
+		etag         string
+		calls        int
+		second       string
+	}{
+		{"max-age=30", "", 1, CacheHit},
+		{"no-store", "", 2, CacheMiss},
+		{"private", "", 2, CacheMiss},
+		// Must revalidate, and can only do so with a validator
+		{"max-age=0", "", 2, CacheMiss},
+		{"no-cache", `"v1"`, 2, CacheHit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			calls := 0
+			app := fiber.New()
+			app.Use(CacheFiber(cache.NewMemoryCache(100), cfg, zap.NewNop()))
+			app.Get("/catalog/items", func(c *fiber.Ctx) error {
+				calls++
+				c.Set(fiber.HeaderCacheControl, tt.cacheControl)
+				if tt.etag != "" {
+					c.Set(fiber.HeaderETag, tt.etag)
+					if c.Get(fiber.HeaderIfNoneMatch) == tt.etag {
+						return c.SendStatus(fiber.StatusNotModified)
+					}
+				}
+				return c.SendString("items")
+			})
+
+			var status string
+			for range 2 {
+				resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/catalog/items", nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				status = resp.Header.Get(CacheHeader)
+			}
+			if calls != tt.calls || status != tt.second {
+				t.Fatalf("calls = %d, second X-Cache = %s; want %d and %s", calls, status, tt.calls, tt.second)
+			}
+		})
+	}
+}
+
+func TestCacheUsesSharedMaxAge(t *testing.T) {
+	cfg := config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 100, MaxObjectBytes: 1 << 20, Paths: []string{"/catalog"}}
+	store := cache.NewMemoryCache(100)
+	app := fiber.New()
+	app.Use(CacheFiber(store, cfg, zap.NewNop()))
+	app.Get("/catalog/items", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "max-age=10, s-maxage=600")
+		return c.SendString("items")
+	})
+
+	if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/catalog/items", nil)); err != nil {
+		t.Fatal(err)
+	}
+	entry, found, err := store.Get(context.Background(), "GET /catalog/items|Accept-Encoding=")
+	if err != nil || !found {
+		t.Fatalf("entry not stored: found %v, err %v", found, err)
+	}
+	if ttl := entry.ExpiresAt.Sub(entry.StoredAt); ttl < 599*time.Second || ttl > 601*time.Second {
+		t.Fatalf("stored for %v, want s-maxage", ttl)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"main/internal/cache"
+	"main/internal/models"
+	"sync/atomic"
+	"time"
+)
+
+// cacheCounters tracks lookup outcomes of CacheFiber
+var cacheCounters struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	stale     atomic.Int64
+	bypass    atomic.Int64
+	coalesced atomic.Int64
+	negative  atomic.Int64
+}
+
+// CacheStats combines the lookup counters with the backend's size and evictions.
+// Stale counts entries that needed revalidation upstream; those confirmed by a
+// 304 also count as hits, the others as misses.
+func CacheStats(ctx context.Context, store cache.Cache, backend string) (models.CacheStats, error) {
+	stats := models.CacheStats{
+		Backend:   backend,
+		Hits:      cacheCounters.hits.Load(),
+		Misses:    cacheCounters.misses.Load(),
+		Stale:     cacheCounters.stale.Load(),
+		Bypass:    cacheCounters.bypass.Load(),
+		Coalesced: cacheCounters.coalesced.Load(),
+		Negative:  cacheCounters.negative.Load(),
+	}
+	if lookups := stats.Hits + stats.Misses + stats.Coalesced + stats.Negative; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits+stats.Coalesced+stats.Negative) / float64(lookups)
+	}
+
+	backendStats, err := store.Stats(ctx)
+	stats.Entries = backendStats.Entries
+	stats.Bytes = backendStats.Bytes
+	stats.Evictions = backendStats.Evictions
+	return stats, err
+}
+
+// CacheKeys lists cached keys for the inspection endpoint
+func CacheKeys(ctx context.Context, store cache.Cache, prefix, cursor string, limit int) (models.CacheKeyList, error) {
+	keys, next, err := store.Keys(ctx, prefix, cursor, limit)
+	if err != nil {
+		return models.CacheKeyList{}, err
+	}
+
+	list := models.CacheKeyList{Keys: make([]models.CacheKeyInfo, len(keys)), NextCursor: next}
+	for i, key := range keys {
+		list.Keys[i] = models.CacheKeyInfo{
+			Key:        key.Key,
+			TTLSeconds: int64(key.TTL / time.Second),
+			SizeBytes:  key.Size,
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// cacheTee copies a streamed response body into memory as the client reads it.
+// Once the copy exceeds limit it is dropped and the body is passed through
+// untouched. done is called once: with the full body at EOF, or with nil when the
+// copy was abandoned or the stream closed early.
+type cacheTee struct {
+	body      io.Reader
+	buf       bytes.Buffer
+	limit     int
+	abandoned bool
+	done      func(body []byte)
+	once      sync.Once
+}
+
+func (t *cacheTee) Read(p []byte) (int, error) {
+	n, err := t.body.Read(p)
+	if !t.abandoned {
+		if t.buf.Len()+n > t.limit {
+			t.abandon()
+		} else {
+			t.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !t.abandoned {
+		t.once.Do(func() { t.done(t.buf.Bytes()) })
+	}
+	return n, err
+}
+
+// Close closes the underlying body, which fasthttp does after sending it
+func (t *cacheTee) Close() error {
+	t.abandon()
+	if closer, ok := t.body.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (t *cacheTee) abandon() {
+	t.abandoned = true
+	t.buf = bytes.Buffer{}
+	t.once.Do(func() { t.done(nil) })
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientIPResolver finds the originating client address behind trusted proxies
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+	headers []string
+}
+
+// NewClientIPResolver trusts the given proxy IPs or CIDRs and reads the client
+// address from headers in order of preference. Invalid entries are returned as
+// an error rather than silently ignored.
+func NewClientIPResolver(trustedProxies, headers []string) (*ClientIPResolver, error) {
+	trusted, err := parsePrefixes(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientIPResolver{trusted: trusted, headers: headers}, nil
+}
+
+// parsePrefixes parses CIDRs, treating plain addresses as single-host prefixes
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, err
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// containsIP reports whether ip lies within any of prefixes
+func containsIP(prefixes []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client IP for a request from peer with the given header getter.
+// Headers are only believed when the peer is a trusted proxy.
+func (r *ClientIPResolver) Resolve(peer string, header func(string) string) string {
+	if !r.isTrusted(peer) {
+		return peer
+	}
+
+	for _, name := range r.headers {
+		value := header(name)
+		if value == "" {
+			continue
+		}
+
+		if !strings.EqualFold(name, fiber.HeaderXForwardedFor) {
+			if addr, err := netip.ParseAddr(strings.TrimSpace(value)); err == nil {
+				return addr.Unmap().String()
+			}
+			continue
+		}
+
+		// Walk the chain from the nearest hop; the first untrusted hop is the client
+		hops := strings.Split(value, ",")
+		client := ""
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = addr.Unmap().String()
+			if !r.isTrusted(client) {
+				return client
+			}
+		}
+		if client != "" {
+			return client
+		}
+	}
+
+	return peer
+}
+
+// FromRequest resolves the client IP of a net/http request
+func (r *ClientIPResolver) FromRequest(req *http.Request) string {
+	peer, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		peer = req.RemoteAddr
+	}
+	return r.Resolve(peer, req.Header.Get)
+}
+
+func (r *ClientIPResolver) isTrusted(ip string) bool {
+	return containsIP(r.trusted, ip)
+}
+
+// ClientIPFiber resolves the client IP once per request for ClientIP
+func ClientIPFiber(resolver *ClientIPResolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		peer := c.Context().RemoteIP().String()
+		c.Locals("client_ip", resolver.Resolve(peer, func(name string) string { return c.Get(name) }))
+		c.Locals("trusted_peer", resolver.isTrusted(peer))
+		return c.Next()
+	}
+}
+
+// ClientIP returns the client IP resolved by ClientIPFiber, or the direct peer
+func ClientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals("client_ip").(string); ok {
+		return ip
+	}
+	return c.IP()
+}
+
+// ForwardedFor returns the X-Forwarded-For chain to send upstream. An incoming
+// chain is only extended when the direct peer is a trusted proxy.
+func ForwardedFor(c *fiber.Ctx) string {
+	peer := c.Context().RemoteIP().String()
+	if trusted, _ := c.Locals("trusted_peer").(bool); trusted {
+		if prior := c.Get(fiber.HeaderXForwardedFor); prior != "" {
+			return prior + ", " + peer
+		}
+	}
+	return peer
+}
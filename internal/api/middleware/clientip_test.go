@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.168.1.1"}, []string{"X-Real-IP", fiber.HeaderXForwardedFor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted peer ignores headers", "203.0.113.9", map[string]string{fiber.HeaderXForwardedFor: "1.2.3.4"}, "203.0.113.9"},
+		{"trusted peer without headers", "10.1.1.1", nil, "10.1.1.1"},
+		{"single-host trust", "192.168.1.1", map[string]string{fiber.HeaderXForwardedFor: "198.51.100.7"}, "198.51.100.7"},
+		{"preferred header first", "10.1.1.1", map[string]string{"X-Real-IP": "198.51.100.8", fiber.HeaderXForwardedFor: "1.2.3.4"}, "198.51.100.8"},
+		{"invalid preferred header skipped", "10.1.1.1", map[string]string{"X-Real-IP": "nonsense", fiber.HeaderXForwardedFor: "198.51.100.7"}, "198.51.100.7"},
+		// The client can prepend anything; only the first untrusted hop from the right counts
+		{"spoofed chain", "10.1.1.1", map[string]string{fiber.HeaderXForwardedFor: "6.6.6.6, 198.51.100.7, 10.2.2.2"}, "198.51.100.7"},
+		{"all hops trusted", "10.1.1.1", map[string]string{fiber.HeaderXForwardedFor: "10.3.3.3, 10.2.2.2"}, "10.3.3.3"},
+		{"garbage hop stops the walk", "10.1.1.1", map[string]string{fiber.HeaderXForwardedFor: "198.51.100.7, junk"}, "10.1.1.1"},
+		{"ipv4-mapped address", "::ffff:10.1.1.1", map[string]string{fiber.HeaderXForwardedFor: "::ffff:198.51.100.7"}, "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			if got := resolver.Resolve(tt.peer, header.Get); got != tt.want {
+				t.Fatalf("Resolve = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClientIPResolverRejectsInvalidProxies(t *testing.T) {
+	if _, err := NewClientIPResolver([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Fatal("expected an error for an invalid CIDR")
+	}
+	if _, err := NewClientIPResolver([]string{"proxy.internal"}, nil); err == nil {
+		t.Fatal("expected an error for a hostname")
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	for _, tt := range []struct {
+		trusted   string
+		client    string
+		forwarded string
+	}{
+		// app.Test connects from 0.0.0.0
+		{"0.0.0.0", "198.51.100.7", "198.51.100.7, 0.0.0.0"},
+		{"10.0.0.0/8", "0.0.0.0", "0.0.0.0"},
+	} {
+		resolver, err := NewClientIPResolver([]string{tt.trusted}, []string{fiber.HeaderXForwardedFor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := fiber.New()
+		app.Use(ClientIPFiber(resolver))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString(ClientIP(c) + "|" + ForwardedFor(c))
+		})
+
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderXForwardedFor, "198.51.100.7")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if want := tt.client + "|" + tt.forwarded; string(body) != want {
+			t.Errorf("trusting %s: got %q, want %q", tt.trusted, body, want)
+		}
+	}
+}
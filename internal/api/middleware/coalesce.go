@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"main/internal/cache"
+	"sync"
+	"time"
+)
+
+// CoalescedRequests returns how many cache misses were served from a concurrent fetch
+func CoalescedRequests() int64 {
+	return cacheCounters.coalesced.Load()
+}
+
+// coalescer lets concurrent cache misses for one key wait for the first request's
+// upstream fetch instead of each fetching it. Unlike singleflight the leader runs
+// the fetch itself, since a fiber.Ctx can't be used from another goroutine.
+type coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done  chan struct{}
+	entry *cache.Entry
+	key   string
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*coalescedCall)}
+}
+
+// join returns the in-progress call for key, or registers the caller as its
+// leader when there is none
+func (g *coalescer) join(key string) (call *coalescedCall, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, exists := g.calls[key]; exists {
+		return call, false
+	}
+	call = &coalescedCall{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// finish publishes the leader's result and the key it was stored under,
+// nil when it must not be shared
+func (g *coalescer) finish(key string, call *coalescedCall, entry *cache.Entry, storedKey string) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	call.entry = entry
+	call.key = storedKey
+	close(call.done)
+}
+
+// wait blocks until the leader finishes or timeout elapses and returns the shared
+// entry and its key, or nil when the caller has to fetch for itself
+func (call *coalescedCall) wait(timeout time.Duration) (*cache.Entry, string) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-call.done:
+		return call.entry, call.key
+	case <-timer.C:
+		return nil, ""
+	}
+}
@@ -1,18 +1,128 @@
 package middleware
 
 import (
-	"net/http"
+	"main/internal/config"
+	"slices"
+	"strconv"
+	"strings"
 
-	"github.com/go-chi/cors"
+	"github.com/gofiber/fiber/v2"
 )
 
-func Cors() func(http.Handler) http.Handler {
-	return cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"}, // Adjust for production!
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300, // Cache the preflight response for 5 minutes
+// Allowed preflight methods and headers when CORSConfig leaves them empty
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization"}
+)
+
+// CORSFiber sets CORS headers for origins listed in cfg.AllowedOrigins. An
+// entry matches exactly, or as "https://*.example.com" any subdomain of
+// example.com over https; "*" matches every origin but is sent as "*", so
+// browsers never send credentials with it. Other origins get no CORS headers.
+//
+// Responses always vary on Origin so caches never serve one origin's CORS
+// headers to another. Preflights are answered with 204 here, before auth, and
+// only allow the requested method and headers when all of them are allowed;
+// browsers cache them for cfg.MaxAge.
+func CORSFiber(cfg config.CORSConfig) fiber.Handler {
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	allowMethods := strings.Join(methods, ",")
+	allowHeaders := strings.Join(headers, ",")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ",")
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(c *fiber.Ctx) error {
+		c.Vary(fiber.HeaderOrigin)
+		origin := c.Get(fiber.HeaderOrigin)
+		preflight := c.Method() == fiber.MethodOptions && origin != "" &&
+			c.Get(fiber.HeaderAccessControlRequestMethod) != ""
+		if preflight {
+			c.Vary(fiber.HeaderAccessControlRequestMethod, fiber.HeaderAccessControlRequestHeaders)
+		}
+
+		allowed := origin != "" && (anyOrigin || allowsOrigin(cfg.AllowedOrigins, origin))
+		if allowed {
+			if anyOrigin {
+				c.Set(fiber.HeaderAccessControlAllowOrigin, "*")
+			} else {
+				c.Set(fiber.HeaderAccessControlAllowOrigin, origin)
+				if cfg.AllowCredentials {
+					c.Set(fiber.HeaderAccessControlAllowCredentials, "true")
+				}
+			}
+		}
+		if !preflight {
+			if allowed && exposeHeaders != "" {
+				c.Set(fiber.HeaderAccessControlExposeHeaders, exposeHeaders)
+			}
+			return c.Next()
+		}
+		if !allowed {
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		if containsFold(methods, c.Get(fiber.HeaderAccessControlRequestMethod)) {
+			c.Set(fiber.HeaderAccessControlAllowMethods, allowMethods)
+		}
+		if allowsHeaders(headers, c.Get(fiber.HeaderAccessControlRequestHeaders)) {
+			c.Set(fiber.HeaderAccessControlAllowHeaders, allowHeaders)
+		}
+		if cfg.MaxAge > 0 {
+			c.Set(fiber.HeaderAccessControlMaxAge, strconv.Itoa(int(cfg.MaxAge.Seconds())))
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// allowsOrigin reports whether origin matches one of the allowed origins,
+// exactly or through a "scheme://*.domain" wildcard. A wildcard matches
+// subdomains at any depth but not the domain itself.
+func allowsOrigin(allowed []string, origin string) bool {
+	for _, pattern := range allowed {
+		if strings.EqualFold(pattern, origin) {
+			return true
+		}
+		scheme, domain, found := strings.Cut(pattern, "://*.")
+		if !found || domain == "" {
+			continue
+		}
+		prefix := scheme + "://"
+		if len(origin) <= len(prefix) || !strings.EqualFold(origin[:len(prefix)], prefix) {
+			continue
+		}
+		host := strings.ToLower(origin[len(prefix):])
+		sub, found := strings.CutSuffix(host, "."+strings.ToLower(domain))
+		if found && sub != "" && !strings.ContainsAny(sub, "/:@?#") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether every header in the comma-separated requested
+// list is allowed; an empty list allows nothing
+func allowsHeaders(allowed []string, requested string) bool {
+	if strings.TrimSpace(requested) == "" {
+		return false
+	}
+	for _, header := range strings.Split(requested, ",") {
+		if !containsFold(allowed, strings.TrimSpace(header)) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
 	})
 }
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"main/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newCORSApp(cfg config.CORSConfig) *fiber.App {
+	app := fiber.New()
+	app.Use(CORSFiber(cfg))
+	app.Get("/items", func(c *fiber.Ctx) error {
+		return c.SendString("items")
+	})
+	return app
+}
+
+func corsRequest(t *testing.T, app *fiber.App, method, origin string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, "/items", nil)
+	if origin != "" {
+		req.Header.Set(fiber.HeaderOrigin, origin)
+	}
+	if method == fiber.MethodOptions {
+		req.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodGet)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCORSVariesOnOrigin(t *testing.T) {
+	app := newCORSApp(config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+
+	// Even requests without an Origin or from other origins, so a cached copy
+	// is never reused cross-origin
+	for _, origin := range []string{"", "https://app.example.com", "https://evil.example.net"} {
+		resp := corsRequest(t, app, fiber.MethodGet, origin)
+		if !strings.Contains(resp.Header.Get(fiber.HeaderVary), fiber.HeaderOrigin) {
+			t.Errorf("origin %q: Vary = %q, want Origin", origin, resp.Header.Get(fiber.HeaderVary))
+		}
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.CORSConfig
+		origin      string
+		allowOrigin string
+		credentials bool
+	}{
+		{"exact", config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}},
+			"https://app.example.com", "https://app.example.com", false},
+		{"exact with credentials", config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			"https://app.example.com", "https://app.example.com", true},
+		{"other origin", config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			"https://evil.example.net", "", false},
+		{"other scheme", config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}},
+			"http://app.example.com", "", false},
+		{"no origins configured", config.CORSConfig{AllowCredentials: true},
+			"https://app.example.com", "", false},
+		{"wildcard subdomain", config.CORSConfig{AllowedOrigins: []string{"https://*.example.com"}},
+			"https://shop.example.com", "https://shop.example.com", false},
+		{"wildcard nested subdomain with credentials", config.CORSConfig{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true},
+			"https://eu.shop.example.com", "https://eu.shop.example.com", true},
+		{"wildcard excludes the domain itself", config.CORSConfig{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true},
+			"https://example.com", "", false},
+		{"wildcard excludes lookalike domains", config.CORSConfig{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true},
+			"https://evilexample.com", "", false},
+		{"wildcard excludes other schemes", config.CORSConfig{AllowedOrigins: []string{"https://*.example.com"}},
+			"http://shop.example.com", "", false},
+		{"any origin never allows credentials", config.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			"https://anyone.example.net", "*", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.ExposedHeaders = []string{"X-Total-Count"}
+			app := newCORSApp(tt.cfg)
+			for _, method := range []string{fiber.MethodGet, fiber.MethodOptions} {
+				resp := corsRequest(t, app, method, tt.origin)
+				if got := resp.Header.Get(fiber.HeaderAccessControlAllowOrigin); got != tt.allowOrigin {
+					t.Errorf("%s: Allow-Origin = %q, want %q", method, got, tt.allowOrigin)
+				}
+				if got := resp.Header.Get(fiber.HeaderAccessControlAllowCredentials) == "true"; got != tt.credentials {
+					t.Errorf("%s: Allow-Credentials = %v, want %v", method, got, tt.credentials)
+				}
+				if tt.allowOrigin != "" {
+					continue
+				}
+				// Disallowed origins get no CORS headers at all
+				for name := range resp.Header {
+					if strings.HasPrefix(name, "Access-Control-") {
+						t.Errorf("%s: disallowed origin got %s", method, name)
+					}
+				}
+			}
+
+			resp := corsRequest(t, app, fiber.MethodGet, tt.origin)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Errorf("GET status %d, want it passed through", resp.StatusCode)
+			}
+			want := ""
+			if tt.allowOrigin != "" {
+				want = "X-Total-Count"
+			}
+			if got := resp.Header.Get(fiber.HeaderAccessControlExposeHeaders); got != want {
+				t.Errorf("Expose-Headers = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	app := newCORSApp(config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-Trace"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	tests := []struct {
+		name         string
+		method       string
+		headers      string
+		allowMethods string
+		allowHeaders string
+	}{
+		{"allowed", "post", "content-type, X-Trace", "GET,POST", "Content-Type,X-Trace"},
+		{"method not allowed", "DELETE", "Content-Type", "", "Content-Type,X-Trace"},
+		{"one header not allowed", "GET", "Content-Type, X-Other", "GET,POST", ""},
+		{"no headers requested", "GET", "", "GET,POST", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodOptions, "/items", nil)
+			req.Header.Set(fiber.HeaderOrigin, "https://app.example.com")
+			req.Header.Set(fiber.HeaderAccessControlRequestMethod, tt.method)
+			if tt.headers != "" {
+				req.Header.Set(fiber.HeaderAccessControlRequestHeaders, tt.headers)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != fiber.StatusNoContent {
+				t.Fatalf("status %d, want 204", resp.StatusCode)
+			}
+			if got := resp.Header.Get(fiber.HeaderAccessControlAllowMethods); got != tt.allowMethods {
+				t.Errorf("Allow-Methods = %q, want %q", got, tt.allowMethods)
+			}
+			if got := resp.Header.Get(fiber.HeaderAccessControlAllowHeaders); got != tt.allowHeaders {
+				t.Errorf("Allow-Headers = %q, want %q", got, tt.allowHeaders)
+			}
+			if got := resp.Header.Get(fiber.HeaderAccessControlMaxAge); got != "600" {
+				t.Errorf("Max-Age = %q, want the configured 600", got)
+			}
+		})
+	}
+}
+
+func TestCORSPlainOptionsPassesThrough(t *testing.T) {
+	app := newCORSApp(config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	app.Options("/items", func(c *fiber.Ctx) error {
+		return c.SendString("options")
+	})
+
+	// An OPTIONS request without Access-Control-Request-Method isn't a preflight
+	req := httptest.NewRequest(fiber.MethodOptions, "/items", nil)
+	req.Header.Set(fiber.HeaderOrigin, "https://app.example.com")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("status %d, want the route's 200", resp.StatusCode)
+	}
+}
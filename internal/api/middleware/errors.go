@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"main/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error is a fiber.Error with a machine-readable code and optional details.
+// Handlers return it and ErrorHandlerFiber renders it as models.ErrorResponse.
+type Error struct {
+	Status  int
+	Code    models.ErrorCode
+	Message string
+	Details fiber.Map
+}
+
+// NewError is fiber.NewError with an explicit error code
+func NewError(status int, code models.ErrorCode, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithDetails attaches extra fields rendered under "details"
+func (e *Error) WithDetails(details fiber.Map) *Error {
+	e.Details = details
+	return e
+}
+
+// RequestID returns the ID assigned by the requestid middleware, or the one the client sent
+func RequestID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("requestid").(string); ok && id != "" {
+		return id
+	}
+	return c.Get(fiber.HeaderXRequestID)
+}
+
+// ErrorHandlerFiber is the app-level error handler. It renders every error as
+// models.ErrorResponse in JSON, HTML or plain text depending on the Accept header;
+// errors other than *Error and *fiber.Error become a 500 without exposing their message.
+func ErrorHandlerFiber(c *fiber.Ctx, err error) error {
+	resp := models.ErrorResponse{
+		Error:     "internal server error",
+		Code:      models.ErrCodeInternal,
+		Status:    fiber.StatusInternalServerError,
+		RequestID: RequestID(c),
+	}
+
+	var gatewayErr *Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &gatewayErr):
+		resp.Error = gatewayErr.Message
+		resp.Code = gatewayErr.Code
+		resp.Status = gatewayErr.Status
+		resp.Details = gatewayErr.Details
+	case errors.As(err, &fiberErr):
+		resp.Error = fiberErr.Message
+		resp.Code = models.ErrorCodeForStatus(fiberErr.Code)
+		resp.Status = fiberErr.Code
+	}
+
+	c.Status(resp.Status)
+
+	// Browsers get a page and plain-text clients a line; anything else gets JSON
+	switch c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML, fiber.MIMETextPlain) {
+	case fiber.MIMETextHTML:
+		var page bytes.Buffer
+		if err := errorPage.Execute(&page, resp); err != nil {
+			return err
+		}
+		c.Type("html", "utf-8")
+		return c.Send(page.Bytes())
+	case fiber.MIMETextPlain:
+		c.Type("txt", "utf-8")
+		return c.SendString(errorText(resp))
+	default:
+		return c.JSON(resp)
+	}
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Status}} {{.Error}}</title></head>
+<body>
+<h1>{{.Status}} {{.Error}}</h1>
+<p>Error code: <code>{{.Code}}</code></p>
+{{if .RequestID}}<p>Request ID: <code>{{.RequestID}}</code></p>{{end}}
+</body>
+</html>
+`))
+
+func errorText(resp models.ErrorResponse) string {
+	text := fmt.Sprintf("%d %s (%s)", resp.Status, resp.Error, resp.Code)
+	if resp.RequestID != "" {
+		text += "\nrequest_id: " + resp.RequestID
+	}
+	return text + "\n"
+}
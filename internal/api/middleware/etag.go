@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConditionalFiber answers conditional GET/HEAD requests on the given path prefixes
+// with 304 Not Modified. Responses without a backend ETag get a strong one derived
+// from the body; backend ETags are kept as they are. Streamed bodies are never
+// buffered to hash them, so only backend ETags apply to those.
+func ConditionalFiber(paths []string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return c.Next()
+		}
+		if !hasPathPrefix(c.Path(), paths) {
+			return c.Next()
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Response().StatusCode() != fiber.StatusOK {
+			return nil
+		}
+
+		etag := string(c.Response().Header.Peek(fiber.HeaderETag))
+		if etag == "" && c.Response().IsBodyStream() {
+			return nil
+		}
+		if etag == "" {
+			sum := sha256.Sum256(c.Response().Body())
+			etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+			c.Set(fiber.HeaderETag, etag)
+		}
+
+		if notModified(c, etag) {
+			c.Status(fiber.StatusNotModified)
+			c.Response().ResetBody()
+			c.Response().Header.Del(fiber.HeaderContentType)
+			c.Response().Header.Del(fiber.HeaderContentLength)
+		}
+		return nil
+	}
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since only
+// when the request carries no entity tags (RFC 9110 section 13.2.2)
+func notModified(c *fiber.Ctx, etag string) bool {
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince := c.Get(fiber.HeaderIfModifiedSince)
+	lastModified := string(c.Response().Header.Peek(fiber.HeaderLastModified))
+	if ifModifiedSince == "" || lastModified == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// etagMatches performs the weak comparison If-None-Match requires
+func etagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
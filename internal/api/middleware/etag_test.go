@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newConditionalApp() *fiber.App {
+	app := fiber.New()
+	app.Use(ConditionalFiber([]string{"/docs"}))
+	app.Get("/docs/generated", func(c *fiber.Ctx) error {
+		return c.SendString("document")
+	})
+	app.Get("/docs/backend", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderETag, `"v7"`)
+		c.Set(fiber.HeaderLastModified, "Mon, 05 Oct 2026 10:00:00 GMT")
+		return c.SendString("document")
+	})
+	app.Get("/other", func(c *fiber.Ctx) error {
+		return c.SendString("document")
+	})
+	return app
+}
+
+func TestConditionalGeneratesETag(t *testing.T) {
+	app := newConditionalApp()
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/docs/generated", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := resp.Header.Get(fiber.HeaderETag)
+	if resp.StatusCode != fiber.StatusOK || etag == "" {
+		t.Fatalf("status %d, ETag %q: want 200 with an ETag", resp.StatusCode, etag)
+	}
+
+	// Revalidating with the ETag, weak or not, gets an empty 304
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"stale", ` + etag} {
+		req := httptest.NewRequest(fiber.MethodGet, "/docs/generated", nil)
+		req.Header.Set(fiber.HeaderIfNoneMatch, ifNoneMatch)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusNotModified || len(body) != 0 {
+			t.Errorf("If-None-Match %s: status %d with %d bytes, want an empty 304", ifNoneMatch, resp.StatusCode, len(body))
+		}
+	}
+
+	// Paths outside the configured prefixes are left alone
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/other", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get(fiber.HeaderETag); got != "" {
+		t.Fatalf("unconfigured path got ETag %q", got)
+	}
+}
+
+func TestConditionalBackendValidators(t *testing.T) {
+	app := newConditionalApp()
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"backend etag kept", fiber.HeaderIfNoneMatch, `"v7"`, fiber.StatusNotModified},
+		{"etag mismatch", fiber.HeaderIfNoneMatch, `"v6"`, fiber.StatusOK},
+		{"not modified since", fiber.HeaderIfModifiedSince, "Mon, 05 Oct 2026 10:00:00 GMT", fiber.StatusNotModified},
+		{"modified since", fiber.HeaderIfModifiedSince, "Sun, 04 Oct 2026 10:00:00 GMT", fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, "/docs/backend", nil)
+			req.Header.Set(tt.header, tt.value)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(fiber.HeaderETag); got != `"v7"` {
+				t.Fatalf("ETag %q, want the backend's", got)
+			}
+		})
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	req := httptest.NewRequest(fiber.MethodGet, "/docs/backend", nil)
+	req.Header.Set(fiber.HeaderIfNoneMatch, `"v6"`)
+	req.Header.Set(fiber.HeaderIfModifiedSince, "Mon, 05 Oct 2026 10:00:00 GMT")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status %d, want 200 when the entity tag does not match", resp.StatusCode)
+	}
+}
@@ -1,105 +1,105 @@
 package middleware
 
 import (
-	"main/internal/auth"
+	"encoding/json"
 	"main/internal/models"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	jwtv4 "github.com/golang-jwt/jwt/v4"
 	"go.uber.org/zap"
 )
 
-// JWTErrorHandler handles JWT validation errors
+// jwtMissingMessage is the error jwtware returns when no token is found
+const jwtMissingMessage = "Missing or malformed JWT"
+
+// JWTErrorHandler is the jwtware error handler: a request without a token
+// and one whose token fails validation get distinct codes
 func JWTErrorHandler(c *fiber.Ctx, err error) error {
-	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-		"error":  "invalid or expired token",
-		"status": fiber.StatusUnauthorized,
-	})
+	if err.Error() == jwtMissingMessage {
+		return NewError(fiber.StatusUnauthorized, models.ErrCodeAuthMissingToken, "missing or malformed token")
+	}
+	return NewError(fiber.StatusUnauthorized, models.ErrCodeAuthInvalidToken, "invalid or expired token")
 }
 
-// RateLimitReachedFiber handles rate limit exceeded
-func RateLimitReachedFiber(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-		"error":  "rate limit exceeded",
-		"status": fiber.StatusTooManyRequests,
-	})
+// RateLimitReachedFiber handles rate limit exceeded, naming the tier and cost applied
+func RateLimitReachedFiber(c *fiber.Ctx, info models.RateLimitInfo, tier string, cost int) error {
+	return NewError(fiber.StatusTooManyRequests, models.ErrCodeRateLimited, "rate limit exceeded").
+		WithDetails(fiber.Map{
+			"rate_limit": info,
+			"tier":       tier,
+			"cost":       cost,
+		})
 }
 
-// ValidateTokenFiber validates JWT token and extracts claims
-func ValidateTokenFiber(validator *auth.TokenValidator, log *zap.Logger) fiber.Handler {
+// RequireJWTRole rejects requests whose JWT doesn't carry the given role.
+// It must run after jwtware.
+func RequireJWTRole(role string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get JWT claims from context (set by jwtware middleware)
-		user := c.Locals("user")
-		if user == nil {
-			return fiber.NewError(fiber.StatusUnauthorized, "missing user in context")
+		if c.Locals("user") == nil {
+			return NewError(fiber.StatusUnauthorized, models.ErrCodeUnauthorized, "unauthorized")
 		}
-
-		claims, ok := user.(*jwt.Token).Claims.(jwt.MapClaims)
-		if !ok {
-			log.Error("Failed to parse JWT claims")
-			return fiber.NewError(fiber.StatusUnauthorized, "invalid token claims")
-		}
-
-		// Extract user information from claims
-		userID := ""
-		username := ""
-		email := ""
-		role := ""
-
-		if v, exists := claims["user_id"]; exists {
-			userID = v.(string)
+		if claimFromLocals(c, "role") != role {
+			return NewError(fiber.StatusForbidden, models.ErrCodeForbidden, "forbidden")
 		}
-		if v, exists := claims["username"]; exists {
-			username = v.(string)
-		}
-		if v, exists := claims["email"]; exists {
-			email = v.(string)
-		}
-		if v, exists := claims["role"]; exists {
-			role = v.(string)
-		}
-
-		// Add user information to headers for downstream services
-		c.Set("X-User-ID", userID)
-		c.Set("X-Username", username)
-		c.Set("X-User-Email", email)
-		c.Set("X-User-Role", role)
-
-		// Store claims in context for later use
-		c.Locals("claims", claims)
-
-		log.Debug("Token validated",
-			zap.String("user_id", userID),
-			zap.String("username", username),
-		)
-
 		return c.Next()
 	}
 }
 
-// RequestLogger logs all incoming requests (alternative to built-in logger)
-func RequestLoggerFiber(log *zap.Logger) fiber.Handler {
+// ValidateTokenFiber forwards the claims of the token validated by jwtware in
+// the request headers given by claimHeaders (claim name to header). Mapped
+// claims missing from the token are sent empty, so callers can't supply them.
+// Requests authenticated by API key pass through, as do anonymous requests
+// SkipAuth lets in, without any identity headers.
+func ValidateTokenFiber(claimHeaders map[string]string, log *zap.Logger) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		log.Info("Request received",
-			zap.String("method", c.Method()),
-			zap.String("path", c.Path()),
-			zap.String("ip", c.IP()),
-		)
+		if HasAPIKeyIdentity(c) {
+			return c.Next()
+		}
+		if SkipAuth(c) {
+			for _, header := range claimHeaders {
+				c.Request().Header.Del(header)
+			}
+			for _, header := range apiKeyIdentityHeaders {
+				c.Request().Header.Del(header)
+			}
+			return c.Next()
+		}
+
+		token, ok := c.Locals("user").(*jwtv4.Token)
+		if !ok {
+			return NewError(fiber.StatusUnauthorized, models.ErrCodeUnauthorized, "unauthorized")
+		}
+		claims, ok := token.Claims.(jwtv4.MapClaims)
+		if !ok {
+			RequestLogger(c, log).Error("Failed to parse JWT claims")
+			return NewError(fiber.StatusUnauthorized, models.ErrCodeUnauthorized, "unauthorized")
+		}
 
+		for claim, header := range claimHeaders {
+			c.Request().Header.Set(header, claimHeaderValue(claims[claim]))
+		}
 		return c.Next()
 	}
 }
 
-// ErrorHandler is a global error handler for Fiber
-func ErrorHandlerFiber(c *fiber.Ctx, err error) error {
-	code := fiber.StatusInternalServerError
-
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
+// claimHeaderValue renders a claim as a header value: strings as they are,
+// numbers and booleans in their usual form, anything else as JSON
+func claimHeaderValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
 	}
-
-	return c.Status(code).JSON(models.ErrorResponse{
-		Error:  err.Error(),
-		Status: code,
-	})
 }
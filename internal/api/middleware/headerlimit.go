@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"main/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HeaderLimitFiber rejects requests with more than maxCount headers, or whose
+// header names and values add up to more than maxBytes, with 431, so they are
+// never copied upstream. A limit of zero is not enforced.
+func HeaderLimitFiber(maxBytes, maxCount int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		count, size := 0, 0
+		c.Request().Header.VisitAll(func(key, value []byte) {
+			count++
+			size += len(key) + len(value)
+		})
+		if (maxCount > 0 && count > maxCount) || (maxBytes > 0 && size > maxBytes) {
+			return NewError(fiber.StatusRequestHeaderFieldsTooLarge, models.ErrCodeHeadersTooLarge, "request headers too large").
+				WithDetails(fiber.Map{"max_header_bytes": maxBytes, "max_header_count": maxCount})
+		}
+		return c.Next()
+	}
+}
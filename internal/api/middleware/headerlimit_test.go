@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/internal/models"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestHeaderLimit(t *testing.T) {
+	// A read buffer big enough that only the middleware limits headers
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandlerFiber, ReadBufferSize: 1 << 20})
+	app.Use(HeaderLimitFiber(4096, 50))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := map[string]struct {
+		headers map[string]string
+		status  int
+	}{
+		"within limits": {map[string]string{"X-Small": "value"}, fiber.StatusNoContent},
+		"too many":      {manyHeaders(10000), fiber.StatusRequestHeaderFieldsTooLarge},
+		"too large":     {map[string]string{"Cookie": strings.Repeat("x", 5000)}, fiber.StatusRequestHeaderFieldsTooLarge},
+	}
+	for name, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status == fiber.StatusRequestHeaderFieldsTooLarge {
+			var body models.ErrorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Code != models.ErrCodeHeadersTooLarge {
+				t.Errorf("%s: error %+v (%v), want %s", name, body, err, models.ErrCodeHeadersTooLarge)
+			}
+		}
+	}
+}
+
+func manyHeaders(n int) map[string]string {
+	headers := make(map[string]string, n)
+	for i := range n {
+		headers[fmt.Sprintf("X-Header-%d", i)] = "v"
+	}
+	return headers
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"main/internal/cache"
+	"main/internal/config"
+	"main/internal/models"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// IdempotentReplayedHeader marks a response replayed for a repeated idempotency key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyKey returns the idempotency key IdempotencyFiber is enforcing for
+// the request, or "" when there is none. Requests with a key may be retried.
+func IdempotencyKey(c *fiber.Ctx) string {
+	key, _ := c.Locals("idempotency_key").(string)
+	return key
+}
+
+// IdempotencyFiber makes POST and PATCH requests carrying cfg.Header safe to
+// repeat. The first response for a key is stored for cfg.TTL under the
+// caller, method, path and key, and repeats are answered from the store
+// without reaching the backend. Requests with a key in progress wait for it
+// to finish. Server errors and gateway errors are not stored, so a request
+// that failed may be tried again; nor are streamed bodies.
+//
+// Waiting is per gateway process: with the redis backend, concurrent repeats
+// reaching different processes may each be forwarded.
+func IdempotencyFiber(store cache.Cache, cfg config.IdempotencyConfig, log *zap.Logger) fiber.Handler {
+	locks := newKeyLocks()
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(cfg.Header)
+		if key == "" || (c.Method() != fiber.MethodPost && c.Method() != fiber.MethodPatch) {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return NewError(fiber.StatusBadRequest, models.ErrCodeBadRequest, "idempotency key too long").
+				WithDetails(fiber.Map{"max_length": maxIdempotencyKeyLength})
+		}
+		log := RequestLogger(c, log)
+		storeKey := strings.Join([]string{
+			"idempotency", TenantFromLocals(c), UserIDFromLocals(c), c.Method(), c.Path(), key,
+		}, "|")
+
+		unlock, err := locks.lock(c.UserContext(), storeKey)
+		if err != nil {
+			return NewError(fiber.StatusGatewayTimeout, models.ErrCodeDeadlineExceeded,
+				"request deadline exceeded waiting for a request with the same idempotency key")
+		}
+		defer unlock()
+
+		entry, found, err := store.Get(c.UserContext(), storeKey)
+		if err != nil {
+			log.Warn("Idempotency lookup failed", zap.String("key", storeKey), zap.Error(err))
+		}
+		if found {
+			log.Debug("Replaying response for idempotency key", zap.String("key", key))
+			for name, values := range entry.Header {
+				for _, value := range values {
+					c.Response().Header.Add(name, value)
+				}
+			}
+			c.Set(IdempotentReplayedHeader, "true")
+			return c.Status(entry.StatusCode).Send(entry.Body)
+		}
+
+		c.Locals("idempotency_key", key)
+		if err := c.Next(); err != nil {
+			return err
+		}
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError || c.Response().IsBodyStream() {
+			return nil
+		}
+
+		entry = &cache.Entry{
+			StatusCode: status,
+			Header:     make(http.Header),
+			Body:       append([]byte(nil), c.Response().Body()...),
+			StoredAt:   time.Now(),
+		}
+		c.Response().Header.VisitAll(func(k, v []byte) {
+			if name := http.CanonicalHeaderKey(string(k)); !uncachedHeaders[name] {
+				entry.Header.Add(name, string(v))
+			}
+		})
+		if err := store.Set(context.Background(), storeKey, entry, cfg.TTL); err != nil {
+			log.Warn("Idempotency store failed", zap.String("key", storeKey), zap.Error(err))
+		}
+		return nil
+	}
+}
+
+// keyLocks serializes the requests for each key
+type keyLocks struct {
+	mu   sync.Mutex
+	held map[string]chan struct{}
+}
+
+func newKeyLocks() *keyLocks {
+	return &keyLocks{held: make(map[string]chan struct{})}
+}
+
+// lock waits until key is free and takes it, or returns ctx's error when it
+// ends first. The returned function releases the key.
+func (l *keyLocks) lock(ctx context.Context, key string) (func(), error) {
+	for {
+		l.mu.Lock()
+		released, busy := l.held[key]
+		if !busy {
+			released = make(chan struct{})
+			l.held[key] = released
+			l.mu.Unlock()
+			return func() {
+				l.mu.Lock()
+				delete(l.held, key)
+				l.mu.Unlock()
+				close(released)
+			}, nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"io"
+	"main/internal/cache"
+	"main/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+func newIdempotentApp(handler fiber.Handler) *fiber.App {
+	cfg := config.IdempotencyConfig{Enabled: true, Header: "Idempotency-Key", TTL: time.Minute, Backend: "memory", MaxKeys: 100}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandlerFiber})
+	app.Use(IdempotencyFiber(cache.NewMemoryCache(cfg.MaxKeys), cfg, zap.NewNop()))
+	app.All("/payments", handler)
+	return app
+}
+
+func sendKeyed(t *testing.T, app *fiber.App, method, key string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/payments", nil)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var calls atomic.Int32
+	app := newIdempotentApp(func(c *fiber.Ctx) error {
+		n := calls.Add(1)
+		c.Set("X-Payment-ID", strconv.Itoa(int(n)))
+		return c.Status(fiber.StatusCreated).SendString("payment " + strconv.Itoa(int(n)))
+	})
+
+	first, firstBody := sendKeyed(t, app, fiber.MethodPost, "key-1")
+	repeat, repeatBody := sendKeyed(t, app, fiber.MethodPost, "key-1")
+	if calls.Load() != 1 {
+		t.Fatalf("backend called %d times for one key, want 1", calls.Load())
+	}
+	if repeat.StatusCode != fiber.StatusCreated || repeatBody != firstBody || repeat.Header.Get("X-Payment-ID") != "1" {
+		t.Errorf("repeat = %d %q, want the first response %d %q", repeat.StatusCode, repeatBody, first.StatusCode, firstBody)
+	}
+	if repeat.Header.Get(IdempotentReplayedHeader) != "true" || first.Header.Get(IdempotentReplayedHeader) != "" {
+		t.Error("only the repeat should be marked as replayed")
+	}
+
+	// Other keys, unkeyed requests and other methods reach the backend
+	sendKeyed(t, app, fiber.MethodPost, "key-2")
+	sendKeyed(t, app, fiber.MethodPost, "")
+	sendKeyed(t, app, fiber.MethodPut, "key-1")
+	if calls.Load() != 4 {
+		t.Errorf("backend called %d times, want 4", calls.Load())
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	app := newIdempotentApp(func(c *fiber.Ctx) error {
+		if calls.Add(1) == 1 {
+			return c.SendStatus(fiber.StatusServiceUnavailable)
+		}
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	sendKeyed(t, app, fiber.MethodPost, "key-1")
+	if resp, _ := sendKeyed(t, app, fiber.MethodPost, "key-1"); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("retry after a 503 = %d, want it forwarded again", resp.StatusCode)
+	}
+}
+
+func TestIdempotencySerializesConcurrentRequests(t *testing.T) {
+	var calls atomic.Int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	app := newIdempotentApp(func(c *fiber.Ctx) error {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+		}
+		return c.Status(fiber.StatusCreated).SendString("done")
+	})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 3)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i > 0 {
+				<-entered
+			}
+			_, bodies[i] = sendKeyed(t, app, fiber.MethodPost, "key-1")
+		}()
+	}
+	<-entered
+	// Give the repeats time to queue behind the first request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("backend called %d times for concurrent requests with one key, want 1", calls.Load())
+	}
+	for i, body := range bodies {
+		if body != "done" {
+			t.Errorf("request %d got %q, want the shared response", i, body)
+		}
+	}
+}
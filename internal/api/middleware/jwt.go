@@ -117,7 +117,7 @@ func (tv *TokenValidator) GenerateToken(userID, username, email, role string) (s
 		Email:    email,
 		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(tv.config.JWT.ExpiresIn) * time.Second)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tv.config.JWT.ExpiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    tv.config.JWT.Issuer,
@@ -137,7 +137,7 @@ func (tv *TokenValidator) GenerateToken(userID, username, email, role string) (s
 
 // RefreshToken generates a new token with extended expiration
 func (tv *TokenValidator) RefreshToken(claims *Claims) (string, error) {
-	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Duration(tv.config.JWT.ExpiresIn) * time.Second))
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(tv.config.JWT.ExpiresIn))
 	claims.IssuedAt = jwt.NewNumericDate(time.Now())
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
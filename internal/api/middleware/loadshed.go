@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"main/internal/models"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// InFlightLimiter caps the number of requests the gateway processes at once
+type InFlightLimiter struct {
+	slots    chan struct{}
+	wait     time.Duration
+	inFlight atomic.Int64
+}
+
+// NewInFlightLimiter creates a limiter allowing max concurrent requests.
+// A max of zero or less only tracks the in-flight count without limiting.
+func NewInFlightLimiter(max int, wait time.Duration) *InFlightLimiter {
+	l := &InFlightLimiter{wait: wait}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+	return l
+}
+
+// InFlight returns the number of requests currently being processed
+func (l *InFlightLimiter) InFlight() int64 {
+	return l.inFlight.Load()
+}
+
+// acquire takes a slot, waiting at most l.wait for one to free up
+func (l *InFlightLimiter) acquire() bool {
+	if l.slots == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (l *InFlightLimiter) release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// LoadShedFiber rejects requests with 503 once the in-flight cap is reached,
+// instead of letting them queue until they time out. Exempt paths are never shed.
+func LoadShedFiber(limiter *InFlightLimiter, exemptPaths ...string) fiber.Handler {
+	exempt := make(map[string]bool, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		if exempt[c.Path()] {
+			return c.Next()
+		}
+
+		start := time.Now()
+		acquired := limiter.acquire()
+		AddPhaseTime(c, PhaseQueue, time.Since(start))
+		if !acquired {
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return NewError(fiber.StatusServiceUnavailable, models.ErrCodeGatewayOverloaded, "gateway overloaded")
+		}
+		defer limiter.release()
+
+		limiter.inFlight.Add(1)
+		defer limiter.inFlight.Add(-1)
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"main/internal/models"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Maintenance is a runtime switch that takes proxied routes offline
+type Maintenance struct {
+	enabled    atomic.Bool
+	retryAfter time.Duration
+}
+
+// NewMaintenance creates a switch, initially off. retryAfter is the Retry-After
+// value sent while maintenance is on.
+func NewMaintenance(retryAfter time.Duration) *Maintenance {
+	return &Maintenance{retryAfter: retryAfter}
+}
+
+func (m *Maintenance) Enabled() bool {
+	return m.enabled.Load()
+}
+
+func (m *Maintenance) SetEnabled(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// MaintenanceFiber answers every request with 503 while maintenance is on
+func MaintenanceFiber(m *Maintenance) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !m.Enabled() {
+			return c.Next()
+		}
+
+		c.Set(fiber.HeaderRetryAfter, RetryAfter(m.retryAfter))
+		return NewError(fiber.StatusServiceUnavailable, models.ErrCodeMaintenance, "service under maintenance").
+			WithDetails(fiber.Map{
+				"message": "We're performing scheduled maintenance. Please try again shortly.",
+			})
+	}
+}
+
+// RetryAfter formats d as a Retry-After header value, in whole seconds
+// rounded up so clients never retry early
+func RetryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
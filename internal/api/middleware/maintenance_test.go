@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"encoding/json"
+	"main/internal/models"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMaintenanceFiber(t *testing.T) {
+	maintenance := NewMaintenance(1500 * time.Millisecond)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandlerFiber})
+	app.Use(MaintenanceFiber(maintenance))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for _, enabled := range []bool{false, true, false} {
+		maintenance.SetEnabled(enabled)
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !enabled {
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("maintenance off: status %d, want 200", resp.StatusCode)
+			}
+			continue
+		}
+		if resp.StatusCode != fiber.StatusServiceUnavailable {
+			t.Fatalf("maintenance on: status %d, want 503", resp.StatusCode)
+		}
+		// Retry-After is rounded up so clients don't come back early
+		if got := resp.Header.Get(fiber.HeaderRetryAfter); got != "2" {
+			t.Errorf("Retry-After = %q, want 2", got)
+		}
+		var body models.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Code != models.ErrCodeMaintenance {
+			t.Errorf("error %+v (%v), want %s", body, err, models.ErrCodeMaintenance)
+		}
+	}
+}
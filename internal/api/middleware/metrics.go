@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"main/internal/metrics"
+	"main/internal/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetUpstreamService records which service a request was forwarded to, for metrics
+func SetUpstreamService(c *fiber.Ctx, name string) {
+	c.Locals("upstream_service", name)
+}
+
+// SetRoutePattern records the route pattern, such as /payments/:id/refund,
+// that labels the request in metrics instead of the Fiber route
+func SetRoutePattern(c *fiber.Ctx, pattern string) {
+	c.Locals("route_pattern", pattern)
+}
+
+// SetRouteUnmatched marks a request that matched no known route, so metrics
+// label it by its raw path within the limits of RouteLabels
+func SetRouteUnmatched(c *fiber.Ctx) {
+	c.Locals("route_unmatched", true)
+}
+
+// unmatchedRouteLabel labels unmatched requests once RouteLabels is full
+const unmatchedRouteLabel = "other"
+
+// RouteLabels bounds the route labels raw paths can add: the first max
+// distinct paths keep their own label, later ones share "other"
+type RouteLabels struct {
+	max int
+
+	mu   sync.RWMutex
+	seen map[string]string
+}
+
+func NewRouteLabels(max int) *RouteLabels {
+	return &RouteLabels{max: max, seen: make(map[string]string)}
+}
+
+// Label returns the label for the raw path. Fiber reuses the memory behind
+// c.Path(), so the label returned is always a copy.
+func (r *RouteLabels) Label(path string) string {
+	r.mu.RLock()
+	label, seen := r.seen[path]
+	full := len(r.seen) >= r.max
+	r.mu.RUnlock()
+	if seen {
+		return label
+	}
+	if full {
+		return unmatchedRouteLabel
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if label, seen := r.seen[path]; seen {
+		return label
+	}
+	if len(r.seen) >= r.max {
+		return unmatchedRouteLabel
+	}
+	label = strings.Clone(path)
+	r.seen[label] = label
+	return label
+}
+
+// routeLabel returns the pattern set by the routing layer, the raw path of an
+// unmatched request as bounded by unmatched, or else the Fiber route pattern.
+// The raw path itself is never used unbounded, as IDs in paths would give
+// every request its own series.
+func routeLabel(c *fiber.Ctx, unmatched *RouteLabels) string {
+	if pattern, ok := c.Locals("route_pattern").(string); ok {
+		return pattern
+	}
+	if isUnmatched, _ := c.Locals("route_unmatched").(bool); isUnmatched {
+		return unmatched.Label(c.Path())
+	}
+	return c.Route().Path
+}
+
+// MetricsFiber records request counts, latency and in-flight requests, labelled
+// by route pattern rather than raw path. Errors are rendered here so the
+// recorded status is the one the client gets.
+func MetricsFiber(unmatched *RouteLabels) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		metrics.RequestsInFlight.Inc()
+		defer metrics.RequestsInFlight.Dec()
+
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		route := routeLabel(c, unmatched)
+		service, _ := c.Locals("upstream_service").(string)
+		metrics.RequestsTotal.WithLabelValues(c.Method(), route, strconv.Itoa(c.Response().StatusCode()), service).Inc()
+		metrics.RequestDuration.WithLabelValues(c.Method(), route, service).Observe(time.Since(c.Context().Time()).Seconds())
+		return nil
+	}
+}
+
+// IPAllowlistFiber only admits clients whose IP falls within one of cidrs,
+// which may also be single addresses
+func IPAllowlistFiber(cidrs []string) (fiber.Handler, error) {
+	allowed, err := parsePrefixes(cidrs)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *fiber.Ctx) error {
+		if containsIP(allowed, ClientIP(c)) {
+			return c.Next()
+		}
+		return NewError(fiber.StatusForbidden, models.ErrCodeForbidden, "forbidden")
+	}, nil
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"main/internal/metrics"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRouteLabelsBounded(t *testing.T) {
+	labels := NewRouteLabels(2)
+	if got := labels.Label("/a"); got != "/a" {
+		t.Fatalf("first path = %q", got)
+	}
+	if got := labels.Label("/b"); got != "/b" {
+		t.Fatalf("second path = %q", got)
+	}
+	if got := labels.Label("/c"); got != unmatchedRouteLabel {
+		t.Fatalf("path past the limit = %q, want %q", got, unmatchedRouteLabel)
+	}
+	// Paths seen before the limit keep their label
+	if got := labels.Label("/a"); got != "/a" {
+		t.Fatalf("known path = %q", got)
+	}
+}
+
+func TestMetricsLabelsByRoute(t *testing.T) {
+	app := fiber.New()
+	app.Use(MetricsFiber(NewRouteLabels(1)))
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/proxy/*", func(c *fiber.Ctx) error {
+		SetUpstreamService(c, "payments")
+		SetRoutePattern(c, "/proxy/payments/:id")
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Use(func(c *fiber.Ctx) error {
+		SetRouteUnmatched(c)
+		return c.SendStatus(fiber.StatusNotFound)
+	})
+
+	tests := []struct {
+		route, status, service string
+		want                   float64
+	}{
+		{"/users/:id", "200", "", 2},
+		{"/proxy/payments/:id", "200", "payments", 1},
+		{"/missing/1", "404", "", 1},
+		{unmatchedRouteLabel, "404", "", 1},
+	}
+	// The counter is global, so count what this test adds
+	before := make([]float64, len(tests))
+	for i, tt := range tests {
+		before[i] = testutil.ToFloat64(metrics.RequestsTotal.WithLabelValues(fiber.MethodGet, tt.route, tt.status, tt.service))
+	}
+
+	for _, path := range []string{"/users/1", "/users/2", "/proxy/payments/9", "/missing/1", "/missing/2"} {
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, tt := range tests {
+		got := testutil.ToFloat64(metrics.RequestsTotal.WithLabelValues(fiber.MethodGet, tt.route, tt.status, tt.service)) - before[i]
+		if got != tt.want {
+			t.Errorf("requests for %s = %v, want %v", tt.route, got, tt.want)
+		}
+	}
+}
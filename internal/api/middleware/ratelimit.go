@@ -1,12 +1,138 @@
 package middleware
 
 import (
+	"context"
+	"main/internal/auth"
+	"main/internal/models"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/gofiber/fiber/v2"
+	jwtv4 "github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
 	"golang.org/x/time/rate" // Official Go rate limit library
 )
 
+// RateLimiter decides whether the client identified by key may proceed and
+// reports the quota left. Implementations may be process-local or shared (e.g. Redis).
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, cost int) (bool, models.RateLimitInfo, error)
+}
+
+// RateLimitPolicy describes how requests are keyed, tiered and charged
+type RateLimitPolicy struct {
+	Key      KeyFunc
+	Tier     TierFunc
+	Limiters map[string]RateLimiter // per tier; tiers without a limiter are not limited
+	Costs    RouteCosts
+	FailOpen bool
+}
+
+// TierFunc chooses the rate limit tier of a request
+type TierFunc func(c *fiber.Ctx) string
+
+// Rate limit tiers
+const (
+	TierAnonymous     = "anonymous"
+	TierAuthenticated = "authenticated"
+	TierAdmin         = "admin"
+)
+
+// StaticTier puts every request in the same tier
+func StaticTier(tier string) TierFunc {
+	return func(c *fiber.Ctx) string {
+		return tier
+	}
+}
+
+// ClaimsTier derives the tier from the authenticated identity's role
+func ClaimsTier(c *fiber.Ctx) string {
+	if UserIDFromLocals(c) == "" {
+		return TierAnonymous
+	}
+	if RoleFromLocals(c) == "admin" {
+		return TierAdmin
+	}
+	return TierAuthenticated
+}
+
+// RouteCosts maps path prefixes to the number of tokens a request consumes
+type RouteCosts map[string]int
+
+// Cost returns the cost of the longest matching prefix, or 1
+func (rc RouteCosts) Cost(path string) int {
+	cost, matched := 1, 0
+	for prefix, prefixCost := range rc {
+		if len(prefix) > matched && strings.HasPrefix(path, prefix) {
+			cost, matched = prefixCost, len(prefix)
+		}
+	}
+	return max(cost, 1)
+}
+
+// KeyFunc derives the rate limit key of a request. Returning false skips limiting.
+type KeyFunc func(c *fiber.Ctx) (string, bool)
+
+// IPKey limits every request by client IP
+func IPKey(c *fiber.Ctx) (string, bool) {
+	return "ip:" + ClientIP(c), true
+}
+
+// AnonymousIPKey limits by client IP only requests carrying no credentials.
+// Authenticated requests are limited per user once the JWT has been validated.
+func AnonymousIPKey(c *fiber.Ctx) (string, bool) {
+	if c.Get(fiber.HeaderAuthorization) != "" || c.Get(APIKeyHeader) != "" {
+		return "", false
+	}
+	return IPKey(c)
+}
+
+// UserKey limits authenticated requests by their user_id claim
+func UserKey(c *fiber.Ctx) (string, bool) {
+	userID := UserIDFromLocals(c)
+	if userID == "" {
+		return "", false
+	}
+	return "user:" + userID, true
+}
+
+// UserIDFromLocals returns the API key client id or the user_id claim of the
+// token stored by jwtware
+func UserIDFromLocals(c *fiber.Ctx) string {
+	if identity, ok := c.Locals("api_client").(*auth.APIKeyIdentity); ok {
+		return identity.ClientID
+	}
+	return claimFromLocals(c, "user_id")
+}
+
+// RoleFromLocals returns the role of the API key identity or JWT
+func RoleFromLocals(c *fiber.Ctx) string {
+	if identity, ok := c.Locals("api_client").(*auth.APIKeyIdentity); ok {
+		return identity.Role
+	}
+	return claimFromLocals(c, "role")
+}
+
+// claimFromLocals returns a string claim of the token stored by jwtware
+func claimFromLocals(c *fiber.Ctx, name string) string {
+	token, ok := c.Locals("user").(*jwtv4.Token)
+	if !ok {
+		return ""
+	}
+
+	claims, ok := token.Claims.(jwtv4.MapClaims)
+	if !ok {
+		return ""
+	}
+
+	value, _ := claims[name].(string)
+	return value
+}
+
 // IPRateLimiter holds the rate limiters for each IP
 type IPRateLimiter struct {
 	ips map[string]*rate.Limiter
@@ -38,11 +164,36 @@ func (i *IPRateLimiter) getLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
-func Limit(limiter *IPRateLimiter) func(http.Handler) http.Handler {
+// Allow reports whether the given key may make a request costing cost tokens.
+// Limit is the sustained quota per minute, as reported by RedisRateLimiter, while
+// Remaining is what the bucket allows right now. Reset is the number of seconds
+// until the bucket is full again.
+func (i *IPRateLimiter) Allow(ctx context.Context, key string, cost int) (bool, models.RateLimitInfo, error) {
+	limiter := i.getLimiter(key)
+	allowed := limiter.AllowN(time.Now(), cost)
+
+	tokens := math.Max(limiter.Tokens(), 0)
+	info := models.RateLimitInfo{
+		Limit:     int(math.Round(float64(i.r) * 60)),
+		Remaining: int(tokens),
+		Reset:     secondsUntil(float64(i.b)-tokens, i.r),
+	}
+
+	return allowed, info, nil
+}
+
+// secondsUntil returns how many whole seconds it takes to refill n tokens at rate r
+func secondsUntil(n float64, r rate.Limit) int {
+	if n <= 0 || r <= 0 {
+		return 0
+	}
+	return int(math.Ceil(n / float64(r)))
+}
+
+func Limit(limiter *IPRateLimiter, resolver *ClientIPResolver) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Extract IP (Simplified: for production, check X-Forwarded-For)
-			ip := r.RemoteAddr
+			ip := resolver.FromRequest(r)
 
 			// Check if IP is allowed
 			if !limiter.getLimiter(ip).Allow() {
@@ -54,3 +205,56 @@ func Limit(limiter *IPRateLimiter) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// RateLimitFiber charges each request the route's cost against the limiter of its
+// tier, keyed by policy.Key. If the limiter itself fails, policy.FailOpen decides
+// whether traffic is let through.
+func RateLimitFiber(policy RateLimitPolicy, log *zap.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, ok := policy.Key(c)
+		if !ok {
+			return c.Next()
+		}
+
+		tier := policy.Tier(c)
+		limiter, ok := policy.Limiters[tier]
+		if !ok {
+			return c.Next()
+		}
+
+		cost := policy.Costs.Cost(c.Path())
+		allowed, info, err := limiter.Allow(c.UserContext(), tier+":"+key, cost)
+		if err != nil {
+			RequestLogger(c, log).Warn("Rate limiter unavailable",
+				zap.Error(err),
+				zap.String("tier", tier),
+				zap.Bool("fail_open", policy.FailOpen),
+			)
+			if policy.FailOpen {
+				return c.Next()
+			}
+			return NewError(fiber.StatusServiceUnavailable, models.ErrCodeRateLimiterUnavailable, "rate limiter unavailable")
+		}
+
+		setRateLimitHeaders(c, info)
+
+		if !allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter(info)))
+			return RateLimitReachedFiber(c, info, tier, cost)
+		}
+
+		return c.Next()
+	}
+}
+
+// setRateLimitHeaders exposes the client's quota on every response
+func setRateLimitHeaders(c *fiber.Ctx, info models.RateLimitInfo) {
+	c.Set("X-RateLimit-Limit", strconv.Itoa(info.Limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(info.Remaining))
+	c.Set("X-RateLimit-Reset", strconv.Itoa(info.Reset))
+}
+
+// retryAfter returns the Retry-After value in seconds, never less than one
+func retryAfter(info models.RateLimitInfo) int {
+	return max(info.Reset, 1)
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"main/internal/config"
+	"main/internal/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fixedWindowScript adds the request cost to the counter of the current window and
+// sets its expiry on first hit, so the check costs a single round trip to Redis
+var fixedWindowScript = redis.NewScript(`
+local current = redis.call("INCRBY", KEYS[1], ARGV[2])
+if current == tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return current
+`)
+
+// RedisRateLimiter is a fixed-window limiter shared by every gateway process
+// talking to the same Redis
+type RedisRateLimiter struct {
+	client *redis.Client
+	limit  int
+	window time.Duration
+	prefix string
+}
+
+func NewRedisRateLimiter(cfg config.RedisConfig, limit int, window time.Duration) *RedisRateLimiter {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Host + ":" + cfg.Port,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		// Keep timeouts tight: a slow Redis must not stall every request
+		DialTimeout:  200 * time.Millisecond,
+		ReadTimeout:  100 * time.Millisecond,
+		WriteTimeout: 100 * time.Millisecond,
+		MaxRetries:   -1,
+	})
+
+	return &RedisRateLimiter{
+		client: client,
+		limit:  limit,
+		window: window,
+		prefix: "ratelimit",
+	}
+}
+
+// Allow reports whether the given key may make a request costing cost tokens in the
+// current window. Reset is the number of seconds until the window ends.
+func (r *RedisRateLimiter) Allow(ctx context.Context, key string, cost int) (bool, models.RateLimitInfo, error) {
+	now := time.Now()
+	windowID := now.UnixNano() / int64(r.window)
+	windowKey := fmt.Sprintf("%s:%s:%d", r.prefix, key, windowID)
+
+	count, err := fixedWindowScript.Run(ctx, r.client, []string{windowKey}, r.window.Milliseconds(), cost).Int()
+	if err != nil {
+		return false, models.RateLimitInfo{}, fmt.Errorf("redis rate limit check failed: %w", err)
+	}
+
+	windowEnd := time.Unix(0, (windowID+1)*int64(r.window))
+	info := models.RateLimitInfo{
+		Limit:     r.limit,
+		Remaining: max(r.limit-count, 0),
+		Reset:     int(windowEnd.Sub(now).Round(time.Second).Seconds()),
+	}
+
+	return count <= r.limit, info, nil
+}
+
+// Close releases the underlying Redis connections
+func (r *RedisRateLimiter) Close() error {
+	return r.client.Close()
+}
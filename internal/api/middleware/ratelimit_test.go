@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestIPRateLimiterReportsPerMinuteLimit(t *testing.T) {
+	// 120 requests per minute with a burst of 5, as built by the router
+	limiter := NewIPRateLimiter(rate.Limit(120.0/60), 5)
+
+	for i := range 5 {
+		allowed, info, err := limiter.Allow(context.Background(), "ip:10.0.0.1", 1)
+		if err != nil || !allowed {
+			t.Fatalf("request %d: allowed = %v, err = %v", i, allowed, err)
+		}
+		if info.Limit != 120 {
+			t.Fatalf("Limit = %d, want the per-minute quota 120", info.Limit)
+		}
+		if info.Remaining != 4-i {
+			t.Fatalf("request %d: Remaining = %d, want %d", i, info.Remaining, 4-i)
+		}
+	}
+
+	allowed, info, _ := limiter.Allow(context.Background(), "ip:10.0.0.1", 1)
+	if allowed {
+		t.Fatal("request past the burst was allowed")
+	}
+	if info.Remaining != 0 || info.Reset < 1 {
+		t.Fatalf("exhausted bucket reported %+v", info)
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.uber.org/zap"
+)
+
+// maxRequestIDLength bounds client-supplied IDs so they can't bloat logs
+const maxRequestIDLength = 128
+
+// RequestIDFiber assigns every request an ID: the client's X-Request-ID when it is
+// well formed, a new UUID otherwise. The ID is echoed in the response, and a logger
+// carrying it is stored for RequestLogger.
+func RequestIDFiber(log *zap.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID(id) {
+			id = utils.UUIDv4()
+		}
+
+		c.Locals("requestid", id)
+		c.Locals("logger", log.With(zap.String("request_id", id)))
+		c.Set(fiber.HeaderXRequestID, id)
+		return c.Next()
+	}
+}
+
+// validRequestID accepts IDs of letters, digits and - _ . : only, so they are
+// safe to log and to forward as a header
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch ch := id[i]; {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestLogger returns the logger tagged with the request's ID, or fallback
+// for requests that didn't pass through RequestIDFiber
+func RequestLogger(c *fiber.Ctx, fallback *zap.Logger) *zap.Logger {
+	if log, ok := c.Locals("logger").(*zap.Logger); ok {
+		return log
+	}
+	return fallback
+}
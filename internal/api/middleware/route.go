@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"main/internal/config"
+	"main/internal/models"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	jwtv4 "github.com/golang-jwt/jwt/v4"
+)
+
+// SetRoute records the configured route a request matched or, when routes
+// cover its path only for other methods, the methods allowed
+func SetRoute(c *fiber.Ctx, route *config.RouteConfig, allowed []string) {
+	if route != nil {
+		c.Locals("route", route)
+	}
+	if len(allowed) > 0 {
+		c.Locals("route_allowed_methods", allowed)
+	}
+}
+
+// RouteFromLocals returns the route recorded by SetRoute, or nil
+func RouteFromLocals(c *fiber.Ctx) *config.RouteConfig {
+	route, _ := c.Locals("route").(*config.RouteConfig)
+	return route
+}
+
+// RouteAuth returns the auth mode of the request's route; requests matching
+// no route require authentication
+func RouteAuth(c *fiber.Ctx) string {
+	if route := RouteFromLocals(c); route != nil {
+		return route.Auth
+	}
+	return config.RouteAuthRequired
+}
+
+// SkipAuth reports whether the request goes through without authentication:
+// on a public route, or an optional one when it carries no credentials
+func SkipAuth(c *fiber.Ctx) bool {
+	switch RouteAuth(c) {
+	case config.RouteAuthPublic:
+		return true
+	case config.RouteAuthOptional:
+		return c.Get(fiber.HeaderAuthorization) == "" && c.Get(APIKeyHeader) == ""
+	}
+	return false
+}
+
+// RouteAccessFiber rejects callers without one of the route's roles or all of
+// its scopes: anonymous callers with 401, others with 403. Methods the routes
+// for the path don't take get 405. It must run after authentication.
+func RouteAccessFiber() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if allowed, ok := c.Locals("route_allowed_methods").([]string); ok {
+			c.Set(fiber.HeaderAllow, strings.Join(allowed, ", "))
+			return NewError(fiber.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "method not allowed").
+				WithDetails(fiber.Map{"allowed": allowed})
+		}
+		route := RouteFromLocals(c)
+		if route == nil || (len(route.Roles) == 0 && len(route.Scopes) == 0) {
+			return c.Next()
+		}
+		if !authenticated(c) {
+			return NewError(fiber.StatusUnauthorized, models.ErrCodeUnauthorized, "unauthorized")
+		}
+		if len(route.Roles) > 0 && !slices.Contains(route.Roles, RoleFromLocals(c)) {
+			return NewError(fiber.StatusForbidden, models.ErrCodeForbidden, "forbidden").
+				WithDetails(fiber.Map{"required_roles": route.Roles})
+		}
+		scopes := ScopesFromLocals(c)
+		for _, scope := range route.Scopes {
+			if !slices.Contains(scopes, scope) {
+				return NewError(fiber.StatusForbidden, models.ErrCodeForbidden, "forbidden").
+					WithDetails(fiber.Map{"required_scopes": route.Scopes})
+			}
+		}
+		return c.Next()
+	}
+}
+
+// ScopesFromLocals returns the scopes granted by the JWT stored by jwtware:
+// its space-separated "scope" claim or its "scopes" list. API keys carry none.
+func ScopesFromLocals(c *fiber.Ctx) []string {
+	token, ok := c.Locals("user").(*jwtv4.Token)
+	if !ok {
+		return nil
+	}
+	claims, ok := token.Claims.(jwtv4.MapClaims)
+	if !ok {
+		return nil
+	}
+
+	scopes := strings.Fields(claimFromLocals(c, "scope"))
+	if list, ok := claims["scopes"].([]any); ok {
+		for _, scope := range list {
+			if s, ok := scope.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"main/internal/config"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SecurityHeadersFiber sets the hardening headers cfg configures on every
+// response. They are set before the request is handled, so a backend that
+// sends one of them itself, such as its own Content-Security-Policy, wins.
+func SecurityHeadersFiber(cfg config.SecurityHeadersConfig) fiber.Handler {
+	var headers [][2]string
+	if cfg.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge.Seconds()), 10)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+		headers = append(headers, [2]string{fiber.HeaderStrictTransportSecurity, hsts})
+	}
+	if cfg.NoSniff {
+		headers = append(headers, [2]string{fiber.HeaderXContentTypeOptions, "nosniff"})
+	}
+	for _, header := range [][2]string{
+		{fiber.HeaderXFrameOptions, strings.ToUpper(cfg.FrameOptions)},
+		{fiber.HeaderReferrerPolicy, cfg.ReferrerPolicy},
+		{fiber.HeaderContentSecurityPolicy, cfg.ContentSecurityPolicy},
+	} {
+		if header[1] != "" && !strings.EqualFold(header[1], "off") {
+			headers = append(headers, header)
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		for _, header := range headers {
+			c.Set(header[0], header[1])
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"main/internal/config"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SecurityHeadersConfig
+		// backendCSP is the Content-Security-Policy the handler sets itself
+		backendCSP string
+		want       map[string]string
+	}{
+		{"all", config.SecurityHeadersConfig{
+			HSTSMaxAge: 365 * 24 * time.Hour, HSTSIncludeSubdomains: true, HSTSPreload: true, NoSniff: true,
+			FrameOptions: "sameorigin", ReferrerPolicy: "no-referrer", ContentSecurityPolicy: "default-src 'none'",
+		}, "", map[string]string{
+			fiber.HeaderStrictTransportSecurity: "max-age=31536000; includeSubDomains; preload",
+			fiber.HeaderXContentTypeOptions:     "nosniff",
+			fiber.HeaderXFrameOptions:           "SAMEORIGIN",
+			fiber.HeaderReferrerPolicy:          "no-referrer",
+			fiber.HeaderContentSecurityPolicy:   "default-src 'none'",
+		}},
+		{"each left out", config.SecurityHeadersConfig{FrameOptions: "off", ReferrerPolicy: "OFF"}, "", map[string]string{
+			fiber.HeaderStrictTransportSecurity: "",
+			fiber.HeaderXContentTypeOptions:     "",
+			fiber.HeaderXFrameOptions:           "",
+			fiber.HeaderReferrerPolicy:          "",
+			fiber.HeaderContentSecurityPolicy:   "",
+		}},
+		{"backend's own header wins", config.SecurityHeadersConfig{HSTSMaxAge: time.Hour, ContentSecurityPolicy: "default-src 'none'"}, "default-src 'self'", map[string]string{
+			fiber.HeaderStrictTransportSecurity: "max-age=3600",
+			fiber.HeaderContentSecurityPolicy:   "default-src 'self'",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(SecurityHeadersFiber(tt.cfg))
+			app.Get("/", func(c *fiber.Ctx) error {
+				if tt.backendCSP != "" {
+					c.Set(fiber.HeaderContentSecurityPolicy, tt.backendCSP)
+				}
+				return c.SendString("ok")
+			})
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for header, want := range tt.want {
+				if got := resp.Header.Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"main/internal/models"
+	"main/internal/redact"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// Request phases timed for the slow request log, besides the upstream call
+// recorded by SetUpstreamDuration
+const (
+	PhaseQueue     = "queue"
+	PhaseAuth      = "auth"
+	PhaseSerialize = "serialize"
+)
+
+// AddPhaseTime adds d to the time the request spent in phase
+func AddPhaseTime(c *fiber.Ctx, phase string, d time.Duration) {
+	previous, _ := c.Locals("phase_" + phase).(time.Duration)
+	c.Locals("phase_"+phase, previous+d)
+}
+
+func phaseTime(c *fiber.Ctx, phase string) time.Duration {
+	d, _ := c.Locals("phase_" + phase).(time.Duration)
+	return d
+}
+
+// StartPhase and EndPhase time the handlers registered between them as phase
+func StartPhase(phase string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("phase_start_"+phase, time.Now())
+		return c.Next()
+	}
+}
+
+func EndPhase(phase string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if start, ok := c.Locals("phase_start_" + phase).(time.Time); ok {
+			AddPhaseTime(c, phase, time.Since(start))
+		}
+		return c.Next()
+	}
+}
+
+// SlowRequestFiber logs requests slower than threshold at Warn with a
+// breakdown of where the time went, and keeps them in slowest. Errors are
+// rendered here so the recorded status is the one the client gets.
+func SlowRequestFiber(threshold time.Duration, slowest *SlowRequests, log *zap.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		duration := time.Since(c.Context().Time())
+		if duration < threshold {
+			return nil
+		}
+
+		upstreamDuration, _ := c.Locals("upstream_duration").(time.Duration)
+		upstream, _ := c.Locals("upstream_service").(string)
+		request := models.SlowRequest{
+			Method:      c.Method(),
+			Path:        c.Path(),
+			Query:       redact.Query(string(c.Request().URI().QueryString())),
+			Status:      c.Response().StatusCode(),
+			Upstream:    upstream,
+			RequestID:   RequestID(c),
+			DurationMs:  milliseconds(duration),
+			QueueMs:     milliseconds(phaseTime(c, PhaseQueue)),
+			AuthMs:      milliseconds(phaseTime(c, PhaseAuth)),
+			UpstreamMs:  milliseconds(upstreamDuration),
+			SerializeMs: milliseconds(phaseTime(c, PhaseSerialize)),
+			Time:        c.Context().Time().UTC(),
+		}
+		slowest.Record(request)
+
+		RequestLogger(c, log).Warn("Slow request",
+			zap.String("method", request.Method),
+			zap.String("path", request.Path),
+			zap.String("query", request.Query),
+			zap.Int("status", request.Status),
+			zap.String("upstream", request.Upstream),
+			zap.Float64("duration_ms", request.DurationMs),
+			zap.Float64("queue_ms", request.QueueMs),
+			zap.Float64("auth_ms", request.AuthMs),
+			zap.Float64("upstream_ms", request.UpstreamMs),
+			zap.Float64("serialize_ms", request.SerializeMs),
+		)
+		return nil
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// The slowest requests are kept per slowWindow over slowWindows windows, so
+// old entries age out a window at a time
+const (
+	slowWindow  = 5 * time.Minute
+	slowWindows = 12
+)
+
+// SlowRequests keeps the slowest requests of the last hour, at most size per
+// window. Requests faster than everything a full window holds are turned away
+// without taking the window's lock.
+type SlowRequests struct {
+	size    int
+	windows [slowWindows]slowWindowRequests
+}
+
+type slowWindowRequests struct {
+	// floor is the fastest duration kept once the window starting at
+	// floorStart (unix seconds) is full, in nanoseconds
+	floor      atomic.Int64
+	floorStart atomic.Int64
+
+	mu       sync.Mutex
+	start    time.Time
+	requests []models.SlowRequest // slowest first
+}
+
+func NewSlowRequests(size int) *SlowRequests {
+	return &SlowRequests{size: size}
+}
+
+// Record keeps request if it is among the slowest of its window
+func (s *SlowRequests) Record(request models.SlowRequest) {
+	if s.size <= 0 {
+		return
+	}
+
+	start := request.Time.Truncate(slowWindow)
+	w := &s.windows[start.Unix()/int64(slowWindow.Seconds())%slowWindows]
+	duration := int64(request.DurationMs * float64(time.Millisecond))
+	if w.floorStart.Load() == start.Unix() && duration <= w.floor.Load() {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Reuse the slot of a window that has aged out
+	if !w.start.Equal(start) {
+		if start.Before(w.start) {
+			return
+		}
+		w.start = start
+		w.requests = w.requests[:0]
+		w.floor.Store(0)
+		w.floorStart.Store(start.Unix())
+	}
+
+	i := sort.Search(len(w.requests), func(i int) bool {
+		return w.requests[i].DurationMs < request.DurationMs
+	})
+	if i >= s.size {
+		return
+	}
+	if len(w.requests) < s.size {
+		w.requests = append(w.requests, models.SlowRequest{})
+	}
+	copy(w.requests[i+1:], w.requests[i:])
+	w.requests[i] = request
+
+	if len(w.requests) == s.size {
+		last := w.requests[len(w.requests)-1]
+		w.floor.Store(int64(last.DurationMs * float64(time.Millisecond)))
+	}
+}
+
+// Slowest returns the size slowest requests of the last hour, slowest first
+func (s *SlowRequests) Slowest() []models.SlowRequest {
+	cutoff := time.Now().Add(-slowWindow * slowWindows)
+
+	requests := []models.SlowRequest{}
+	for i := range s.windows {
+		w := &s.windows[i]
+		w.mu.Lock()
+		if w.start.After(cutoff) {
+			requests = append(requests, w.requests...)
+		}
+		w.mu.Unlock()
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].DurationMs > requests[j].DurationMs
+	})
+	if len(requests) > s.size {
+		requests = requests[:s.size]
+	}
+	return requests
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"main/internal/config"
+	"main/internal/models"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TenantFiber resolves the request's tenant from the JWT claim and the
+// subdomain of cfg.BaseDomain, and passes it upstream in config.TenantHeader,
+// replacing any caller value. Requests naming no tenant pass through without
+// one. Tenants without a service in known get a 404. A subdomain tenant gets a
+// 403 unless the caller's claim names the same tenant, or the caller is
+// unauthenticated and cfg.AllowAnonymousHost is set: the Host header is the
+// caller's choice. It must run after authentication.
+func TenantFiber(cfg config.TenancyConfig, known map[string]bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Request().Header.Del(config.TenantHeader)
+
+		var fromClaim string
+		if cfg.Claim != "" && !HasAPIKeyIdentity(c) {
+			fromClaim = claimFromLocals(c, cfg.Claim)
+		}
+		fromHost := subdomainTenant(string(c.Request().Host()), cfg.BaseDomain)
+
+		if fromClaim != "" && fromHost != "" && fromClaim != fromHost {
+			return NewError(fiber.StatusForbidden, models.ErrCodeForbidden, "tenant mismatch")
+		}
+		if fromHost != "" && fromClaim == "" && (authenticated(c) || !cfg.AllowAnonymousHost) {
+			return NewError(fiber.StatusForbidden, models.ErrCodeForbidden, "tenant not authorised")
+		}
+		tenant := fromClaim
+		if tenant == "" {
+			tenant = fromHost
+		}
+		if tenant == "" {
+			return c.Next()
+		}
+		if !known[tenant] {
+			return NewError(fiber.StatusNotFound, models.ErrCodeNotFound, "unknown tenant")
+		}
+
+		c.Locals("tenant_id", tenant)
+		c.Request().Header.Set(config.TenantHeader, tenant)
+		return c.Next()
+	}
+}
+
+// TenantFromLocals returns the tenant resolved by TenantFiber, if any
+func TenantFromLocals(c *fiber.Ctx) string {
+	tenant, _ := c.Locals("tenant_id").(string)
+	return tenant
+}
+
+// authenticated reports whether the request carries a verified API key or JWT
+func authenticated(c *fiber.Ctx) bool {
+	return HasAPIKeyIdentity(c) || c.Locals("user") != nil
+}
+
+// subdomainTenant returns the part of host before baseDomain, without any port
+func subdomainTenant(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	tenant, ok := strings.CutSuffix(strings.ToLower(host), "."+baseDomain)
+	if !ok {
+		return ""
+	}
+	return tenant
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"main/internal/auth"
+	"main/internal/config"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	jwtv4 "github.com/golang-jwt/jwt/v4"
+)
+
+func TestTenantFiber(t *testing.T) {
+	known := map[string]bool{"acme": true, "globex": true}
+	tests := map[string]struct {
+		host      string
+		claim     string // tenant claim of a JWT caller; "-" for no JWT
+		apiKey    bool
+		anonymous bool
+		wantCode  int
+		wantSent  string
+	}{
+		"claim only":                   {host: "gw.example.com", claim: "acme", wantCode: 200, wantSent: "acme"},
+		"subdomain matching claim":     {host: "acme.gw.example.com", claim: "acme", wantCode: 200, wantSent: "acme"},
+		"subdomain against claim":      {host: "globex.gw.example.com", claim: "acme", wantCode: 403},
+		"subdomain without claim":      {host: "globex.gw.example.com", claim: "", wantCode: 403},
+		"subdomain with API key":       {host: "globex.gw.example.com", claim: "-", apiKey: true, anonymous: true, wantCode: 403},
+		"anonymous subdomain":          {host: "globex.gw.example.com", claim: "-", wantCode: 403},
+		"anonymous subdomain allowed":  {host: "globex.gw.example.com", claim: "-", anonymous: true, wantCode: 200, wantSent: "globex"},
+		"unknown tenant":               {host: "gw.example.com", claim: "initech", wantCode: 404},
+		"no tenant passes through":     {host: "gw.example.com", claim: "", wantCode: 200},
+		"caller header is not trusted": {host: "gw.example.com", claim: "-", wantCode: 200},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.TenancyConfig{Enabled: true, Claim: "tenant_id", BaseDomain: "gw.example.com", AllowAnonymousHost: tt.anonymous}
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandlerFiber})
+			app.Use(func(c *fiber.Ctx) error {
+				if tt.claim != "-" {
+					c.Locals("user", &jwtv4.Token{Claims: jwtv4.MapClaims{"tenant_id": tt.claim}})
+				}
+				if tt.apiKey {
+					c.Locals("api_client", &auth.APIKeyIdentity{ClientID: "billing"})
+				}
+				return c.Next()
+			})
+			app.Use(TenantFiber(cfg, known))
+			var sent string
+			app.Get("/", func(c *fiber.Ctx) error {
+				sent = c.Get(config.TenantHeader)
+				return nil
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			req.Host = tt.host
+			req.Header.Set(config.TenantHeader, "spoofed")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantCode || sent != tt.wantSent {
+				t.Fatalf("status %d, tenant header %q; want %d, %q", resp.StatusCode, sent, tt.wantCode, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"main/internal/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TimeoutMiddleware bounds the rest of the handler chain to d through the
+// request's user context, which upstream calls and cache lookups are made with,
+// so they are cancelled when it expires. Requests that run out of time get a 504.
+// A streamed response keeps the deadline until it is sent.
+func TimeoutMiddleware(d time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), d)
+		defer func() {
+			if !c.Response().IsBodyStream() {
+				cancel()
+			}
+		}()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return NewError(fiber.StatusGatewayTimeout, models.ErrCodeGatewayTimeout, "request timed out")
+		}
+		return err
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"main/internal/tracing"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// requestHeaderCarrier exposes fasthttp request headers to OTel propagators
+type requestHeaderCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h requestHeaderCarrier) Get(key string) string { return h.c.Get(key) }
+func (h requestHeaderCarrier) Set(key, value string) { h.c.Request().Header.Set(key, value) }
+func (h requestHeaderCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// TracingFiber starts a server span per request, continuing any trace the client
+// propagated. The span context becomes the request's user context, and the
+// trace ID is added to the request logger so logs and traces cross-reference.
+// The span carries the request ID and, once forwarded, the upstream service.
+// Errors are rendered here so the recorded status is the one the client gets.
+func TracingFiber() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestHeaderCarrier{c})
+		ctx, span := tracing.Tracer().Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", c.Path()),
+				attribute.String("request.id", RequestID(c)),
+			),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+			if log, ok := c.Locals("logger").(*zap.Logger); ok {
+				c.Locals("logger", log.With(zap.String("trace_id", spanContext.TraceID().String())))
+			}
+		}
+
+		if err := c.Next(); err != nil {
+			span.RecordError(err)
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				return err
+			}
+		}
+
+		status := c.Response().StatusCode()
+		span.SetName(c.Method() + " " + c.Route().Path)
+		span.SetAttributes(
+			attribute.String("http.route", c.Route().Path),
+			attribute.Int("http.response.status_code", status),
+		)
+		if service, ok := c.Locals("upstream_service").(string); ok {
+			span.SetAttributes(attribute.String("gateway.service", service))
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		return nil
+	}
+}
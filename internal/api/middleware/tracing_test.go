@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+// recordSpans installs a tracer provider recording every span, and the W3C
+// propagator, until the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return recorder
+}
+
+func TestTracingContinuesTraceWithRequestID(t *testing.T) {
+	recorder := recordSpans(t)
+
+	app := fiber.New()
+	app.Use(RequestIDFiber(zap.NewNop()))
+	app.Use(TracingFiber())
+	app.Get("/orders/:id", func(c *fiber.Ctx) error {
+		SetUpstreamService(c, "orders")
+		return c.SendStatus(fiber.StatusAccepted)
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/orders/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(fiber.HeaderXRequestID, "req-123")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans recorded, want 1", len(spans))
+	}
+	span := spans[0]
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID %s, want the caller's", got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span %s, want the caller's", got)
+	}
+	if span.Name() != "GET /orders/:id" {
+		t.Errorf("span name %q, want the route", span.Name())
+	}
+
+	want := map[attribute.Key]attribute.Value{
+		"request.id":                attribute.StringValue("req-123"),
+		"gateway.service":           attribute.StringValue("orders"),
+		"http.response.status_code": attribute.IntValue(fiber.StatusAccepted),
+	}
+	for _, attr := range span.Attributes() {
+		if value, ok := want[attr.Key]; ok {
+			if attr.Value != value {
+				t.Errorf("%s = %v, want %v", attr.Key, attr.Value.Emit(), value.Emit())
+			}
+			delete(want, attr.Key)
+		}
+	}
+	for key := range want {
+		t.Errorf("span attribute %s missing", key)
+	}
+}
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"main/internal/api/middleware"
+	"main/internal/config"
+	"main/internal/gateway"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+func TestSlowServiceDoesNotStarveOthers(t *testing.T) {
+	tests := []struct {
+		name string
+		// slowMaxConcurrent gives the slow service a bulkhead and with it a
+		// transport of its own
+		slowMaxConcurrent int
+		want              int
+	}{
+		{"dedicated transport", 2, fiber.StatusOK},
+		// Without the bulkhead both services share one pool, the hung slow
+		// calls hold every connection to the host and the fast call times out
+		{"shared transport", 0, fiber.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Both services live on the same host, so only the transport
+			// keeps them apart
+			var slowHits atomic.Int32
+			release := make(chan struct{})
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/slow" {
+					slowHits.Add(1)
+					select {
+					case <-release:
+					case <-r.Context().Done():
+					}
+					return
+				}
+				w.Write([]byte("fast"))
+			}))
+			defer backend.Close()
+
+			slow := config.ServiceConfig{Name: "slow", URL: backend.URL, Timeout: 10 * time.Second, MaxRetry: 1, MaxConcurrent: tt.slowMaxConcurrent}
+			fast := config.ServiceConfig{Name: "fast", URL: backend.URL, Timeout: 500 * time.Millisecond, MaxRetry: 1}
+			cfg := &config.Config{}
+			cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, MinRequests: 100, FailureRatio: 1}
+			cfg.Upstream.Pool = config.PoolConfig{MaxConnsPerHost: 2}
+			cfg.Upstream.Services = []config.ServiceConfig{slow, fast}
+			proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+			app.All("/slow", func(c *fiber.Ctx) error {
+				return ForwardRequest(c, cfg, proxy, slow, c.Path(), zap.NewNop())
+			})
+			app.All("/fast", func(c *fiber.Ctx) error {
+				return ForwardRequest(c, cfg, proxy, fast, c.Path(), zap.NewNop())
+			})
+
+			// Two slow calls hang, holding two connections to the host
+			var wg sync.WaitGroup
+			for range 2 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					app.Test(httptest.NewRequest(fiber.MethodGet, "/slow", nil), -1)
+				}()
+			}
+			defer func() {
+				close(release)
+				wg.Wait()
+			}()
+			waitFor(t, func() bool { return slowHits.Load() == 2 })
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/fast", nil), 5000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("fast service answered %d while the slow one is saturated, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
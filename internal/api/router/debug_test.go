@@ -0,0 +1,54 @@
+package router
+
+import (
+	"main/internal/api/middleware"
+	"main/internal/config"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+func TestDebugRoutesAccess(t *testing.T) {
+	tests := map[string]struct {
+		enabled bool
+		allowed []string
+		key     string
+		want    int
+	}{
+		// app.Test connections come from 0.0.0.0
+		"no key":          {true, []string{"0.0.0.0"}, "", fiber.StatusUnauthorized},
+		"service key":     {true, []string{"0.0.0.0"}, "service-key", fiber.StatusForbidden},
+		"admin key":       {true, []string{"0.0.0.0"}, "admin-key", fiber.StatusOK},
+		"external caller": {true, []string{"10.0.0.0/8"}, "admin-key", fiber.StatusForbidden},
+		"pprof disabled":  {false, []string{"0.0.0.0"}, "admin-key", fiber.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Server.PprofEnabled = tt.enabled
+			cfg.Server.InternalAllowedIPs = tt.allowed
+			cfg.APIKeys.Enabled = true
+			cfg.APIKeys.Keys = []config.APIKeyEntry{
+				{Key: "service-key", ClientID: "billing", Role: "service"},
+				{Key: "admin-key", ClientID: "ops", Role: "admin"},
+			}
+
+			app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+			SetupDebugRoutes(app, cfg, zap.NewNop())
+
+			req := httptest.NewRequest(fiber.MethodGet, "/debug/pprof/", nil)
+			if tt.key != "" {
+				req.Header.Set(middleware.APIKeyHeader, tt.key)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
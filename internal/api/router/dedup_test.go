@@ -0,0 +1,99 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingBackend counts requests and holds them until release is closed
+func blockingBackend(t *testing.T) (*httptest.Server, *atomic.Int32, chan struct{}) {
+	t.Helper()
+	var hits atomic.Int32
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(backend.Close)
+	return backend, &hits, release
+}
+
+func TestDoUpstreamSharesIdenticalRequests(t *testing.T) {
+	backend, hits, release := blockingBackend(t)
+
+	var wg sync.WaitGroup
+	var shared atomic.Int32
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, backend.URL+"/items", nil)
+			resp, wasShared, err := doUpstream(http.DefaultClient, http.MethodGet, req, true, 5*time.Second, 0, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(resp.Body) != "ok" {
+				t.Errorf("body = %q", resp.Body)
+			}
+			if wasShared {
+				shared.Add(1)
+			}
+		}()
+	}
+	waitFor(t, func() bool { return hits.Load() >= 1 })
+	// Give the other requests time to join the leader's call
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("backend saw %d requests, want 1", got)
+	}
+	if got := shared.Load(); got != 4 {
+		t.Fatalf("%d responses marked shared, want 4", got)
+	}
+}
+
+func TestDoUpstreamKeepsCallersApart(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header string
+	}{
+		{"authorization", http.MethodGet, "Authorization"},
+		{"api key", http.MethodGet, "X-API-Key"},
+		{"cookie", http.MethodGet, "Cookie"},
+		// Not idempotent: never shared, even with identical credentials
+		{"post", http.MethodPost, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, hits, release := blockingBackend(t)
+
+			var wg sync.WaitGroup
+			for _, value := range []string{"caller-a", "caller-b"} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req, _ := http.NewRequest(tt.method, backend.URL+"/items", nil)
+					if tt.header != "" {
+						req.Header.Set(tt.header, value)
+					}
+					if _, shared, err := doUpstream(http.DefaultClient, tt.method, req, true, 5*time.Second, 0, 0); err != nil || shared {
+						t.Errorf("shared = %v, err = %v", shared, err)
+					}
+				}()
+			}
+			// Both reach the backend while neither has been answered
+			waitFor(t, func() bool { return hits.Load() >= 2 })
+			close(release)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,727 @@
+package router
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"main/internal/api/middleware"
+	"main/internal/cache"
+	"main/internal/config"
+	"main/internal/gateway"
+	"main/internal/metrics"
+	"main/internal/models"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+// newForwardApp forwards every request to service through a real proxy
+func newForwardApp(t *testing.T, service config.ServiceConfig, handlers ...fiber.Handler) *fiber.App {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, MinRequests: 100, FailureRatio: 1}
+	cfg.Upstream.RetryBudgetRatio = 0.1
+	cfg.Upstream.RetryBudgetMax = 10
+	cfg.Upstream.DeadlineHeader = "X-Request-Timeout-Ms"
+	cfg.Upstream.Services = []config.ServiceConfig{service}
+	proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber, DisableStartupMessage: true})
+	for _, handler := range handlers {
+		app.Use(handler)
+	}
+	app.All("/*", func(c *fiber.Ctx) error {
+		return ForwardRequest(c, cfg, proxy, service, c.Path(), zap.NewNop())
+	})
+	return app
+}
+
+func errorCode(t *testing.T, resp *http.Response) models.ErrorCode {
+	t.Helper()
+	var body models.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding error response: %v", err)
+	}
+	return body.Code
+}
+
+// waitFor polls condition until it holds, failing the test after five seconds
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestForwardRequestTimeouts(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(3 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+	app := newForwardApp(t, config.ServiceConfig{Name: "slow", URL: backend.URL, Timeout: time.Second, MaxRetry: 1, Affinity: "none"})
+
+	// The service timeout running out is the backend timing out
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/orders", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := errorCode(t, resp); resp.StatusCode != fiber.StatusGatewayTimeout || code != models.ErrCodeUpstreamTimeout {
+		t.Fatalf("service timeout: %d %s, want 504 %s", resp.StatusCode, code, models.ErrCodeUpstreamTimeout)
+	}
+
+	// The caller's shorter budget running out is the deadline being exceeded
+	req := httptest.NewRequest(fiber.MethodGet, "/orders", nil)
+	req.Header.Set("X-Request-Timeout-Ms", "100")
+	resp, err = app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := errorCode(t, resp); resp.StatusCode != fiber.StatusGatewayTimeout || code != models.ErrCodeDeadlineExceeded {
+		t.Fatalf("caller deadline: %d %s, want 504 %s", resp.StatusCode, code, models.ErrCodeDeadlineExceeded)
+	}
+}
+
+func TestForwardRequestRetries(t *testing.T) {
+	// A backend that accepts connections and drops them unanswered
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			conn.Close()
+		}
+	}()
+	idempotency := config.IdempotencyConfig{Enabled: true, Header: "Idempotency-Key", TTL: time.Minute, Backend: "memory", MaxKeys: 10}
+	app := newForwardApp(t, config.ServiceConfig{
+		Name: "flaky", URL: "http://" + listener.Addr().String(), Timeout: 5 * time.Second, MaxRetry: 3, Affinity: "none",
+	}, middleware.IdempotencyFiber(cache.NewMemoryCache(idempotency.MaxKeys), idempotency, zap.NewNop()))
+
+	tests := []struct {
+		method   string
+		key      string
+		attempts int32
+	}{
+		{fiber.MethodGet, "", 3},
+		// Not idempotent: never retried
+		{fiber.MethodPost, "", 1},
+		// The key dedups the client's repeats, not the gateway's: the
+		// backend got the request and may have run it
+		{fiber.MethodPost, "order-1", 1},
+	}
+	for _, tt := range tests {
+		accepted.Store(0)
+		req := httptest.NewRequest(tt.method, "/orders", nil)
+		if tt.key != "" {
+			req.Header.Set("Idempotency-Key", tt.key)
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadGateway {
+			t.Errorf("%s: status %d, want 502", tt.method, resp.StatusCode)
+		}
+		if got := accepted.Load(); got != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.method, got, tt.attempts)
+		}
+	}
+}
+
+func TestForwardRequestRetriesKeyedUnsent(t *testing.T) {
+	// A port nothing listens on refuses connections, so no request is sent
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refusing := "http://" + listener.Addr().String()
+	listener.Close()
+	idempotency := config.IdempotencyConfig{Enabled: true, Header: "Idempotency-Key", TTL: time.Minute, Backend: "memory", MaxKeys: 10}
+	app := newForwardApp(t, config.ServiceConfig{
+		Name: "refusing", URL: refusing, Timeout: 5 * time.Second, MaxRetry: 3, Affinity: "none",
+	}, middleware.IdempotencyFiber(cache.NewMemoryCache(idempotency.MaxKeys), idempotency, zap.NewNop()))
+
+	tests := []struct {
+		key     string
+		retries float64
+	}{
+		{"", 0},
+		{"order-2", 2},
+	}
+	for _, tt := range tests {
+		retries := metrics.UpstreamRetries.WithLabelValues("refusing", config.RetryOnConnectionError)
+		before := testutil.ToFloat64(retries)
+		req := httptest.NewRequest(fiber.MethodPost, "/orders", nil)
+		if tt.key != "" {
+			req.Header.Set("Idempotency-Key", tt.key)
+		}
+		if _, err := app.Test(req, 5000); err != nil {
+			t.Fatal(err)
+		}
+		if got := testutil.ToFloat64(retries) - before; got != tt.retries {
+			t.Errorf("key %q: %v retries, want %v", tt.key, got, tt.retries)
+		}
+	}
+}
+
+func TestForwardRequestRetryPolicy(t *testing.T) {
+	// Every request fails with the status in its path until its third attempt
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			status, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+			w.WriteHeader(status)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer backend.Close()
+	idempotency := config.IdempotencyConfig{Enabled: true, Header: "Idempotency-Key", TTL: time.Minute, Backend: "memory", MaxKeys: 10}
+	app := newForwardApp(t, config.ServiceConfig{
+		Name: "policy", URL: backend.URL, Timeout: 5 * time.Second, Affinity: "none",
+		Retry: &config.RetryPolicy{
+			Attempts:    3,
+			RetryOn:     []string{config.RetryOn5xx},
+			Statuses:    []int{fiber.StatusTooManyRequests},
+			BackoffBase: time.Millisecond,
+			BackoffCap:  2 * time.Millisecond,
+		},
+	}, middleware.IdempotencyFiber(cache.NewMemoryCache(idempotency.MaxKeys), idempotency, zap.NewNop()))
+
+	tests := []struct {
+		method string
+		key    string
+		status int
+		want   int
+		calls  int32
+	}{
+		{fiber.MethodGet, "", fiber.StatusServiceUnavailable, fiber.StatusOK, 3},
+		{fiber.MethodGet, "", fiber.StatusTooManyRequests, fiber.StatusOK, 3},
+		// Neither 5xx nor a listed status
+		{fiber.MethodGet, "", fiber.StatusConflict, fiber.StatusConflict, 1},
+		// The backend may have committed the payment before failing
+		{fiber.MethodPost, "payment-1", fiber.StatusServiceUnavailable, fiber.StatusServiceUnavailable, 1},
+	}
+	for _, tt := range tests {
+		calls.Store(0)
+		retries := metrics.UpstreamRetries.WithLabelValues("policy", strconv.Itoa(tt.status))
+		before := testutil.ToFloat64(retries)
+		req := httptest.NewRequest(tt.method, "/"+strconv.Itoa(tt.status), nil)
+		if tt.key != "" {
+			req.Header.Set("Idempotency-Key", tt.key)
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %d: status %d, want %d", tt.method, tt.status, resp.StatusCode, tt.want)
+		}
+		if got := calls.Load(); got != tt.calls {
+			t.Errorf("%s %d: %d calls, want %d", tt.method, tt.status, got, tt.calls)
+		}
+		if got := testutil.ToFloat64(retries) - before; got != float64(tt.calls-1) {
+			t.Errorf("%s %d: %v retries counted, want %d", tt.method, tt.status, got, tt.calls-1)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := config.RetryPolicy{BackoffBase: 100 * time.Millisecond, BackoffCap: time.Second}
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 40: time.Second} {
+		if got := retryBackoff(policy, n); got != want {
+			t.Errorf("retry %d waits %v, want %v", n, got, want)
+		}
+	}
+	policy.Jitter = 0.5
+	for range 100 {
+		if got := retryBackoff(policy, 5); got <= 500*time.Millisecond || got > time.Second {
+			t.Fatalf("jittered wait %v, want within (500ms, 1s]", got)
+		}
+	}
+}
+
+func TestForwardRequestUnavailable(t *testing.T) {
+	// A port nothing listens on refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refusing := "http://" + listener.Addr().String()
+	listener.Close()
+
+	// A backend that answers with something other than HTTP
+	garbled, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer garbled.Close()
+	go func() {
+		for {
+			conn, err := garbled.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, "not http\r\n\r\n")
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name       string
+		url        string
+		status     int
+		code       models.ErrorCode
+		retryAfter string
+	}{
+		{"down", refusing, fiber.StatusServiceUnavailable, models.ErrCodeUpstreamRefused, "30"},
+		{"bad response", "http://" + garbled.Addr().String(), fiber.StatusBadGateway, models.ErrCodeUpstreamBadResponse, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newForwardApp(t, config.ServiceConfig{
+				Name: "orders", URL: tt.url, Timeout: 5 * time.Second, MaxRetry: 2, RetryAfter: 30 * time.Second, Affinity: "none",
+			})
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/orders", nil), 5000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := errorCode(t, resp); resp.StatusCode != tt.status || code != tt.code {
+				t.Errorf("got %d %s, want %d %s", resp.StatusCode, code, tt.status, tt.code)
+			}
+			if got := resp.Header.Get(fiber.HeaderRetryAfter); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestForwardRequestStreamsEvents(t *testing.T) {
+	next := make(chan struct{})
+	disconnected := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		// The second event is only sent once the client has received the first
+		<-next
+		w.Write([]byte("data: second\n\n"))
+		w.(http.Flusher).Flush()
+		// Keep the stream alive until the gateway hangs up
+		for {
+			select {
+			case <-r.Context().Done():
+				close(disconnected)
+				return
+			case <-time.After(20 * time.Millisecond):
+				w.Write([]byte(": keep-alive\n\n"))
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer backend.Close()
+
+	app := newForwardApp(t, config.ServiceConfig{Name: "events", URL: backend.URL, Timeout: 30 * time.Second, MaxRetry: 1, Affinity: "none"})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	defer app.Shutdown()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		reader.ReadString('\n')
+		return strings.TrimSpace(line)
+	}
+
+	if got := readEvent(); got != "data: first" {
+		t.Fatalf("first event = %q", got)
+	}
+	close(next)
+	if got := readEvent(); got != "data: second" {
+		t.Fatalf("second event = %q", got)
+	}
+
+	// The gateway notices the client going away on its next write and ends
+	// the backend request
+	resp.Body.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("backend never saw the client disconnect")
+	}
+}
+
+func TestForwardRequestServiceTLS(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer backend.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pinning the backend's certificate as the CA lets the request through
+	app := newForwardApp(t, config.ServiceConfig{
+		Name: "secure", URL: backend.URL, Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none", TLS: &config.TLSConfig{CAFile: caFile},
+	})
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/data", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != fiber.StatusOK || string(body) != "secure" {
+		t.Fatalf("with CA: %d %q, want 200 from the backend", resp.StatusCode, body)
+	}
+
+	// Verification stays on without it
+	app = newForwardApp(t, config.ServiceConfig{Name: "secure", URL: backend.URL, Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none"})
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/data", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := errorCode(t, resp); resp.StatusCode != fiber.StatusBadGateway || code != models.ErrCodeUpstreamTLS {
+		t.Fatalf("without CA: %d %s, want 502 %s", resp.StatusCode, code, models.ErrCodeUpstreamTLS)
+	}
+}
+
+func TestForwardRequestCanary(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	stable, canary := backend("stable"), backend("canary")
+	app := newForwardApp(t, config.ServiceConfig{
+		Name: "search", URL: stable.URL, Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none",
+		Canary: &config.CanaryConfig{URL: canary.URL, Header: "X-Canary", Cookie: "canary", Value: "always"},
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		want   string
+	}{
+		{"no opt-in", "", "", "stable"},
+		{"matching header", "always", "", "canary"},
+		{"other header value", "never", "", "stable"},
+		{"matching cookie", "", "always", "canary"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, "/search", nil)
+		if tt.header != "" {
+			req.Header.Set("X-Canary", tt.header)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "canary", Value: tt.cookie})
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != tt.want {
+			t.Errorf("%s: served by %q, want %s", tt.name, body, tt.want)
+		}
+	}
+}
+
+func TestForwardRequestPropagatesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	traceparent := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+	}))
+	defer backend.Close()
+	app := newForwardApp(t, config.ServiceConfig{Name: "orders", URL: backend.URL, Timeout: 5 * time.Second, MaxRetry: 1})
+
+	if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/orders", nil), 5000); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "upstream orders" {
+		t.Fatalf("recorded %d spans, want the upstream span", len(spans))
+	}
+	span := spans[0]
+	// The backend continues the trace from the upstream span
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := <-traceparent; got != want {
+		t.Errorf("traceparent %q, want %q", got, want)
+	}
+
+	var latency, status bool
+	for _, attr := range span.Attributes() {
+		switch attr.Key {
+		case "gateway.upstream_latency_ms":
+			latency = true
+		case "http.response.status_code":
+			status = attr.Value.AsInt64() == fiber.StatusOK
+		}
+	}
+	if !latency || !status {
+		t.Errorf("upstream span attributes %v, want the latency and status 200", span.Attributes())
+	}
+}
+
+func TestForwardRequestAllowedMethods(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("replica"))
+	}))
+	defer backend.Close()
+	app := newForwardApp(t, config.ServiceConfig{
+		Name: "replica", URL: backend.URL, Timeout: 5 * time.Second, MaxRetry: 1, AllowedMethods: []string{"GET", "HEAD"},
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/reports", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != fiber.StatusOK || string(body) != "replica" {
+		t.Fatalf("GET: %d %q, want it forwarded", resp.StatusCode, body)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodPost, "/reports", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allow := resp.Header.Get(fiber.HeaderAllow); allow != "GET, HEAD" {
+		t.Errorf("Allow = %q, want the allowed methods", allow)
+	}
+	if code := errorCode(t, resp); resp.StatusCode != fiber.StatusMethodNotAllowed || code != models.ErrCodeMethodNotAllowed {
+		t.Fatalf("POST: %d %s, want 405 %s", resp.StatusCode, code, models.ErrCodeMethodNotAllowed)
+	}
+}
+
+func TestForwardRequestBasicAuth(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "gateway" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("legacy"))
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name      string
+		basicAuth *config.BasicAuthConfig
+		status    int
+	}{
+		{"configured", &config.BasicAuthConfig{Username: "gateway", Password: "s3cret"}, fiber.StatusOK},
+		{"not configured", nil, fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		app := newForwardApp(t, config.ServiceConfig{
+			Name: "legacy", URL: backend.URL, Timeout: 5 * time.Second, MaxRetry: 1, BasicAuth: tt.basicAuth,
+		})
+		req := httptest.NewRequest(fiber.MethodGet, "/reports", nil)
+		// The caller's own credentials never reach the service
+		req.SetBasicAuth("gateway", "guessed")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestForwardRequestTransform(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/orders":
+			// The backend only knows the new field names
+			var order map[string]any
+			if err := json.Unmarshal(body, &order); err != nil || order["customer_id"] != "c-1" || order["customerId"] != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"order_id":7,"customer_id":"c-1","items":[{"order_id":7}]}`))
+		case "/report":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write(body)
+		case "/broken":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"order_id":`))
+		}
+	}))
+	defer backend.Close()
+
+	app := newForwardApp(t, config.ServiceConfig{
+		Name: "orders", URL: backend.URL, Timeout: 5 * time.Second, MaxRetry: 1,
+		Transform: &config.TransformConfig{
+			RequestRenames:  map[string]string{"customerId": "customer_id"},
+			ResponseRenames: map[string]string{"order_id": "orderId", "customer_id": "customerId"},
+		},
+	})
+	send := func(path, contentType, body string) *http.Response {
+		req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := send("/orders", "application/json", `{"customerId":"c-1"}`)
+	body, _ := io.ReadAll(resp.Body)
+	if want := `{"customerId":"c-1","items":[{"orderId":7}],"orderId":7}`; resp.StatusCode != fiber.StatusOK || string(body) != want {
+		t.Errorf("JSON round trip = %d %s, want %s", resp.StatusCode, body, want)
+	}
+
+	// Bodies that aren't JSON pass through untouched
+	resp = send("/report", "text/csv", "customerId,order_id")
+	if body, _ := io.ReadAll(resp.Body); string(body) != "customerId,order_id" {
+		t.Errorf("CSV round trip = %d %s, want it unchanged", resp.StatusCode, body)
+	}
+
+	resp = send("/broken", "application/json", `{}`)
+	if resp.StatusCode != fiber.StatusBadGateway || errorCode(t, resp) != models.ErrCodeTransformFailed {
+		t.Errorf("malformed JSON response = %d, want 502 %s", resp.StatusCode, models.ErrCodeTransformFailed)
+	}
+}
+
+func TestForwardRequestResponseLimit(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		if r.URL.Path == "/chunked" {
+			// Flushing first leaves the length undeclared
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("x", size)))
+	}))
+	defer backend.Close()
+
+	limited := newForwardApp(t, config.ServiceConfig{
+		Name: "reports", URL: backend.URL, Timeout: 5 * time.Second, MaxRetry: 3, MaxResponseBytes: 1000,
+	})
+	unlimited := newForwardApp(t, config.ServiceConfig{
+		Name: "files", URL: backend.URL, Timeout: 5 * time.Second, MaxRetry: 3, MaxResponseBytes: config.NoLimit,
+	})
+	tests := []struct {
+		name    string
+		app     *fiber.App
+		target  string
+		status  int
+		outcome string
+	}{
+		{"declared within limit", limited, "/declared?size=1000", fiber.StatusOK, ""},
+		{"declared over limit", limited, "/declared?size=1001", fiber.StatusBadGateway, "rejected"},
+		{"undeclared within limit", limited, "/chunked?size=1000", fiber.StatusOK, ""},
+		{"undeclared over limit", limited, "/chunked?size=5000", fiber.StatusBadGateway, "aborted"},
+		{"no limit", unlimited, "/chunked?size=5000", fiber.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		counter := metrics.ResponsesTooLarge.WithLabelValues("reports", tt.outcome)
+		before := testutil.ToFloat64(counter)
+		resp, err := tt.app.Test(httptest.NewRequest(fiber.MethodGet, tt.target, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.outcome == "" {
+			continue
+		}
+		if code := errorCode(t, resp); code != models.ErrCodeResponseTooLarge {
+			t.Errorf("%s: code %s, want %s", tt.name, code, models.ErrCodeResponseTooLarge)
+		}
+		if got := testutil.ToFloat64(counter) - before; got != 1 {
+			t.Errorf("%s: counted %v %s responses, want 1", tt.name, got, tt.outcome)
+		}
+	}
+}
+
+func TestUpstreamStreamCutsOffAtLimit(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader(strings.Repeat("x", 5000))}
+	var reached int64
+	stream := &upstreamStream{
+		ReadCloser: body,
+		bytesOut:   metrics.BytesOut.WithLabelValues("stream-test"),
+		done:       func() {},
+		limit:      1000,
+		exceeded:   func(read int64) { reached = read },
+	}
+
+	got, err := io.ReadAll(stream)
+	if len(got) != 1000 {
+		t.Errorf("streamed %d bytes, want the 1000 within the limit", len(got))
+	}
+	if !errors.As(err, new(*responseTooLargeError)) {
+		t.Errorf("stream error = %v, want the limit reported", err)
+	}
+	if !body.closed || reached <= 1000 {
+		t.Errorf("upstream closed %v, exceeded at %d bytes; want it closed past 1000", body.closed, reached)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"encoding/json"
+	"main/internal/api/middleware"
+	"main/internal/auth"
+	"main/internal/config"
+	"main/internal/models"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	jwtv4 "github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+)
+
+func TestAuthIntrospect(t *testing.T) {
+	token := signedToken(t, jwtv4.MapClaims{"user_id": "alice"})
+	tests := map[string]struct {
+		allowed []string
+		body    string
+		status  int
+		valid   bool
+	}{
+		// app.Test connections come from 0.0.0.0
+		"valid token":     {allowed: []string{"0.0.0.0"}, body: `{"token": "` + token + `"}`, status: 200, valid: true},
+		"invalid token":   {allowed: []string{"0.0.0.0"}, body: `{"token": "not-a-token"}`, status: 200},
+		"missing token":   {allowed: []string{"0.0.0.0"}, body: `{}`, status: 400},
+		"external caller": {allowed: []string{"10.0.0.0/8"}, body: `{"token": "` + token + `"}`, status: 403},
+		"not registered":  {body: `{"token": "` + token + `"}`, status: 404},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.JWT.SecretKey = "secret"
+			cfg.Server.InternalAllowedIPs = tt.allowed
+			app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+			SetupAuthRoutes(app, cfg, zap.NewNop(), auth.NewTokenValidator(cfg, zap.NewNop()))
+
+			req := httptest.NewRequest(fiber.MethodPost, "/auth/introspect", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != fiber.StatusOK {
+				return
+			}
+			var result models.TokenIntrospection
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Valid != tt.valid {
+				t.Errorf("valid = %v (%s), want %v", result.Valid, result.Reason, tt.valid)
+			}
+		})
+	}
+}
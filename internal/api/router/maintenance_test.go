@@ -0,0 +1,90 @@
+package router
+
+import (
+	"main/internal/api/middleware"
+	"main/internal/config"
+	"main/internal/gateway"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "secret"
+	cfg.APIKeys.Enabled = true
+	cfg.APIKeys.Keys = []config.APIKeyEntry{
+		{Key: "service-key", ClientID: "billing", Role: "service"},
+		{Key: "admin-key", ClientID: "ops", Role: "admin"},
+	}
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, MinRequests: 100, FailureRatio: 1}
+	cfg.Upstream.Services = []config.ServiceConfig{
+		{Name: "catalog", URL: newBackend(t, "catalog"), Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none"},
+	}
+	cfg.Upstream.Routes = []config.RouteConfig{
+		{Path: "/api/catalog", Service: "catalog", Auth: config.RouteAuthPublic},
+		{Path: "/api/orders", Service: "catalog", Auth: config.RouteAuthRequired},
+	}
+	proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := NewReloader(cfg, zap.NewNop())
+	maintenance := middleware.NewMaintenance(time.Minute)
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+	SetupAdminRoutes(app, reloader, zap.NewNop(), zap.NewAtomicLevel(), proxy, maintenance, nil, middleware.NewSlowRequests(0), nil)
+	SetupPublicRoutes(app, reloader, zap.NewNop(), proxy, nil, maintenance, nil)
+
+	toggle := func(key, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(fiber.MethodPost, "/admin/maintenance", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(middleware.APIKeyHeader, key)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	get := func(path string) int {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := toggle("service-key", `{"enabled": true}`); got != fiber.StatusForbidden {
+		t.Fatalf("non-admin toggle: %d, want 403", got)
+	}
+	if got := toggle("admin-key", `{}`); got != fiber.StatusBadRequest {
+		t.Fatalf("toggle without a value: %d, want 400", got)
+	}
+	if maintenance.Enabled() {
+		t.Fatal("maintenance turned on by a rejected request")
+	}
+
+	if got := toggle("admin-key", `{"enabled": true}`); got != fiber.StatusOK {
+		t.Fatalf("admin toggle: %d, want 200", got)
+	}
+	if got := get("/api/catalog"); got != fiber.StatusServiceUnavailable {
+		t.Errorf("public route in maintenance: %d, want 503", got)
+	}
+	// The notice comes before auth, so anonymous callers see it too
+	if got := get("/api/orders"); got != fiber.StatusServiceUnavailable {
+		t.Errorf("protected route in maintenance: %d, want 503 rather than 401", got)
+	}
+
+	// Admins can still turn it off
+	if got := toggle("admin-key", `{"enabled": false}`); got != fiber.StatusOK {
+		t.Fatalf("admin toggle off: %d, want 200", got)
+	}
+	if got := get("/api/catalog"); got != fiber.StatusOK {
+		t.Errorf("public route after maintenance: %d, want 200", got)
+	}
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"encoding/json"
+	"main/internal/api/middleware"
+	"main/internal/cache"
+	"main/internal/config"
+	"main/internal/gateway"
+	"main/internal/models"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+func TestMonitorRoutesInternalOnly(t *testing.T) {
+	tests := map[string]struct {
+		allowed []string
+		want    int
+	}{
+		// app.Test connections come from 0.0.0.0
+		"internal caller": {[]string{"0.0.0.0"}, fiber.StatusOK},
+		"external caller": {[]string{"10.0.0.0/8"}, fiber.StatusForbidden},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Server.InternalAllowedIPs = tt.allowed
+			cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Second, Timeout: time.Second, MinRequests: 1, FailureRatio: 1}
+			cfg.Upstream.Services = []config.ServiceConfig{{Name: "orders", URL: defaultUpstreamURL, Affinity: "none"}}
+			proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+			SetupMonitoringRoutes(app, cfg, zap.NewNop(), proxy, middleware.NewInFlightLimiter(0, 0), cache.NewMemoryCache(1))
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/monitor/routes", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthModes(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	// A port nothing listens on refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refusing := "http://" + listener.Addr().String()
+	listener.Close()
+
+	newApp := func(mode string, urls ...string) *fiber.App {
+		cfg := &config.Config{}
+		cfg.Health = config.HealthConfig{CheckInterval: time.Minute, CheckTimeout: time.Second, Mode: mode}
+		cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Second, Timeout: time.Second, MinRequests: 1, FailureRatio: 1}
+		for i, url := range urls {
+			cfg.Upstream.Services = append(cfg.Upstream.Services, config.ServiceConfig{Name: "svc" + strconv.Itoa(i), URL: url, Affinity: "none"})
+		}
+		proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+		checker := NewHealthChecker(cfg, zap.NewNop(), proxy)
+		checker.Start(t.Context())
+		SetupHealthRoutes(app, cfg, checker)
+		return app
+	}
+	// get waits for the background probes to settle, then returns the last answer
+	get := func(app *fiber.App, target string, want int) models.HealthCheckResponse {
+		t.Helper()
+		var body models.HealthCheckResponse
+		for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = models.HealthCheckResponse{}
+			json.NewDecoder(resp.Body).Decode(&body)
+			if resp.StatusCode == want {
+				return body
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("GET %s = %d %+v, want %d", target, resp.StatusCode, body, want)
+			}
+		}
+	}
+
+	// Light mode answers while the process is alive, whatever the upstreams
+	app := newApp(config.HealthModeLight, refusing)
+	if body := get(app, "/health", fiber.StatusOK); body.Status != "ok" || body.Dependencies != nil {
+		t.Errorf("light /health = %+v, want ok without dependencies", body)
+	}
+	get(app, "/health?mode=deep", fiber.StatusServiceUnavailable)
+	get(app, "/health?mode=full", fiber.StatusBadRequest)
+
+	// Deep mode needs one upstream up, and lists each
+	app = newApp(config.HealthModeDeep, backend.URL, refusing)
+	body := get(app, "/health", fiber.StatusOK)
+	if body.Dependencies["upstreams"].Status != "up" || len(body.Services) != 2 {
+		t.Errorf("deep /health = %+v, want the upstreams up and both listed", body)
+	}
+	get(app, "/health?mode=light", fiber.StatusOK)
+}
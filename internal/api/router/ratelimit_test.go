@@ -0,0 +1,77 @@
+package router
+
+import (
+	"main/internal/api/middleware"
+	"main/internal/config"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	jwtv4 "github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+)
+
+// newRateLimitedApp applies both rate limiting layers around a stand-in for
+// JWT validation that trusts the X-Test-User header
+func newRateLimitedApp(strategy string) *fiber.App {
+	cfg := &config.Config{}
+	cfg.RateLimit = config.RateLimitConfig{
+		Enabled: true, Strategy: strategy,
+		RequestsPerMinute: 60, BurstSize: 1,
+		UserRequestsPerMinute: 60, UserBurstSize: 2,
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+	SetupRateLimitingRoutes(app, NewReloader(cfg, zap.NewNop()), zap.NewNop())
+	app.Use(func(c *fiber.Ctx) error {
+		if user := c.Get("X-Test-User"); user != "" {
+			c.Locals("user", &jwtv4.Token{Claims: jwtv4.MapClaims{"user_id": user}})
+		}
+		return c.Next()
+	})
+	app.Use(userRateLimiter(cfg, zap.NewNop()))
+	app.Get("/items", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+func statusFor(t *testing.T, app *fiber.App, user string) int {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodGet, "/items", nil)
+	if user != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer token-of-"+user)
+		req.Header.Set("X-Test-User", user)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestRateLimitStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		// caller of each successive request, "" being anonymous
+		requests []string
+		want     []int
+	}{
+		// Everyone shares their IP's bucket
+		{"ip", []string{"", "alice"}, []int{200, 429}},
+		// Anonymous callers are limited by IP, users by their own buckets
+		{"user_or_ip", []string{"", "", "alice", "alice", "alice", "bob"}, []int{200, 429, 200, 200, 429, 200}},
+		// Only users are limited
+		{"user", []string{"", "", "", "alice", "alice", "alice"}, []int{200, 200, 200, 200, 200, 429}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			app := newRateLimitedApp(tt.strategy)
+			for i, user := range tt.requests {
+				if got := statusFor(t, app, user); got != tt.want[i] {
+					t.Fatalf("request %d by %q: status %d, want %d", i, user, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
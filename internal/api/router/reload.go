@@ -0,0 +1,137 @@
+package router
+
+import (
+	"fmt"
+	"main/internal/config"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// Reloader holds the configuration in effect and applies a new one to the
+// parts of the gateway that can change at runtime. Handlers read it through
+// Config, or are rebuilt as a whole, so a request never sees a half-applied
+// reload. With prefork every process reloads on its own.
+type Reloader struct {
+	current atomic.Pointer[config.Config]
+	log     *zap.Logger
+	// mu serializes reloads and guards hooks and last
+	mu    sync.Mutex
+	hooks []func(old, cfg *config.Config)
+	last  *ReloadInfo
+}
+
+// ReloadInfo describes a reload that was applied
+type ReloadInfo struct {
+	At time.Time `json:"at"`
+	// Trigger is what asked for the reload, such as "SIGHUP" or "admin"
+	Trigger string `json:"trigger"`
+	// Sources are where the configuration sections were loaded from
+	Sources map[string]string `json:"sources"`
+}
+
+func NewReloader(cfg *config.Config, log *zap.Logger) *Reloader {
+	r := &Reloader{log: log}
+	r.current.Store(cfg)
+	return r
+}
+
+// Config returns the configuration in effect
+func (r *Reloader) Config() *config.Config {
+	return r.current.Load()
+}
+
+// OnReload registers hook to run after each reload with the previous and the
+// new configuration
+func (r *Reloader) OnReload(hook func(old, cfg *config.Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// Handler returns a handler that runs the one build makes from the current
+// configuration. It is rebuilt on reload when the part section selects
+// changed, so stateful handlers such as rate limiters keep their state
+// across unrelated reloads.
+func (r *Reloader) Handler(section func(*config.Config) any, build func(*config.Config) fiber.Handler) fiber.Handler {
+	var current atomic.Pointer[fiber.Handler]
+	handler := build(r.Config())
+	current.Store(&handler)
+
+	r.OnReload(func(old, cfg *config.Config) {
+		if reflect.DeepEqual(section(old), section(cfg)) {
+			return
+		}
+		handler := build(cfg)
+		current.Store(&handler)
+	})
+	return func(c *fiber.Ctx) error {
+		return (*current.Load())(c)
+	}
+}
+
+// Reload re-reads the env file and the configuration, validates it and
+// applies it, recording trigger as what asked for it. It returns the changed
+// settings that need a restart, which keep their current values; an invalid
+// configuration changes nothing.
+func (r *Reloader) Reload(trigger string) ([]string, error) {
+	if err := config.ReloadEnvFile(); err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if malformed := cfg.MalformedEnv(); len(malformed) > 0 {
+		r.log.Warn("Ignoring malformed environment variables, their settings keep their defaults",
+			zap.Errors("errors", malformed))
+	}
+	restart := r.Apply(cfg)
+
+	r.mu.Lock()
+	r.last = &ReloadInfo{At: time.Now(), Trigger: trigger, Sources: cfg.Sources()}
+	r.mu.Unlock()
+	return restart, nil
+}
+
+// LastReload returns the last reload applied, or false when the
+// configuration hasn't been reloaded since startup
+func (r *Reloader) LastReload() (ReloadInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		return ReloadInfo{}, false
+	}
+	return *r.last, true
+}
+
+// Apply makes cfg the configuration in effect and returns the changed
+// settings that need a restart. Those of its upstream services keep their
+// current values in cfg, so what is served matches the proxy built at
+// startup.
+func (r *Reloader) Apply(cfg *config.Config) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.current.Load()
+	restart := old.RestartRequired(cfg)
+	cfg.Upstream.Services = old.ReloadServices(cfg)
+	r.current.Store(cfg)
+	for _, hook := range r.hooks {
+		hook(old, cfg)
+	}
+
+	if len(restart) > 0 {
+		r.log.Warn("Configuration reloaded, some changes need a restart", zap.Strings("settings", restart))
+	} else {
+		r.log.Info("Configuration reloaded")
+	}
+	return restart
+}
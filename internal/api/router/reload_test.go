@@ -0,0 +1,153 @@
+package router
+
+import (
+	"main/internal/api/middleware"
+	"main/internal/config"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// loadReloadConfig loads the configuration from the environment with IP rate
+// limiting allowing burst requests
+func loadReloadConfig(t *testing.T, burst string) *config.Config {
+	t.Helper()
+	t.Setenv("JWT_SECRET_KEY", "secret")
+	t.Setenv("RATE_LIMIT_ENABLED", "true")
+	t.Setenv("RATE_LIMIT_BURST_SIZE", burst)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func newReloadApp(reloader *Reloader) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+	SetupRateLimitingRoutes(app, reloader, zap.NewNop())
+	app.Get("/items", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+// admitted counts how many of n requests get through
+func admitted(t *testing.T, app *fiber.App, n int) int {
+	t.Helper()
+	var ok int
+	for range n {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/items", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == fiber.StatusOK {
+			ok++
+		}
+	}
+	return ok
+}
+
+func TestReloadAppliesRateLimits(t *testing.T) {
+	reloader := NewReloader(loadReloadConfig(t, "1"), zap.NewNop())
+	app := newReloadApp(reloader)
+	if got := admitted(t, app, 3); got != 1 {
+		t.Fatalf("%d requests admitted before the reload, want the burst of 1", got)
+	}
+
+	// Only the log level changed: the limiter and its state are kept
+	t.Setenv("LOG_LEVEL", "debug")
+	restart, err := reloader.Reload("test")
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(restart) != 0 {
+		t.Errorf("restart required for %v, want nothing", restart)
+	}
+	if got := admitted(t, app, 1); got != 0 {
+		t.Fatal("rate limiter reset by a reload that didn't change it")
+	}
+
+	// A larger burst takes effect with a fresh limiter
+	t.Setenv("RATE_LIMIT_BURST_SIZE", "3")
+	if _, err := reloader.Reload("test"); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := admitted(t, app, 5); got != 3 {
+		t.Errorf("%d requests admitted after the reload, want the new burst of 3", got)
+	}
+	if reloader.Config().RateLimit.BurstSize != 3 {
+		t.Errorf("Config() burst size %d, want 3", reloader.Config().RateLimit.BurstSize)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	cfg := loadReloadConfig(t, "1")
+	reloader := NewReloader(cfg, zap.NewNop())
+
+	t.Setenv("RATE_LIMIT_STRATEGY", "nobody")
+	if _, err := reloader.Reload("test"); err == nil {
+		t.Fatal("expected the invalid strategy to be rejected")
+	}
+	if reloader.Config() != cfg {
+		t.Error("an invalid configuration replaced the one in effect")
+	}
+	if _, ok := reloader.LastReload(); ok {
+		t.Error("a rejected reload was recorded as applied")
+	}
+}
+
+func TestReloadRecordsLastReload(t *testing.T) {
+	reloader := NewReloader(loadReloadConfig(t, "1"), zap.NewNop())
+	if _, ok := reloader.LastReload(); ok {
+		t.Fatal("last reload recorded before any reload")
+	}
+
+	before := time.Now()
+	if _, err := reloader.Reload("SIGHUP"); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	last, ok := reloader.LastReload()
+	if !ok || last.Trigger != "SIGHUP" || last.At.Before(before) || last.Sources["default"] != "env" {
+		t.Errorf("last reload = %+v, %v", last, ok)
+	}
+}
+
+func TestReloadReportsStartupSettings(t *testing.T) {
+	reloader := NewReloader(loadReloadConfig(t, "1"), zap.NewNop())
+
+	t.Setenv("SERVER_PORT", "9090")
+	restart, err := reloader.Reload("test")
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !slices.Equal(restart, []string{"server.port"}) {
+		t.Errorf("restart required for %v, want [server.port]", restart)
+	}
+}
+
+func TestReloadKeepsStartupServiceSettings(t *testing.T) {
+	t.Setenv("UPSTREAM_SERVICE_COUNT", "1")
+	t.Setenv("UPSTREAM_SERVICE_0_NAME", "orders")
+	t.Setenv("UPSTREAM_SERVICE_0_URL", "http://orders:3000")
+	t.Setenv("UPSTREAM_SERVICE_0_TIMEOUT", "10")
+	reloader := NewReloader(loadReloadConfig(t, "1"), zap.NewNop())
+
+	// The proxy was built for the old URL; the timeout is read per request
+	t.Setenv("UPSTREAM_SERVICE_0_URL", "http://orders-v2:3000")
+	t.Setenv("UPSTREAM_SERVICE_0_TIMEOUT", "5")
+	restart, err := reloader.Reload("test")
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !slices.Equal(restart, []string{"upstream.services"}) {
+		t.Errorf("restart required for %v, want [upstream.services]", restart)
+	}
+	service := reloader.Config().Upstream.Services[0]
+	if service.URL != "http://orders:3000" || service.Timeout != 5*time.Second {
+		t.Errorf("service after reload = %s with timeout %v, want the old URL and the new 5s timeout", service.URL, service.Timeout)
+	}
+}
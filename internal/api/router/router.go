@@ -1,151 +1,953 @@
 package router
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"main/internal/api/handler"
+	"main/internal/api/middleware"
+	"main/internal/audit"
 	"main/internal/auth"
+	"main/internal/cache"
 	"main/internal/config"
+	"main/internal/gateway"
+	"main/internal/health"
+	"main/internal/loggers"
+	"main/internal/metrics"
+	"main/internal/models"
+	"main/internal/redact"
+	"main/internal/tracing"
+	"main/internal/version"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/pprof"
+	"github.com/gofiber/fiber/v2/utils"
 	jwtware "github.com/gofiber/jwt/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
 )
 
-// SetupRouter initializes the main router with all routes
-func SetupRouter(app *fiber.App, cfg *config.Config, log *zap.Logger, validator *auth.TokenValidator) {
+// AppConfig returns the Fiber settings for cfg: the server's timeouts and
+// limits, prefork in production and errors rendered as models.ErrorResponse
+func AppConfig(cfg *config.Config) fiber.Config {
+	return fiber.Config{
+		AppName:          "JanusCopy Gateway",
+		Prefork:          cfg.Environment == "production",
+		ReadTimeout:      cfg.Server.ReadTimeout,
+		WriteTimeout:     cfg.Server.WriteTimeout,
+		IdleTimeout:      cfg.Server.IdleTimeout,
+		BodyLimit:        cfg.Server.BodyLimit,
+		ReadBufferSize:   cfg.Server.ReadBufferSize,
+		DisableKeepalive: cfg.Server.DisableKeepalive,
+		ErrorHandler:     middleware.ErrorHandlerFiber,
+	}
+}
+
+// SetupRouter initializes the main router with all routes. Settings read
+// through reloader apply on reload; the rest are fixed at startup.
+func SetupRouter(app *fiber.App, reloader *Reloader, log *zap.Logger, logLevel zap.AtomicLevel, logStream *loggers.Broadcaster, validator *auth.TokenValidator, proxy *gateway.Proxy, checker *health.Checker, auditLog *audit.Log) {
+	cfg := reloader.Config()
+
+	// A reload sets the configured log level, unless only other settings changed
+	reloader.OnReload(func(old, cfg *config.Config) {
+		if cfg.Logging.Level == old.Logging.Level {
+			return
+		}
+		level, err := loggers.ParseLevel(cfg.Logging.Level)
+		if err != nil {
+			log.Warn("Log level not reloaded", zap.Error(err))
+			return
+		}
+		logLevel.SetLevel(level)
+	})
+	// Statically configured instances are updated in place; discovery owns them otherwise
+	reloader.OnReload(func(old, cfg *config.Config) {
+		if cfg.Discovery.Provider != config.DiscoveryStatic {
+			return
+		}
+		var services []config.ServiceConfig
+		for _, service := range cfg.Upstream.Services {
+			if len(service.Instances) > 0 {
+				services = append(services, service)
+			}
+		}
+		proxy.Reload(services)
+	})
+
+	// Tracks (and optionally caps) concurrent requests
+	inFlight := middleware.NewInFlightLimiter(cfg.Server.MaxInFlight, cfg.Server.InFlightQueueTimeout)
+
+	// Slowest requests of the last hour, listed by the admin endpoints
+	slowRequests := middleware.NewSlowRequests(cfg.Logging.SlowRequestsKept)
+
 	// Essential middleware (always enabled)
-	SetupCoreMiddleware(app, cfg, log, validator)
+	SetupCoreMiddleware(app, reloader, log, validator, inFlight, slowRequests)
+
+	// Shared by the caching middleware and the monitoring endpoints; nil when disabled
+	responseCache := newResponseCache(cfg)
+
+	// IP rate limiting must be registered before the catch-all proxy route.
+	// Per-user limits are applied in SetupPublicRoutes, after JWT validation.
+	SetupRateLimitingRoutes(app, reloader, log)
+
+	// Liveness and readiness probes, public like monitoring
+	SetupHealthRoutes(app, cfg, checker)
+
+	// Monitoring is public and must not fall through to the proxy catch-all
+	SetupMonitoringRoutes(app, cfg, log, proxy, inFlight, responseCache)
+
+	// Token introspection for support, reachable from internal networks only
+	SetupAuthRoutes(app, cfg, log, validator)
+
+	// The live log tail authenticates by admin JWT, so it is registered ahead
+	// of the API key protected admin group
+	SetupLogStreamRoutes(app, cfg, logStream)
+
+	// Admin endpoints authenticate by API key, not JWT
+	maintenance := middleware.NewMaintenance(cfg.Server.MaintenanceRetryAfter)
+	SetupAdminRoutes(app, reloader, log, logLevel, proxy, maintenance, responseCache, slowRequests, auditLog)
+
+	// Profiling, for admin API keys from internal IPs
+	SetupDebugRoutes(app, cfg, log)
+
+	// Prometheus metrics are public like monitoring
+	SetupMetricsRoutes(app, cfg, log, responseCache)
+
+	// Merged OpenAPI document of the upstream services, public like monitoring
+	SetupOpenAPIRoutes(app, cfg, log, proxy)
+
+	// Error responses on audited routes (e.g. payments) go to the audit log
+	if auditLog != nil {
+		for _, prefix := range cfg.Audit.Paths {
+			app.Use(prefix, middleware.AuditResponsesFiber(auditLog, audit.EventRouteError, fiber.StatusBadRequest))
+		}
+	}
 
 	// Core routes - forward to NestJS backend
-	SetupPublicRoutes(app, cfg, log)
+	SetupPublicRoutes(app, reloader, log, proxy, responseCache, maintenance, auditLog)
 
 	// Optional feature routes - add only what you need
-	// setupRateLimitingRoutes(app, cfg, log)
 	// setupCircuitBreakerRoutes(app, cfg, log)
-	// setupCachingRoutes(app, cfg, log)
-	// setupMonitoringRoutes(app, cfg, log)
-	// setupMetricsRoutes(app, cfg, log)
 }
 
 // ============================================================================
 // CORE - Always enabled (JWT, CORS, Logging)
 // ============================================================================
 
-func SetupCoreMiddleware(app *fiber.App, cfg *config.Config, log *zap.Logger, validator *auth.TokenValidator) {
+func SetupCoreMiddleware(app *fiber.App, reloader *Reloader, log *zap.Logger, validator *auth.TokenValidator, inFlight *middleware.InFlightLimiter, slowRequests *middleware.SlowRequests) {
+	cfg := reloader.Config()
+
 	// Recovery from panics
-	app.Use(func(c *fiber.Ctx) error {
+	app.Use(func(c *fiber.Ctx) (err error) {
 		defer func() {
-			if err := recover(); err != nil {
-				log.Error("Panic recovered", zap.Any("error", err))
-				c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "internal server error",
-				})
+			if r := recover(); r != nil {
+				middleware.RequestLogger(c, log).Error("Panic recovered", zap.Any("error", r))
+				err = middleware.NewError(fiber.StatusInternalServerError, models.ErrCodeInternal, "internal server error")
 			}
 		}()
 		return c.Next()
 	})
 
-	// Request logging
-	app.Use(func(c *fiber.Ctx) error {
-		log.Info("Request received",
-			zap.String("method", c.Method()),
-			zap.String("path", c.Path()),
-			zap.String("ip", c.IP()),
-		)
-		return c.Next()
-	})
+	// Request IDs are echoed in X-Request-ID, error responses, logs and upstream requests
+	app.Use(middleware.RequestIDFiber(log))
+
+	// A server span per request; no-op unless an OTLP endpoint is configured
+	app.Use(middleware.TracingFiber())
+
+	// Resolve the real client IP before anything logs or limits on it
+	resolver, err := middleware.NewClientIPResolver(cfg.Server.TrustedProxies, cfg.Server.ProxyHeaders)
+	if err != nil {
+		log.Fatal("Invalid trusted proxy", zap.Error(err))
+	}
+	app.Use(middleware.ClientIPFiber(resolver))
 
-	// CORS - Allow Angular on :4200
-	app.Use(func(c *fiber.Ctx) error {
-		origin := c.Get("Origin")
-		c.Set("Access-Control-Allow-Origin", origin)
-		c.Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,PATCH,OPTIONS")
-		c.Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
-		c.Set("Access-Control-Allow-Credentials", "true")
+	// Request metrics; renders errors so the recorded status is final
+	app.Use(middleware.MetricsFiber(middleware.NewRouteLabels(cfg.Metrics.MaxUnmatchedRoutes)))
 
-		if c.Method() == fiber.MethodOptions {
-			return c.SendStatus(fiber.StatusOK)
+	// Access log, written once the request has completed
+	if cfg.Logging.AccessLogEnabled {
+		accessLog, err := middleware.AccessLogFiber(cfg.Logging, log)
+		if err != nil {
+			log.Fatal("Invalid access log config", zap.Error(err))
 		}
+		app.Use(accessLog)
+	}
 
-		return c.Next()
-	})
+	// Reject abusive header sets before any other work copies them
+	if cfg.Server.MaxHeaderBytes > 0 || cfg.Server.MaxHeaderCount > 0 {
+		app.Use(middleware.HeaderLimitFiber(cfg.Server.MaxHeaderBytes, cfg.Server.MaxHeaderCount))
+	}
+
+	// Slow requests are logged with a timing breakdown and kept for /admin/slow-requests
+	if cfg.Logging.SlowRequestThreshold > 0 {
+		app.Use(middleware.SlowRequestFiber(cfg.Logging.SlowRequestThreshold, slowRequests, log))
+	}
+
+	// Edge timeout covering auth, cache and the upstream call
+	if cfg.Server.RequestTimeout > 0 {
+		app.Use(middleware.TimeoutMiddleware(cfg.Server.RequestTimeout))
+	}
+
+	// Load shedding - keep health probes up so the load balancer doesn't eject us
+	app.Use(middleware.LoadShedFiber(inFlight, "/health", "/healthz", "/readyz"))
+
+	// Body logging (opt-in, only for configured routes)
+	if cfg.Logging.BodyLogEnabled {
+		app.Use(middleware.BodyLoggerFiber(cfg.Logging, log))
+	}
+
+	// CORS for the configured origins; answers preflights before auth
+	app.Use(reloader.Handler(
+		func(cfg *config.Config) any { return cfg.CORS },
+		func(cfg *config.Config) fiber.Handler { return middleware.CORSFiber(cfg.CORS) },
+	))
+
+	// Security headers, after CORS so preflights are answered without them
+	app.Use(reloader.Handler(
+		func(cfg *config.Config) any { return cfg.Server.SecurityHeaders },
+		func(cfg *config.Config) fiber.Handler {
+			if !cfg.Server.SecurityHeaders.Enabled {
+				return next
+			}
+			return middleware.SecurityHeadersFiber(cfg.Server.SecurityHeaders)
+		},
+	))
 }
 
 // ============================================================================
 // CORE ROUTES - Forward to NestJS Backend (:3000)
 // ============================================================================
 
-func SetupPublicRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger) {
-	nestjsURL := "http://localhost:3000"
+// defaultUpstreamURL receives every request matched by the catch-all route
+const defaultUpstreamURL = "http://localhost:3000"
 
-	// Health check (no auth required - public)
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"status": "ok", "gateway": "running"})
-	})
+// matchRoute records the configured route each request matches. Paths a
+// route covers, but not for the request's method, get 405 once the caller
+// is authenticated.
+func matchRoute(routes *gateway.RouteTable) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		route, allowed := routes.Match(c.Method(), c.Path())
+		middleware.SetRoute(c, route, allowed)
+		return c.Next()
+	}
+}
+
+func SetupPublicRoutes(app *fiber.App, reloader *Reloader, log *zap.Logger, proxy *gateway.Proxy, responseCache cache.Cache, maintenance *middleware.Maintenance, auditLog *audit.Log) {
+	cfg := reloader.Config()
 
-	// Protected routes - require JWT
+	// Protected routes - require JWT or, when enabled, an API key, unless the
+	// route table makes the route optional or public
 	protected := app.Group("")
+	// Checked before auth so clients get the maintenance notice rather than a 401
+	protected.Use(middleware.MaintenanceFiber(maintenance))
+	// The matched route decides the auth and policies applied below, so
+	// reloaded routes change them too
+	protected.Use(reloader.Handler(
+		func(cfg *config.Config) any { return cfg.Upstream.Routes },
+		func(cfg *config.Config) fiber.Handler { return matchRoute(gateway.NewRouteTable(cfg.Upstream.Routes)) },
+	))
+	protected.Use(middleware.StartPhase(middleware.PhaseAuth))
+	if auditLog != nil {
+		protected.Use(middleware.AuditAuthFiber(auditLog))
+	}
+	if cfg.APIKeys.Enabled {
+		protected.Use(middleware.APIKeyFiber(auth.NewAPIKeyValidator(cfg, log), log))
+	}
 	protected.Use(jwtware.New(jwtware.Config{
-		SigningKey: []byte(cfg.JWT.SecretKey),
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
+		// Requests already authenticated by API key, or let in anonymously by
+		// their route, don't need a JWT
+		Filter: func(c *fiber.Ctx) bool {
+			return middleware.HasAPIKeyIdentity(c) || middleware.SkipAuth(c)
 		},
+		SigningKey:   []byte(cfg.JWT.SecretKey),
+		ErrorHandler: middleware.JWTErrorHandler,
 		SuccessHandler: func(c *fiber.Ctx) error {
 			return c.Next()
 		},
 	}))
+	// The token's claims reach the backend in the configured headers
+	protected.Use(middleware.ValidateTokenFiber(cfg.JWT.ClaimHeaders, log))
+	protected.Use(middleware.EndPhase(middleware.PhaseAuth))
+	// Roles and scopes are checked before anything is served, cached or not.
+	// They are read from the route matched above.
+	protected.Use(middleware.RouteAccessFiber())
 
-	// Catch-all route - forward everything to NestJS (protected)
-	protected.All("/*", func(c *fiber.Ctx) error {
-		path := c.Path()
-		return ForwardRequest(c, nestjsURL, path, log)
+	// Tenants are routed to the services scoped to them
+	if cfg.Tenancy.Enabled {
+		known := make(map[string]bool)
+		for _, svc := range cfg.Upstream.Services {
+			if svc.Tenant != "" {
+				known[svc.Tenant] = true
+			}
+		}
+		protected.Use(middleware.TenantFiber(cfg.Tenancy, known))
+	}
+
+	// Per-user rate limiting needs the claims set by the JWT middleware above
+	protected.Use(reloader.Handler(
+		func(cfg *config.Config) any { return []any{cfg.RateLimit, cfg.Cache.Redis} },
+		func(cfg *config.Config) fiber.Handler { return userRateLimiter(cfg, log) },
+	))
+	protected.Use(reloader.Handler(
+		func(cfg *config.Config) any { return []any{cfg.Upstream.Routes, cfg.RateLimit, cfg.Cache.Redis} },
+		func(cfg *config.Config) fiber.Handler { return routeRateLimiter(cfg, log) },
+	))
+
+	// Keyed POSTs and PATCHes are answered once per caller and key
+	if cfg.Idempotency.Enabled {
+		protected.Use(middleware.IdempotencyFiber(newIdempotencyStore(cfg), cfg.Idempotency, log))
+	}
+
+	// Caching sits behind auth so cached responses are never served to unauthenticated clients
+	if responseCache != nil {
+		SetupCachingRoutes(protected, responseCache, reloader, log)
+	}
+
+	// Catch-all route - forward routed requests to their service, everything
+	// else to NestJS (protected). Reloaded services take effect here, and
+	// the upstream settings are read from the configuration in effect.
+	protected.All("/*", reloader.Handler(
+		func(cfg *config.Config) any { return cfg.Upstream.Services },
+		func(cfg *config.Config) fiber.Handler {
+			services := make(map[string]config.ServiceConfig)
+			tenantServices := make(map[string]config.ServiceConfig)
+			for _, svc := range cfg.Upstream.Services {
+				services[svc.Name] = svc
+				if svc.Tenant != "" {
+					tenantServices[svc.Tenant] = svc
+				}
+			}
+			service := lookupService(cfg, defaultUpstreamURL)
+
+			return func(c *fiber.Ctx) error {
+				path := c.Path()
+				cfg := reloader.Config()
+				if route := middleware.RouteFromLocals(c); route != nil {
+					routed, ok := services[route.Service]
+					if !ok {
+						// A reloaded route naming a service that only exists after a restart
+						middleware.RequestLogger(c, log).Error("Route names a service not running",
+							zap.String("route", route.Path),
+							zap.String("service", route.Service),
+						)
+						return middleware.NewError(fiber.StatusServiceUnavailable, models.ErrCodeServiceUnavailable, "service unavailable").
+							WithDetails(fiber.Map{"service": route.Service})
+					}
+					if route.Timeout > 0 {
+						routed.Timeout = route.Timeout
+					}
+					if route.MaxResponseBytes != 0 {
+						routed.MaxResponseBytes = route.MaxResponseBytes
+					}
+					if route.Retry != nil {
+						routed.Retry = route.Retry
+					}
+					return ForwardRequest(c, cfg, proxy, routed, path, log)
+				}
+				if tenant := middleware.TenantFromLocals(c); tenant != "" {
+					return ForwardRequest(c, cfg, proxy, tenantServices[tenant], path, log)
+				}
+				return ForwardRequest(c, cfg, proxy, service, path, log)
+			}
+		},
+	))
+}
+
+// SetupAdminRoutes adds internal operational endpoints, available to admin API keys only
+func SetupAdminRoutes(app *fiber.App, reloader *Reloader, log *zap.Logger, logLevel zap.AtomicLevel, proxy *gateway.Proxy, maintenance *middleware.Maintenance, responseCache cache.Cache, slowRequests *middleware.SlowRequests, auditLog *audit.Log) {
+	cfg := reloader.Config()
+	if !cfg.APIKeys.Enabled {
+		log.Info("API keys disabled, admin endpoints not registered")
+		return
+	}
+
+	handlers := []fiber.Handler{
+		middleware.APIKeyFiber(auth.NewAPIKeyValidator(cfg, log), log),
+		middleware.RequireAPIKeyRole("admin"),
+	}
+	// Rejected callers are audited as auth failures, admins as admin requests
+	if auditLog != nil {
+		handlers = append([]fiber.Handler{middleware.AuditAuthFiber(auditLog)}, handlers...)
+		handlers = append(handlers, middleware.AuditResponsesFiber(auditLog, audit.EventAdminRequest, 0))
+	}
+	admin := app.Group("/admin", handlers...)
+
+	// Toggle maintenance mode: {"enabled": true|false}
+	admin.Post("/maintenance", func(c *fiber.Ctx) error {
+		var body struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Enabled == nil {
+			return fiber.NewError(fiber.StatusBadRequest, `expected {"enabled": true|false}`)
+		}
+
+		maintenance.SetEnabled(*body.Enabled)
+		middleware.RequestLogger(c, log).Warn("Maintenance mode changed",
+			zap.Bool("enabled", *body.Enabled),
+			zap.String("client_id", middleware.UserIDFromLocals(c)),
+		)
+
+		return c.JSON(fiber.Map{"maintenance": maintenance.Enabled()})
+	})
+
+	// Read or change the log level with zap's handler: {"level": "debug"}.
+	// ?duration=10m reverts the change after that long.
+	levelHandler := adaptor.HTTPHandler(logLevel)
+	reverter := loggers.NewLevelReverter(logLevel, log)
+	admin.Get("/loglevel", levelHandler)
+	admin.Put("/loglevel", func(c *fiber.Ctx) error {
+		var duration time.Duration
+		if value := c.Query("duration"); value != "" {
+			var err error
+			if duration, err = time.ParseDuration(value); err != nil || duration <= 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "duration must be a positive duration such as 10m")
+			}
+		}
+
+		previous := logLevel.Level()
+		if err := levelHandler(c); err != nil || c.Response().StatusCode() != fiber.StatusOK {
+			return err
+		}
+
+		reverter.Changed(previous, duration)
+		middleware.RequestLogger(c, log).Info("Log level changed",
+			zap.Stringer("from", previous),
+			zap.Stringer("to", logLevel.Level()),
+			zap.Duration("duration", duration),
+			zap.String("client_id", middleware.UserIDFromLocals(c)),
+		)
+		return nil
+	})
+
+	// Re-read the configuration file and environment, as SIGHUP does
+	admin.Post("/reload", func(c *fiber.Ctx) error {
+		log := middleware.RequestLogger(c, log)
+		restart, err := reloader.Reload("admin")
+		if err != nil {
+			log.Error("Configuration reload failed", zap.Error(err))
+			return middleware.NewError(fiber.StatusUnprocessableEntity, models.ErrCodeInvalidConfig, "configuration not reloaded").
+				WithDetails(fiber.Map{"errors": strings.Split(err.Error(), "\n")})
+		}
+		log.Info("Configuration reload requested", zap.String("client_id", middleware.UserIDFromLocals(c)))
+		return c.JSON(fiber.Map{"reloaded": true, "restart_required": restart})
+	})
+
+	// Endpoints that map out the deployment or steer upstream traffic are for
+	// internal IPs only
+	if len(cfg.Server.InternalAllowedIPs) == 0 {
+		log.Info("No internal IPs configured, /admin/config and /admin/breaker not registered")
+	} else {
+		internalOnly, err := middleware.IPAllowlistFiber(cfg.Server.InternalAllowedIPs)
+		if err != nil {
+			log.Fatal("Invalid internal IP allowlist", zap.Error(err))
+		}
+
+		// The configuration in effect, merged from its files and the
+		// environment, with secrets fingerprinted, and the last reload
+		admin.Get("/config", internalOnly, func(c *fiber.Ctx) error {
+			response := fiber.Map{"config": json.RawMessage(reloader.Config().String()), "last_reload": nil}
+			if last, ok := reloader.LastReload(); ok {
+				response["last_reload"] = last
+			}
+			return c.JSON(response)
+		})
+
+		// Force a service's circuit open or closed until reset, or reset it:
+		// {"action": "open"|"close"|"reset"}
+		admin.Post("/breaker/:service", internalOnly, func(c *fiber.Ctx) error {
+			var body struct {
+				Action string `json:"action"`
+			}
+			if err := c.BodyParser(&body); err != nil ||
+				(body.Action != gateway.BreakerOpen && body.Action != gateway.BreakerClose && body.Action != gateway.BreakerReset) {
+				return middleware.NewError(fiber.StatusBadRequest, models.ErrCodeBadRequest, `expected {"action": "open"|"close"|"reset"}`)
+			}
+
+			service := c.Params("service")
+			state, err := proxy.OverrideBreaker(service, body.Action)
+			if err != nil {
+				return middleware.NewError(fiber.StatusNotFound, models.ErrCodeNotFound, "unknown service").
+					WithDetails(fiber.Map{"service": service})
+			}
+			middleware.RequestLogger(c, log).Warn("Circuit breaker manually overridden",
+				zap.String("service", service),
+				zap.String("action", body.Action),
+				zap.String("state", state.String()),
+				zap.String("client_id", middleware.UserIDFromLocals(c)),
+			)
+			return c.JSON(fiber.Map{"service": service, "action": body.Action, "state": state.String()})
+		})
+	}
+
+	if responseCache == nil {
+		return
+	}
+
+	// Purge cached responses: ?key= one entry, ?prefix= a path prefix, ?all=true everything
+	admin.Delete("/cache", func(c *fiber.Ctx) error {
+		log := middleware.RequestLogger(c, log)
+		var purged int
+		var err error
+
+		switch {
+		case c.QueryBool("all"):
+			purged, err = responseCache.Flush(c.Context())
+		case c.Query("key") != "":
+			var removed bool
+			removed, err = responseCache.Delete(c.Context(), c.Query("key"))
+			if removed {
+				purged = 1
+			}
+		case c.Query("prefix") != "":
+			purged, err = middleware.PurgePath(c.Context(), responseCache, c.Query("prefix"))
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, "one of key, prefix or all=true is required")
+		}
+
+		if err != nil {
+			log.Error("Cache purge failed", zap.Error(err))
+			return middleware.NewError(fiber.StatusServiceUnavailable, models.ErrCodeCacheUnavailable, "cache purge failed").
+				WithDetails(fiber.Map{"purged": purged})
+		}
+
+		log.Info("Cache purged",
+			zap.String("client_id", middleware.UserIDFromLocals(c)),
+			zap.String("query", string(c.Request().URI().QueryString())),
+			zap.Int("purged", purged),
+		)
+		return c.JSON(fiber.Map{"purged": purged})
+	})
+
+	admin.Get("/cache/stats", func(c *fiber.Ctx) error {
+		stats, err := middleware.CacheStats(c.Context(), responseCache, cfg.Cache.Backend)
+		if err != nil {
+			middleware.RequestLogger(c, log).Warn("Cache backend stats unavailable", zap.Error(err))
+		}
+		return c.JSON(stats)
+	})
+
+	// List cached keys: ?prefix= filters, ?cursor= continues from next_cursor, ?limit= caps the page
+	admin.Get("/cache/keys", func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", 100)
+		if limit < 1 || limit > maxCacheKeysPage {
+			limit = maxCacheKeysPage
+		}
+
+		keys, err := middleware.CacheKeys(c.Context(), responseCache, c.Query("prefix"), c.Query("cursor"), limit)
+		if err != nil {
+			middleware.RequestLogger(c, log).Error("Cache key listing failed", zap.Error(err))
+			return middleware.NewError(fiber.StatusServiceUnavailable, models.ErrCodeCacheUnavailable, "cache key listing failed")
+		}
+		return c.JSON(keys)
+	})
+
+	// Audit log: ?user_id=, ?from= and ?to= (RFC 3339) filter, ?cursor= continues
+	// from next_cursor, ?limit= caps the page
+	if auditLog != nil {
+		admin.Get("/audit", func(c *fiber.Ctx) error {
+			query := audit.Query{
+				UserID: c.Query("user_id"),
+				Limit:  c.QueryInt("limit", 100),
+				Cursor: c.Query("cursor"),
+			}
+			if query.Limit < 1 || query.Limit > maxAuditPage {
+				query.Limit = maxAuditPage
+			}
+			for param, t := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+				if value := c.Query(param); value != "" {
+					parsed, err := time.Parse(time.RFC3339, value)
+					if err != nil {
+						return middleware.NewError(fiber.StatusBadRequest, models.ErrCodeBadRequest, param+" must be an RFC 3339 time")
+					}
+					*t = parsed
+				}
+			}
+
+			page, err := auditLog.Find(c.UserContext(), query)
+			if err != nil {
+				middleware.RequestLogger(c, log).Error("Audit log query failed", zap.Error(err))
+				return middleware.NewError(fiber.StatusServiceUnavailable, models.ErrCodeServiceUnavailable, "audit log unavailable")
+			}
+			return c.JSON(page)
+		})
+	}
+
+	// Slowest requests of the last hour, slowest first
+	admin.Get("/slow-requests", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"threshold_ms": cfg.Logging.SlowRequestThreshold.Milliseconds(),
+			"requests":     slowRequests.Slowest(),
+		})
 	})
 }
 
+// maxCacheKeysPage bounds one page of /admin/cache/keys
+const maxCacheKeysPage = 1000
+
+// maxAuditPage bounds one page of /admin/audit
+const maxAuditPage = 1000
+
 // ============================================================================
 // HELPER FUNCTION - Forward requests to NestJS backend
 // ============================================================================
 
-func ForwardRequest(c *fiber.Ctx, backendURL string, path string, log *zap.Logger) error {
-	// Create new request to NestJS backend
-	req, err := http.NewRequest(c.Method(), backendURL+path, bytes.NewReader(c.Body()))
+// lookupService returns the configured service for url, or a bare service without
+// a timeout. Services scoped to a tenant are only reached by that tenant.
+func lookupService(cfg *config.Config, url string) config.ServiceConfig {
+	for _, svc := range cfg.Upstream.Services {
+		if svc.URL == url && svc.Tenant == "" {
+			return svc
+		}
+	}
+	return config.ServiceConfig{URL: url}
+}
+
+// requestBudget returns the time the caller left for the upstream call: any
+// budget received in header minus the time already spent in the gateway,
+// capped by the edge timeout. limited is false when neither sets a deadline.
+// The service's own timeout is applied separately.
+func requestBudget(c *fiber.Ctx, header string) (budget time.Duration, limited bool) {
+	if ms, err := strconv.Atoi(c.Get(header)); header != "" && err == nil && ms >= 0 {
+		budget, limited = time.Duration(ms)*time.Millisecond-time.Since(c.Context().Time()), true
+	}
+	if deadline, ok := c.UserContext().Deadline(); ok {
+		if left := time.Until(deadline); !limited || left < budget {
+			budget, limited = left, true
+		}
+	}
+	return budget, limited
+}
+
+// errServiceTimeout is the cause of a context ended by the service's own
+// timeout, as opposed to the caller's deadline
+var errServiceTimeout = errors.New("service timeout")
+
+func ForwardRequest(c *fiber.Ctx, cfg *config.Config, proxy *gateway.Proxy, service config.ServiceConfig, path string, log *zap.Logger) error {
+	log = middleware.RequestLogger(c, log)
+	serviceName := upstreamName(service)
+	middleware.SetUpstreamService(c, serviceName)
+	if pattern, ok := gateway.RoutePattern(service, path); ok {
+		middleware.SetRoutePattern(c, pattern)
+	} else {
+		middleware.SetRouteUnmatched(c)
+	}
+	// Methods the service doesn't take never reach it
+	if len(service.AllowedMethods) > 0 && !slices.Contains(service.AllowedMethods, c.Method()) {
+		c.Set(fiber.HeaderAllow, strings.Join(service.AllowedMethods, ", "))
+		return middleware.NewError(fiber.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "method not allowed").
+			WithDetails(fiber.Map{"allowed": service.AllowedMethods})
+	}
+	deadlineHeader := cfg.Upstream.DeadlineHeader
+	ctx := c.UserContext()
+	cancel := context.CancelFunc(func() {})
+	budget, limited := requestBudget(c, deadlineHeader)
+	if limited && budget <= 0 {
+		log.Warn("Request budget exhausted", zap.String("path", path))
+		return middleware.NewError(fiber.StatusGatewayTimeout, models.ErrCodeDeadlineExceeded, "request deadline exceeded")
+	}
+	// The shorter of the caller's budget and the service timeout bounds the call.
+	// The service timeout ends the context with its own cause, so running out of
+	// it is reported as the backend timing out.
+	if timeout := service.Timeout; timeout > 0 && (!limited || timeout < budget) {
+		budget, limited = timeout, true
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, errServiceTimeout)
+	} else if limited {
+		ctx, cancel = context.WithTimeout(ctx, budget)
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "upstream "+serviceName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gateway.service", serviceName),
+			attribute.String("http.request.method", c.Method()),
+		),
+	)
+	// A streamed body outlives this handler and cleans up when it is closed
+	streaming := false
+	defer func() {
+		if !streaming {
+			span.End()
+			cancel()
+		}
+	}()
+
+	// The service's transformer rewrites JSON bodies on the way in and out
+	body := c.Body()
+	transformer := proxy.Transformer(service.Name)
+	if transformer != nil && len(body) > 0 && gateway.IsJSON(c.Get(fiber.HeaderContentType)) {
+		transformed, err := transformer.TransformRequest(body)
+		if err != nil {
+			log.Warn("Request body transform failed", zap.String("service", service.Name), zap.Error(err))
+			return middleware.NewError(fiber.StatusBadGateway, models.ErrCodeTransformFailed, "request transform failed")
+		}
+		body = transformed
+	}
+
+	// Create new request to the NestJS instance picked for this client
+	instance := pickInstance(c, proxy, service)
+	req, err := http.NewRequestWithContext(ctx, c.Method(), instance+proxy.UpstreamPath(service.Name, path), bytes.NewReader(body))
 	if err != nil {
 		log.Error("Failed to create request", zap.Error(err), zap.String("path", path))
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "gateway error",
-		})
+		return middleware.NewError(fiber.StatusInternalServerError, models.ErrCodeInternal, "gateway error")
 	}
 
 	// Copy headers from original request (fasthttp style)
 	c.Request().Header.VisitAll(func(key, value []byte) {
 		req.Header.Add(string(key), string(value))
 	})
+	// A transformer needs plain response bodies: the transport asks for gzip
+	// itself and decompresses
+	if transformer != nil {
+		req.Header.Del(fiber.HeaderAcceptEncoding)
+	}
+	// The service's own credentials replace whatever the caller sent
+	if service.BasicAuth != nil {
+		req.Header.Del(fiber.HeaderAuthorization)
+		req.SetBasicAuth(service.BasicAuth.Username, service.BasicAuth.Password)
+	}
+
+	// Let the backend log under the same request ID and continue the trace
+	req.Header.Set(fiber.HeaderXRequestID, middleware.RequestID(c))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	span.SetAttributes(attribute.String("url.full", req.URL.String()))
+
+	// Forwarding headers: extend the chain only when the peer is a trusted proxy
+	req.Header.Set(fiber.HeaderXForwardedFor, middleware.ForwardedFor(c))
+	req.Header.Set("X-Real-IP", middleware.ClientIP(c))
+
+	// Tell the backend how much time it has left, replacing any caller value
+	if deadlineHeader != "" {
+		req.Header.Del(deadlineHeader)
+		if limited {
+			req.Header.Set(deadlineHeader, strconv.FormatInt(budget.Milliseconds(), 10))
+		}
+	}
 
 	// Add query parameters
 	if len(c.Request().URI().QueryString()) > 0 {
-		req.URL.RawQuery = string(c.Request().URI().QueryString())
+		req.URL.RawQuery = proxy.UpstreamQuery(service.Name, string(c.Request().URI().QueryString()))
+	}
+	// Respect the service's concurrency limit
+	queued := time.Now()
+	release, err := proxy.Acquire(ctx, service.Name)
+	middleware.AddPhaseTime(c, middleware.PhaseQueue, time.Since(queued))
+	if errors.Is(err, context.Canceled) {
+		log.Debug("Request cancelled while queued", zap.String("service", service.Name), zap.String("path", path))
+		return middleware.NewError(statusClientClosedRequest, models.ErrCodeClientClosedRequest, "request cancelled")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		failure := classifyUpstreamError(ctx, err)
+		return middleware.NewError(failure.Status, failure.Code, failure.Message)
 	}
-	// Execute request to NestJS
-	client := &http.Client{}
-	resp, err := client.Do(req)
 	if err != nil {
-		log.Error("Request to backend failed", zap.Error(err), zap.String("path", path))
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": "backend service unavailable",
-		})
+		span.SetStatus(codes.Error, err.Error())
+		log.Warn("Service at concurrency limit", zap.String("service", service.Name), zap.String("path", path))
+		c.Set(fiber.HeaderRetryAfter, "1")
+		return middleware.NewError(fiber.StatusServiceUnavailable, models.ErrCodeServiceBusy, "service overloaded")
 	}
-	defer resp.Body.Close()
+	defer func() {
+		if !streaming {
+			release()
+		}
+	}()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	// Keep traffic off a service whose circuit is open
+	breakerDone, err := proxy.Allow(service.Name)
 	if err != nil {
+		span.SetAttributes(attribute.Bool("gateway.circuit_open", true))
+		log.Warn("Circuit open, rejecting request", zap.String("service", service.Name), zap.String("path", path))
+		metrics.UpstreamErrors.WithLabelValues(serviceName, string(models.ErrCodeCircuitOpen)).Inc()
+		c.Set(fiber.HeaderRetryAfter, middleware.RetryAfter(proxy.BreakerConfig(service.Name).Timeout))
+		return middleware.NewError(fiber.StatusServiceUnavailable, models.ErrCodeCircuitOpen, "service unavailable, circuit open")
+	}
+
+	// The service's transport carries its TLS, protocol and pool settings. The
+	// client sets no timeout of its own so streamed bodies aren't cut off.
+	client := &http.Client{Transport: proxy.Transport(service.Name)}
+
+	// Shadow traffic gets a copy; its outcome never affects this request
+	proxy.Mirror(service.Name, req, body)
+
+	// Execute request to NestJS; bodies larger than a cacheable object are
+	// streamed. Idempotent requests are retried on the failures the service's
+	// retry policy names, within its retry budget, and so are those with an
+	// idempotency key that never reached the backend.
+	keyed := middleware.IdempotencyKey(c) != ""
+	policy := retryPolicy(cfg, service)
+	// Sharing calls needs the caller's credentials in the request to keep
+	// callers apart, and basic auth replaces them
+	dedup := cfg.Upstream.Dedup && service.BasicAuth == nil
+	maxBytes := service.MaxResponseBytes
+	if maxBytes == 0 {
+		maxBytes = cfg.Upstream.DefaultMaxResponseBytes
+	}
+	proxy.RecordRequest(service.Name)
+	var resp *upstreamResponse
+	var shared bool
+	var elapsed time.Duration
+	first := time.Now()
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		resp, shared, err = doUpstream(client, c.Method(), req, dedup,
+			cfg.Upstream.DedupTimeout, cfg.Cache.MaxObjectBytes, maxBytes)
+		elapsed = time.Since(start)
+		metrics.Proxy.Record(serviceName, elapsed, err != nil || resp.StatusCode >= fiber.StatusInternalServerError)
+		// Cancelled or expired callers say nothing about the instance's health
+		if ctx.Err() == nil && !badBody(err) {
+			status := 0
+			if err == nil {
+				status = resp.StatusCode
+			}
+			proxy.ReportInstance(service.Name, instance, gateway.Outcome(err, status))
+		}
+
+		reason, ok := retryable(policy, c.Method(), keyed, err, resp)
+		if !ok || attempt >= policy.Attempts || ctx.Err() != nil {
+			break
+		}
+		backoff := retryBackoff(policy, attempt)
+		if policy.Deadline > 0 && time.Since(first)+backoff >= policy.Deadline {
+			break
+		}
+		if !proxy.AllowRetry(service.Name) {
+			break
+		}
+		if err == nil && resp.Stream != nil {
+			resp.Stream.Close()
+		}
+		metrics.UpstreamRetries.WithLabelValues(serviceName, reason).Inc()
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("reason", reason),
+		))
+		log.Warn("Request attempt failed, retrying",
+			zap.String("service", service.Name),
+			zap.Int("attempt", attempt),
+			zap.String("reason", reason),
+			zap.Error(err),
+		)
+		if !sleepContext(ctx, backoff) {
+			// The response was discarded for the retry, so the request fails
+			resp, err = nil, ctx.Err()
+			break
+		}
+		req = req.Clone(ctx)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	var failure error
+	switch {
+	case err != nil:
+		failure = err
+	case resp.StatusCode >= fiber.StatusInternalServerError:
+		failure = fmt.Errorf("upstream responded %d", resp.StatusCode)
+	}
+	// A cancelled caller must still settle its breaker slot, or a half-open
+	// circuit would wait on it forever
+	if ctx.Err() != nil {
+		breakerDone(nil)
+	} else {
+		breakerDone(failure)
+		proxy.Observe(service.Name, elapsed, failure)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if errors.Is(err, errReadResponse) {
 		log.Error("Failed to read response", zap.Error(err))
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "gateway error",
-		})
+		metrics.UpstreamErrors.WithLabelValues(serviceName, string(models.ErrCodeInternal)).Inc()
+		return middleware.NewError(fiber.StatusInternalServerError, models.ErrCodeInternal, "gateway error")
+	}
+	var tooLarge *responseTooLargeError
+	if errors.As(err, &tooLarge) {
+		outcome := "aborted"
+		if tooLarge.Declared {
+			outcome = "rejected"
+		}
+		log.Error("Upstream response over the size limit",
+			zap.String("service", service.Name),
+			zap.String("path", path),
+			zap.Int("limit_bytes", tooLarge.Limit),
+			zap.Int64("bytes", tooLarge.Size),
+			zap.Bool("declared", tooLarge.Declared),
+		)
+		metrics.ResponsesTooLarge.WithLabelValues(serviceName, outcome).Inc()
+		metrics.UpstreamErrors.WithLabelValues(serviceName, string(models.ErrCodeResponseTooLarge)).Inc()
+		return middleware.NewError(fiber.StatusBadGateway, models.ErrCodeResponseTooLarge, "upstream response too large").
+			WithDetails(fiber.Map{"limit_bytes": tooLarge.Limit})
+	}
+	if err != nil {
+		failure := classifyUpstreamError(ctx, err)
+		log.Error("Request to backend failed",
+			zap.Error(err),
+			zap.String("path", path),
+			zap.String("code", string(failure.Code)),
+		)
+		metrics.UpstreamErrors.WithLabelValues(serviceName, string(failure.Code)).Inc()
+		if failure.Status == fiber.StatusServiceUnavailable {
+			retryAfter := service.RetryAfter
+			if retryAfter == 0 {
+				retryAfter = cfg.Upstream.DefaultRetryAfter
+			}
+			if retryAfter > 0 {
+				c.Set(fiber.HeaderRetryAfter, middleware.RetryAfter(retryAfter))
+			}
+		}
+		return middleware.NewError(failure.Status, failure.Code, failure.Message)
+	}
+	metrics.BytesIn.WithLabelValues(serviceName).Add(float64(len(c.Body())))
+	middleware.SetUpstreamDuration(c, resp.Duration)
+	responding := time.Now()
+	defer func() {
+		middleware.AddPhaseTime(c, middleware.PhaseSerialize, time.Since(responding))
+	}()
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", resp.StatusCode),
+		attribute.Bool("gateway.shared", shared),
+		attribute.Int64("gateway.upstream_latency_ms", resp.Duration.Milliseconds()),
+	)
+	if resp.StatusCode >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	// Streamed bodies pass through untransformed. A shared response is read
+	// by other callers, so the transformed body is kept apart.
+	respBody := resp.Body
+	if transformer != nil && resp.Stream == nil && len(respBody) > 0 && gateway.IsJSON(resp.Header.Get(fiber.HeaderContentType)) {
+		transformed, err := transformer.TransformResponse(respBody)
+		if err != nil {
+			log.Warn("Response body transform failed",
+				zap.String("service", service.Name),
+				zap.Int("status", resp.StatusCode),
+				zap.Error(err),
+			)
+			metrics.UpstreamErrors.WithLabelValues(serviceName, string(models.ErrCodeTransformFailed)).Inc()
+			return middleware.NewError(fiber.StatusBadGateway, models.ErrCodeTransformFailed, "response transform failed")
+		}
+		respBody = transformed
 	}
 
 	// Copy response headers
@@ -155,25 +957,638 @@ func ForwardRequest(c *fiber.Ctx, backendURL string, path string, log *zap.Logge
 		}
 	}
 
+	if cfg.Server.DebugHeaders {
+		setUpstreamHeaders(c, cfg.Server.UpstreamHeader, service, req.URL.Host, resp.Duration)
+	}
+
 	// Log the request
 	log.Info("Request forwarded",
 		zap.String("method", c.Method()),
 		zap.String("path", path),
 		zap.Int("status", resp.StatusCode),
+		zap.Bool("shared", shared),
+		zap.Bool("streamed", resp.Stream != nil),
 	)
 
+	if resp.Stream != nil {
+		streaming = true
+		stream := &upstreamStream{ReadCloser: resp.Stream, bytesOut: metrics.BytesOut.WithLabelValues(serviceName), done: func() {
+			release()
+			span.End()
+			cancel()
+		}}
+		// Event streams run for as long as the backend sends events, so they
+		// aren't held to the size limit
+		if maxBytes > 0 && !resp.EventStream {
+			stream.limit = int64(maxBytes)
+			stream.exceeded = func(read int64) {
+				log.Error("Upstream response cut off over the size limit",
+					zap.String("service", service.Name),
+					zap.String("path", path),
+					zap.Int("limit_bytes", maxBytes),
+					zap.Int64("bytes", read),
+				)
+				metrics.ResponsesTooLarge.WithLabelValues(serviceName, "aborted").Inc()
+				metrics.UpstreamErrors.WithLabelValues(serviceName, string(models.ErrCodeResponseTooLarge)).Inc()
+			}
+		}
+		if resp.EventStream {
+			// fasthttp would buffer a plain body stream, holding events back.
+			// Events flow for as long as the backend sends them, so the server's
+			// write timeout, set just before the body is written, is lifted.
+			c.Status(resp.StatusCode)
+			conn := c.Context().Conn()
+			c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+				conn.SetWriteDeadline(time.Time{})
+				sendEvents(w, stream)
+			})
+			return nil
+		}
+		return c.Status(resp.StatusCode).SendStream(stream, int(resp.ContentLength))
+	}
+
 	// Return response from NestJS
-	return c.Status(resp.StatusCode).Send(body)
+	metrics.BytesOut.WithLabelValues(serviceName).Add(float64(len(respBody)))
+	return c.Status(resp.StatusCode).Send(respBody)
+}
+
+// retryable reports whether an upstream call may be repeated, and the reason
+// it failed: the backend never answered or sent a status the policy retries,
+// and the method is idempotent. Keyed requests with other methods are only
+// repeated when they never reached the backend: the idempotency key dedups
+// the client's repeats, not the gateway's, so a call the backend may have
+// run is never sent again.
+func retryable(policy config.RetryPolicy, method string, keyed bool, err error, resp *upstreamResponse) (string, bool) {
+	if badBody(err) {
+		return "", false
+	}
+	var idempotent bool
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodPut, fiber.MethodDelete:
+		idempotent = true
+	}
+	if !idempotent && (!keyed || err == nil || !neverSent(err)) {
+		return "", false
+	}
+	if err != nil {
+		return config.RetryOnConnectionError, len(policy.RetryOn) == 0 ||
+			slices.Contains(policy.RetryOn, config.RetryOnConnectionError)
+	}
+	status := resp.StatusCode
+	if slices.Contains(policy.Statuses, status) ||
+		status >= fiber.StatusInternalServerError && slices.Contains(policy.RetryOn, config.RetryOn5xx) {
+		return strconv.Itoa(status), true
+	}
+	return "", false
+}
+
+// neverSent reports whether a failed upstream call failed before a request
+// was written: its host didn't resolve or no connection could be made
+func neverSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, new(*net.DNSError)) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// retryPolicy returns the retry policy of a service. Loaded services carry
+// their own; otherwise the global policy applies with the service's MaxRetry.
+func retryPolicy(cfg *config.Config, service config.ServiceConfig) config.RetryPolicy {
+	if service.Retry != nil {
+		return *service.Retry
+	}
+	policy := cfg.Upstream.Retry
+	if policy.Attempts == 0 {
+		policy.Attempts = service.MaxRetry
+	}
+	return policy
+}
+
+// retryBackoff returns the wait before retry n: the policy's base doubled
+// n-1 times up to its cap, less a random share of up to its jitter
+func retryBackoff(policy config.RetryPolicy, n int) time.Duration {
+	backoff := policy.BackoffBase
+	for i := 1; i < n && backoff < policy.BackoffCap; i++ {
+		backoff *= 2
+	}
+	if policy.BackoffCap > 0 {
+		backoff = min(backoff, policy.BackoffCap)
+	}
+	if policy.Jitter > 0 {
+		backoff -= time.Duration(rand.Float64() * policy.Jitter * float64(backoff))
+	}
+	return backoff
+}
+
+// badBody reports whether a failed upstream call got a response whose body
+// couldn't be read or was over its limit: the backend answered, so the call
+// isn't retried and says nothing about the instance's reachability
+func badBody(err error) bool {
+	return errors.Is(err, errReadResponse) || errors.As(err, new(*responseTooLargeError))
+}
+
+// sleepContext waits for d, reporting false when ctx ends first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// pickInstance returns the base URL of the instance to forward to, keyed by the
+// affinity cookie or user_id when the service asks for session affinity
+func pickInstance(c *fiber.Ctx, proxy *gateway.Proxy, service config.ServiceConfig) string {
+	// An unconfigured default upstream has no balancer. Configured services
+	// always go through theirs: discovery may have given a URL-only service
+	// instances since startup.
+	if service.Name == "" {
+		return service.URL
+	}
+	// Clients the canary serves skip the stable instances
+	if target, ok := proxy.Canary(service.Name, canaryKey(c), canaryRequested(c, service.Canary)); ok {
+		return target
+	}
+
+	var key string
+	switch service.Affinity {
+	case gateway.AffinityCookie:
+		key = c.Cookies(service.AffinityCookie)
+		if key == "" {
+			key = utils.UUIDv4()
+			c.Cookie(&fiber.Cookie{
+				Name:     service.AffinityCookie,
+				Value:    key,
+				Path:     "/",
+				HTTPOnly: true,
+				SameSite: fiber.CookieSameSiteLaxMode,
+			})
+		}
+	case gateway.AffinityUser:
+		key = middleware.UserIDFromLocals(c)
+	}
+	return proxy.PickInstance(service.Name, key)
+}
+
+// canaryKey buckets a client for canary routing: by user ID, so a user stays on
+// one track across devices, or else by client IP
+func canaryKey(c *fiber.Ctx) string {
+	if userID := middleware.UserIDFromLocals(c); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + middleware.ClientIP(c)
+}
+
+// canaryRequested reports whether the request carries the canary's match
+// header or cookie
+func canaryRequested(c *fiber.Ctx, canary *config.CanaryConfig) bool {
+	if canary == nil {
+		return false
+	}
+	matches := func(value string) bool {
+		return value != "" && (canary.Value == "" || value == canary.Value)
+	}
+	return (canary.Header != "" && matches(c.Get(canary.Header))) ||
+		(canary.Cookie != "" && matches(c.Cookies(canary.Cookie)))
+}
+
+// upstreamName names the service in metrics; requests to an unconfigured default
+// upstream are reported as "default"
+func upstreamName(service config.ServiceConfig) string {
+	if service.Name == "" {
+		return "default"
+	}
+	return service.Name
+}
+
+// setUpstreamHeaders reports which upstream served the request and how long it took
+func setUpstreamHeaders(c *fiber.Ctx, header string, service config.ServiceConfig, host string, duration time.Duration) {
+	upstream := host
+	if service.Name != "" {
+		upstream = service.Name + "@" + host
+	}
+	c.Set(header, upstream)
+	c.Set(header+"-Duration-Ms", strconv.FormatInt(duration.Milliseconds(), 10))
+}
+
+// upstreamResponse is a backend response. Bodies up to the buffer limit are read
+// fully and may be shared between callers; larger ones are left in Stream for
+// the caller that made the request.
+type upstreamResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Duration   time.Duration
+	// Stream holds an unbuffered body of ContentLength bytes, -1 when unknown
+	Stream        io.ReadCloser
+	ContentLength int64
+	// EventStream marks a text/event-stream body, forwarded as it arrives
+	EventStream bool
+}
+
+// clone returns a copy so callers sharing one upstream call never alias each other's data
+func (r *upstreamResponse) clone() *upstreamResponse {
+	return &upstreamResponse{
+		StatusCode:    r.StatusCode,
+		Header:        r.Header.Clone(),
+		Body:          bytes.Clone(r.Body),
+		Duration:      r.Duration,
+		Stream:        r.Stream,
+		ContentLength: r.ContentLength,
+		EventStream:   r.EventStream,
+	}
+}
+
+// upstreamStream counts the bytes streamed and releases the request's resources
+// once the body is closed. With a limit, the body is cut off there: the
+// upstream body is closed, dropping its connection, and exceeded is called
+// with the bytes read.
+type upstreamStream struct {
+	io.ReadCloser
+	bytesOut prometheus.Counter
+	done     func()
+	once     sync.Once
+
+	limit    int64
+	read     int64
+	exceeded func(read int64)
+}
+
+func (s *upstreamStream) Read(p []byte) (int, error) {
+	if s.limit > 0 && s.read > s.limit {
+		return 0, &responseTooLargeError{Limit: int(s.limit), Size: s.read}
+	}
+	n, err := s.ReadCloser.Read(p)
+	s.read += int64(n)
+	if s.limit > 0 && s.read > s.limit {
+		n -= int(s.read - s.limit)
+		s.ReadCloser.Close()
+		s.exceeded(s.read)
+		err = &responseTooLargeError{Limit: int(s.limit), Size: s.read}
+	}
+	s.bytesOut.Add(float64(n))
+	return n, err
+}
+
+func (s *upstreamStream) Close() error {
+	err := s.ReadCloser.Close()
+	s.once.Do(s.done)
+	return err
+}
+
+var errReadResponse = errors.New("failed to read response body")
+
+// responseTooLargeError is a response body over its size limit: declared so
+// by its Content-Length, or found so after reading Size bytes
+type responseTooLargeError struct {
+	Limit    int
+	Size     int64
+	Declared bool
+}
+
+func (e *responseTooLargeError) Error() string {
+	if e.Declared {
+		return fmt.Sprintf("response of %d bytes exceeds the limit of %d", e.Size, e.Limit)
+	}
+	return fmt.Sprintf("response exceeded the limit of %d bytes after %d", e.Limit, e.Size)
+}
+
+// statusClientClosedRequest is the non-standard status nginx logs for requests
+// the client abandoned
+const statusClientClosedRequest = 499
+
+// upstreamFailure describes why a backend call failed and how to report it
+type upstreamFailure struct {
+	Status  int
+	Code    models.ErrorCode
+	Message string
+}
+
+// classifyUpstreamError tells timeouts, refused connections, DNS and certificate
+// failures apart. A deadline of ctx that ran out is the backend timing out when
+// the service timeout ended it, and the caller's budget running out otherwise.
+// A backend that can't be reached is temporarily unavailable, 503; one that
+// was reached but answered badly, or not at all, is a bad gateway, 502.
+func classifyUpstreamError(ctx context.Context, err error) upstreamFailure {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+
+	switch {
+	case errors.Is(err, errServiceTimeout),
+		errors.Is(err, context.DeadlineExceeded) && errors.Is(context.Cause(ctx), errServiceTimeout):
+		return upstreamFailure{fiber.StatusGatewayTimeout, models.ErrCodeUpstreamTimeout, "backend timed out"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return upstreamFailure{fiber.StatusGatewayTimeout, models.ErrCodeDeadlineExceeded, "request deadline exceeded"}
+	case errors.As(err, &dnsErr):
+		return upstreamFailure{fiber.StatusServiceUnavailable, models.ErrCodeUpstreamDNS, "backend host could not be resolved"}
+	case errors.As(err, &certErr):
+		return upstreamFailure{fiber.StatusBadGateway, models.ErrCodeUpstreamTLS, "backend certificate could not be verified"}
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return upstreamFailure{fiber.StatusServiceUnavailable, models.ErrCodeUpstreamRefused, "backend refused the connection"}
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return upstreamFailure{fiber.StatusServiceUnavailable, models.ErrCodeUpstreamUnavailable, "backend service unavailable"}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return upstreamFailure{fiber.StatusGatewayTimeout, models.ErrCodeUpstreamTimeout, "backend timed out"}
+	default:
+		return upstreamFailure{fiber.StatusBadGateway, models.ErrCodeUpstreamBadResponse, "bad response from backend"}
+	}
+}
+
+// inflight deduplicates identical concurrent idempotent requests
+var inflight singleflight.Group
+
+// dedupedRequests counts requests answered with another request's upstream response
+var dedupedRequests atomic.Int64
+
+// doUpstream executes req. With dedup enabled, identical concurrent GET/HEAD requests
+// share one upstream call; a waiter gives up on a slow leader after wait and fetches
+// for itself. The boolean reports whether the response was shared. Streamed
+// bodies can't be shared, so waiters handed one fetch for themselves.
+func doUpstream(client *http.Client, method string, req *http.Request, dedup bool, wait time.Duration, bufferLimit, maxBytes int) (*upstreamResponse, bool, error) {
+	if !dedup || (method != fiber.MethodGet && method != fiber.MethodHead) {
+		resp, err := fetchUpstream(client, req, bufferLimit, maxBytes)
+		return resp, false, err
+	}
+
+	// Credentials are part of the key so users never receive each other's responses,
+	// and so are validators so unconditional requests never receive a 304
+	key := method + " " + req.URL.String() + " " +
+		req.Header.Get(fiber.HeaderAuthorization) + " " + req.Header.Get(middleware.APIKeyHeader) + " " +
+		req.Header.Get(fiber.HeaderCookie) + " " +
+		req.Header.Get(fiber.HeaderIfNoneMatch) + " " + req.Header.Get(fiber.HeaderIfModifiedSince)
+
+	// Only the leader's function runs, so this tells the leader from waiters
+	var leader atomic.Bool
+	results := inflight.DoChan(key, func() (interface{}, error) {
+		leader.Store(true)
+		return fetchUpstream(client, req, bufferLimit, maxBytes)
+	})
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case res := <-results:
+			if res.Err != nil {
+				return nil, res.Shared, res.Err
+			}
+			if !leader.Load() && res.Val.(*upstreamResponse).Stream != nil {
+				resp, err := fetchUpstream(client, req, bufferLimit, maxBytes)
+				return resp, false, err
+			}
+			if res.Shared && !leader.Load() {
+				dedupedRequests.Add(1)
+			}
+			return res.Val.(*upstreamResponse).clone(), res.Shared, nil
+		case <-timer.C:
+			if !leader.Load() {
+				resp, err := fetchUpstream(client, req, bufferLimit, maxBytes)
+				return resp, false, err
+			}
+		case <-req.Context().Done():
+			return nil, false, req.Context().Err()
+		}
+	}
+}
+
+// fetchUpstream executes req and reads the response body. Bodies larger than
+// bufferLimit (0 = no limit) are returned unread in Stream, skipping the read
+// altogether when Content-Length already exceeds it. Server-sent events are
+// always returned unread, since the stream only ends when the backend closes it.
+func fetchUpstream(client *http.Client, req *http.Request, bufferLimit, maxBytes int) (*upstreamResponse, error) {
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &upstreamResponse{
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+	}
+	// A body declared over the limit is refused before any of it is read
+	if maxBytes > 0 && resp.ContentLength > int64(maxBytes) && req.Method != http.MethodHead {
+		resp.Body.Close()
+		return nil, &responseTooLargeError{Limit: maxBytes, Size: resp.ContentLength, Declared: true}
+	}
+	if isEventStream(resp.Header) && req.Method != http.MethodHead {
+		result.Stream = resp.Body
+		result.EventStream = true
+		result.Duration = time.Since(start)
+		return result, nil
+	}
+	if bufferLimit > 0 && resp.ContentLength > int64(bufferLimit) && req.Method != http.MethodHead {
+		result.Stream = resp.Body
+		result.Duration = time.Since(start)
+		return result, nil
+	}
+
+	readLimit := bufferLimit
+	if maxBytes > 0 && (readLimit <= 0 || maxBytes < readLimit) {
+		readLimit = maxBytes
+	}
+	body := io.Reader(resp.Body)
+	if readLimit > 0 {
+		body = io.LimitReader(resp.Body, int64(readLimit)+1)
+	}
+	result.Body, err = io.ReadAll(body)
+	result.Duration = time.Since(start)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %v", errReadResponse, err)
+	}
+	if maxBytes > 0 && len(result.Body) > maxBytes {
+		// Closing the unread rest drops the connection
+		resp.Body.Close()
+		return nil, &responseTooLargeError{Limit: maxBytes, Size: int64(len(result.Body))}
+	}
+	if bufferLimit > 0 && len(result.Body) > bufferLimit {
+		// Too large to buffer: stream what was read followed by the rest
+		result.Stream = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(result.Body), resp.Body), Closer: resp.Body}
+		result.Body = nil
+		return result, nil
+	}
+	resp.Body.Close()
+	return result, nil
+}
+
+func isEventStream(header http.Header) bool {
+	return strings.HasPrefix(strings.ToLower(header.Get(fiber.HeaderContentType)), "text/event-stream")
+}
+
+// sendEvents copies an event stream to the client, flushing after every read
+// so no event waits in a buffer. A failed write means the client went away;
+// closing the stream then stops reading from the backend.
+func sendEvents(w *bufio.Writer, stream io.ReadCloser) {
+	defer stream.Close()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// prefixedBody is a response body with its first bytes already read
+type prefixedBody struct {
+	io.Reader
+	io.Closer
 }
 
 // ============================================================================
 // OPTIONAL FEATURES - Enable only when needed
 // ============================================================================
 
-// setupRateLimitingRoutes adds rate limiting to specific endpoints
-func SetupRateLimitingRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger) {
-	// Apply rate limiting to high-traffic routes
-	// Example: Rate limit middleware can be added here
+// setupRateLimitingRoutes adds IP-keyed rate limiting backed by memory or Redis.
+// With the "user_or_ip" strategy only anonymous requests are limited here.
+// The limiters are rebuilt, starting afresh, when a reload changes their settings.
+func SetupRateLimitingRoutes(app *fiber.App, reloader *Reloader, log *zap.Logger) {
+	app.Use(reloader.Handler(
+		func(cfg *config.Config) any { return []any{cfg.RateLimit, cfg.Cache.Redis} },
+		func(cfg *config.Config) fiber.Handler { return ipRateLimiter(cfg, log) },
+	))
+}
+
+// ipRateLimiter returns the IP-keyed limiter for the configured strategy, or a
+// handler that passes every request on
+func ipRateLimiter(cfg *config.Config, log *zap.Logger) fiber.Handler {
+	if !cfg.RateLimit.Enabled {
+		return next
+	}
+
+	log.Info("Rate limiting enabled",
+		zap.String("backend", cfg.RateLimit.Backend),
+		zap.String("strategy", cfg.RateLimit.Strategy),
+		zap.Int("requests_per_minute", cfg.RateLimit.RequestsPerMinute),
+		zap.Int("user_requests_per_minute", cfg.RateLimit.UserRequestsPerMinute),
+		zap.Int("admin_requests_per_minute", cfg.RateLimit.AdminRequestsPerMinute),
+		zap.Any("route_costs", cfg.RateLimit.RouteCosts),
+		zap.Bool("fail_open", cfg.RateLimit.FailOpen),
+	)
+
+	policy := middleware.RateLimitPolicy{
+		Costs:    cfg.RateLimit.RouteCosts,
+		FailOpen: cfg.RateLimit.FailOpen,
+	}
+
+	tier := "ip"
+	switch cfg.RateLimit.Strategy {
+	case "user":
+		// Only authenticated traffic is limited, see userRateLimiter
+		return next
+	case "user_or_ip":
+		policy.Key = middleware.AnonymousIPKey
+		tier = middleware.TierAnonymous
+	default:
+		policy.Key = middleware.IPKey
+	}
+
+	policy.Tier = middleware.StaticTier(tier)
+	policy.Limiters = map[string]middleware.RateLimiter{
+		tier: newRateLimiter(cfg, cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.BurstSize),
+	}
+
+	return middleware.RateLimitFiber(policy, log)
+}
+
+// userRateLimiter returns the per-user limiter for the configured strategy, or
+// a handler that passes every request on. Users with the admin role get their
+// own, larger tier.
+func userRateLimiter(cfg *config.Config, log *zap.Logger) fiber.Handler {
+	if !cfg.RateLimit.Enabled {
+		return next
+	}
+	if cfg.RateLimit.Strategy != "user" && cfg.RateLimit.Strategy != "user_or_ip" {
+		return next
+	}
+
+	adminRPM, adminBurst := cfg.RateLimit.AdminRequestsPerMinute, cfg.RateLimit.AdminBurstSize
+	if adminRPM == 0 {
+		adminRPM, adminBurst = cfg.RateLimit.UserRequestsPerMinute, cfg.RateLimit.UserBurstSize
+	}
+
+	return middleware.RateLimitFiber(middleware.RateLimitPolicy{
+		Key:  middleware.UserKey,
+		Tier: middleware.ClaimsTier,
+		Limiters: map[string]middleware.RateLimiter{
+			middleware.TierAuthenticated: newRateLimiter(cfg, cfg.RateLimit.UserRequestsPerMinute, cfg.RateLimit.UserBurstSize),
+			middleware.TierAdmin:         newRateLimiter(cfg, adminRPM, adminBurst),
+		},
+		Costs:    cfg.RateLimit.RouteCosts,
+		FailOpen: cfg.RateLimit.FailOpen,
+	}, log)
+}
+
+// routeRateLimiter applies the rate limits of the routes that set one, per
+// user or, for anonymous callers, per client IP
+func routeRateLimiter(cfg *config.Config, log *zap.Logger) fiber.Handler {
+	limiters := make(map[string]fiber.Handler)
+	for _, route := range gateway.NewRouteTable(cfg.Upstream.Routes).Routes() {
+		// Of routes alike, the one tried first is the one matched
+		tier := routeTier(route)
+		if _, dup := limiters[tier]; route.RateLimit == nil || dup {
+			continue
+		}
+		// Each route is limited apart from the others and the global limits
+		limiters[tier] = middleware.RateLimitFiber(middleware.RateLimitPolicy{
+			Key: func(c *fiber.Ctx) (string, bool) {
+				if key, ok := middleware.UserKey(c); ok {
+					return key, true
+				}
+				return middleware.IPKey(c)
+			},
+			Tier: middleware.StaticTier(tier),
+			Limiters: map[string]middleware.RateLimiter{
+				tier: newRateLimiter(cfg, route.RateLimit.RequestsPerMinute, route.RateLimit.BurstSize),
+			},
+			FailOpen: cfg.RateLimit.FailOpen,
+		}, log)
+	}
+	if len(limiters) == 0 {
+		return next
+	}
+
+	return func(c *fiber.Ctx) error {
+		if route := middleware.RouteFromLocals(c); route != nil {
+			if limiter, ok := limiters[routeTier(route)]; ok {
+				return limiter(c)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// routeTier names the rate limit tier of a route. Routes are keyed by their
+// methods and path rather than by identity, as the route matcher and the
+// route rate limiter each build their own table.
+func routeTier(route *config.RouteConfig) string {
+	return "route:" + strings.Join(route.Methods, ",") + ":" + route.Path
+}
+
+// newRateLimiter builds a limiter on the configured backend
+func newRateLimiter(cfg *config.Config, requestsPerMinute, burst int) middleware.RateLimiter {
+	switch cfg.RateLimit.Backend {
+	case "redis":
+		// Shared across prefork children and replicas
+		return middleware.NewRedisRateLimiter(cfg.Cache.Redis, requestsPerMinute, time.Minute)
+	default:
+		perSecond := rate.Limit(float64(requestsPerMinute) / 60)
+		return middleware.NewIPRateLimiter(perSecond, burst)
+	}
 }
 
 // setupCircuitBreakerRoutes adds circuit breaker pattern to critical endpoints
@@ -182,43 +1597,377 @@ func SetupCircuitBreakerRoutes(app *fiber.App, cfg *config.Config, log *zap.Logg
 	// Example: Circuit breaker middleware can be added here
 }
 
-// setupCachingRoutes adds response caching to read-only endpoints
-func SetupCachingRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger) {
-	// Cache GET requests for a period
-	// Example: Caching middleware can be added here
+// setupCachingRoutes adds response caching to read-only endpoints. Cached
+// paths, TTLs and the like apply on reload; the store is kept.
+func SetupCachingRoutes(router fiber.Router, store cache.Cache, reloader *Reloader, log *zap.Logger) {
+	// Registered first so cache hits are revalidated as well
+	router.Use(reloader.Handler(
+		func(cfg *config.Config) any { return cfg.Cache.Paths },
+		func(cfg *config.Config) fiber.Handler { return middleware.ConditionalFiber(cfg.Cache.Paths) },
+	))
+	router.Use(reloader.Handler(
+		func(cfg *config.Config) any { return cfg.Cache },
+		func(cfg *config.Config) fiber.Handler { return middleware.CacheFiber(store, cfg.Cache, log) },
+	))
+}
+
+// next passes the request on, standing in for a disabled middleware
+func next(c *fiber.Ctx) error {
+	return c.Next()
+}
+
+// newResponseCache returns the configured cache backend, or nil when caching is disabled
+func newResponseCache(cfg *config.Config) cache.Cache {
+	if !cfg.Cache.Enabled {
+		return nil
+	}
+	if cfg.Cache.Backend == "redis" {
+		return cache.NewRedisCache(cfg.Cache.Redis, cfg.Cache.MaxSize, cfg.Cache.LocalTTL)
+	}
+	return cache.NewMemoryCache(cfg.Cache.MaxSize)
+}
+
+// newIdempotencyStore returns the configured store of idempotent responses.
+// Redis lookups skip the local cache: a miss remembered there would let a
+// repeat through to the backend.
+func newIdempotencyStore(cfg *config.Config) cache.Cache {
+	if cfg.Idempotency.Backend == "redis" {
+		return cache.NewRedisStore(cfg.Cache.Redis, "idempotency", 0, 0)
+	}
+	return cache.NewMemoryCache(cfg.Idempotency.MaxKeys)
+}
+
+// SetupOpenAPIRoutes serves the services' OpenAPI documents merged into one at
+// /openapi.json. They are fetched once in the background at startup.
+func SetupOpenAPIRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger, proxy *gateway.Proxy) {
+	if cfg.Upstream.OpenAPIPath == "" {
+		return
+	}
+
+	aggregator := gateway.NewOpenAPIAggregator(proxy, cfg.Upstream.OpenAPIPath, app.Config().AppName, log)
+	go aggregator.Refresh(context.Background())
+
+	app.Get("/openapi.json", func(c *fiber.Ctx) error {
+		doc := aggregator.Document()
+		if doc == nil {
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return middleware.NewError(fiber.StatusServiceUnavailable, models.ErrCodeServiceUnavailable, "OpenAPI document not ready")
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(doc)
+	})
+}
+
+// SetupDebugRoutes serves net/http/pprof under /debug/pprof to admin API keys
+// calling from the internal IPs, unless disabled by SERVER_PPROF_ENABLED
+func SetupDebugRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger) {
+	if !cfg.Server.PprofEnabled {
+		return
+	}
+	if !cfg.APIKeys.Enabled || len(cfg.Server.InternalAllowedIPs) == 0 {
+		log.Info("pprof needs API keys and internal IPs, not registered")
+		return
+	}
+	internalOnly, err := middleware.IPAllowlistFiber(cfg.Server.InternalAllowedIPs)
+	if err != nil {
+		log.Fatal("Invalid internal IP allowlist", zap.Error(err))
+	}
+
+	app.Use("/debug/pprof",
+		internalOnly,
+		middleware.APIKeyFiber(auth.NewAPIKeyValidator(cfg, log), log),
+		middleware.RequireAPIKeyRole("admin"),
+		pprof.New(),
+	)
+}
+
+// SetupAuthRoutes adds POST /auth/introspect: {"token": "..."} returns the
+// token's decoded claims, validity, expiry and the reason it was rejected.
+// It reveals token internals, so only internal client IPs may call it.
+func SetupAuthRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger, validator *auth.TokenValidator) {
+	if len(cfg.Server.InternalAllowedIPs) == 0 {
+		log.Info("No internal IPs configured, token introspection not registered")
+		return
+	}
+	internalOnly, err := middleware.IPAllowlistFiber(cfg.Server.InternalAllowedIPs)
+	if err != nil {
+		log.Fatal("Invalid internal IP allowlist", zap.Error(err))
+	}
+
+	app.Post("/auth/introspect", internalOnly, func(c *fiber.Ctx) error {
+		var body struct {
+			Token string `json:"token"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Token == "" {
+			return middleware.NewError(fiber.StatusBadRequest, models.ErrCodeBadRequest, `expected {"token": "..."}`)
+		}
+
+		result := validator.Introspect(body.Token)
+		middleware.RequestLogger(c, log).Info("Token introspected",
+			zap.String("token_fingerprint", redact.Fingerprint(body.Token)),
+			zap.Bool("valid", result.Valid),
+			zap.String("reason", result.Reason),
+		)
+		return c.JSON(result)
+	})
+}
+
+// SetupLogStreamRoutes adds /admin/logs/stream, a WebSocket streaming the
+// gateway's log entries to admins. Browsers can't set headers on a WebSocket,
+// so the JWT may also be passed as ?access_token=.
+func SetupLogStreamRoutes(app *fiber.App, cfg *config.Config, logStream *loggers.Broadcaster) {
+	app.Get("/admin/logs/stream",
+		jwtware.New(jwtware.Config{
+			SigningKey:   []byte(cfg.JWT.SecretKey),
+			TokenLookup:  "header:Authorization,query:access_token",
+			AuthScheme:   "Bearer",
+			ErrorHandler: middleware.JWTErrorHandler,
+		}),
+		middleware.RequireJWTRole("admin"),
+		handler.HandleLogStream(logStream),
+	)
+}
+
+// NewHealthChecker returns the checker of the gateway's dependencies: its
+// upstream services, Redis when the cache or rate limiter uses it, and the
+// database when the audit log does. It backs both the startup wait and the
+// health routes; start it once the startup wait is over.
+func NewHealthChecker(cfg *config.Config, log *zap.Logger, proxy *gateway.Proxy) *health.Checker {
+	informational := make(map[string]bool, len(cfg.Health.Informational))
+	for _, name := range cfg.Health.Informational {
+		informational[name] = true
+	}
+
+	var checks []health.Check
+	for _, service := range cfg.Upstream.Services {
+		check := health.Check{
+			Name:     service.Name,
+			Critical: !informational[service.Name],
+			Probe: func(ctx context.Context) error {
+				return proxy.ProbeService(ctx, service.Name)
+			},
+		}
+		if hc := service.HealthCheck; hc != nil {
+			check.Interval, check.Timeout = hc.Interval, hc.Timeout
+			check.HealthyThreshold, check.UnhealthyThreshold = hc.HealthyThreshold, hc.UnhealthyThreshold
+		}
+		checks = append(checks, check)
+	}
+	redisUsed := (cfg.Cache.Enabled && cfg.Cache.Backend == "redis") ||
+		(cfg.RateLimit.Enabled && cfg.RateLimit.Backend == "redis")
+	if redisUsed {
+		checks = append(checks, health.Check{
+			Name:     "redis",
+			Critical: !informational["redis"],
+			Probe:    health.RedisProbe(cfg.Cache.Redis),
+		})
+	}
+	// The database is only a dependency of the audit log
+	if cfg.Audit.Enabled {
+		checks = append(checks, health.Check{
+			Name:     "database",
+			Critical: !informational["database"],
+			Probe:    health.DatabaseProbe(cfg.GetDatabaseDSN()),
+		})
+	}
+
+	for name := range informational {
+		if !slices.ContainsFunc(checks, func(check health.Check) bool { return check.Name == name }) {
+			log.Warn("Unknown informational dependency ignored", zap.String("dependency", name))
+		}
+	}
+
+	return health.NewChecker(checks, cfg.Health.CheckInterval, cfg.Health.CheckTimeout)
+}
+
+// SetupHealthRoutes adds /healthz, answered while the process is alive,
+// /readyz, which returns 503 until every critical dependency is up, and
+// /health, answered in the configured mode. Readiness comes from checker's
+// background probes, each under its own timeout, so no route waits on a
+// dependency.
+func SetupHealthRoutes(app *fiber.App, cfg *config.Config, checker *health.Checker) {
+	app.Get("/healthz", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "ok"})
+	})
+
+	app.Get("/readyz", func(c *fiber.Ctx) error {
+		response, ready := checker.Ready()
+		if !ready {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+		return c.JSON(response)
+	})
+
+	// Deep mode needs every critical dependency but the upstreams up, and any
+	// one of the upstreams. ?mode= overrides the configured mode, so liveness
+	// and readiness probes can share the endpoint.
+	upstreams := make([]string, len(cfg.Upstream.Services))
+	for i, service := range cfg.Upstream.Services {
+		upstreams[i] = service.Name
+	}
+	app.Get("/health", func(c *fiber.Ctx) error {
+		switch c.Query("mode", cfg.Health.Mode) {
+		case config.HealthModeLight:
+			return c.JSON(fiber.Map{"status": "ok", "gateway": "running"})
+		case config.HealthModeDeep:
+			response, healthy := checker.Healthy("upstreams", upstreams)
+			if !healthy {
+				c.Status(fiber.StatusServiceUnavailable)
+			}
+			return c.JSON(response)
+		default:
+			return middleware.NewError(fiber.StatusBadRequest, models.ErrCodeBadRequest, "mode must be light or deep")
+		}
+	})
 }
 
 // setupMonitoringRoutes adds monitoring/status endpoints
-func SetupMonitoringRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger) {
+func SetupMonitoringRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger, proxy *gateway.Proxy, inFlight *middleware.InFlightLimiter, responseCache cache.Cache) {
 	// Health status
 	app.Get("/monitor/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
 			"status":  "healthy",
 			"gateway": "ok",
+			"version": version.Version,
 		})
 	})
 
+	// Build of the running gateway, to confirm rollouts
+	app.Get("/version", func(c *fiber.Ctx) error {
+		return c.JSON(version.Get())
+	})
+
 	// Service metrics
 	app.Get("/monitor/metrics", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"requests_total":  1000,
-			"requests_failed": 5,
-			"avg_latency_ms":  45,
-		})
+		var queued int64
+		for name := range proxy.GetAllServiceStatus() {
+			queued += proxy.Queued(name)
+		}
+
+		summary, err := metrics.Summarize()
+		if err != nil {
+			middleware.RequestLogger(c, log).Warn("Metrics unavailable", zap.Error(err))
+		}
+
+		result := fiber.Map{
+			"requests_total":     summary.RequestsTotal,
+			"requests_failed":    summary.RequestsFailed,
+			"avg_latency_ms":     summary.AvgLatencyMs,
+			"upstream_errors":    summary.UpstreamErrors,
+			"proxy":              metrics.Proxy.Snapshot(),
+			"requests_in_flight": inFlight.InFlight(),
+			"upstream_coalesced": dedupedRequests.Load(),
+			"upstream_queued":    queued,
+			"cache_coalesced":    middleware.CoalescedRequests(),
+		}
+		// Non-zero while Redis is failing and the cache degrades to pass-through
+		if redisCache, ok := responseCache.(*cache.RedisCache); ok {
+			result["cache_backend_errors"] = redisCache.Errors()
+		}
+		return c.JSON(result)
 	})
 
-	// Dependency status
+	// Dependency status - circuit state, instances and last-minute outcomes per upstream
 	app.Get("/monitor/dependencies", func(c *fiber.Ctx) error {
+		return c.JSON(models.DependenciesResponse{Services: proxy.Dependencies()})
+	})
+
+	// Effective routing table, built from the proxy's live service state. It
+	// exposes the upstream topology, so only internal client IPs may read it.
+	internalOnly, err := middleware.IPAllowlistFiber(cfg.Server.InternalAllowedIPs)
+	if err != nil {
+		log.Fatal("Invalid internal IP allowlist", zap.Error(err))
+	}
+	app.Get("/monitor/routes", internalOnly, func(c *fiber.Ctx) error {
+		routes := proxy.Routes()
+
+		// The catch-all forwards to the default upstream, configured as a service or not
+		catchAll := -1
+		for i := range routes {
+			if routes[i].Targets[0] == defaultUpstreamURL {
+				catchAll = i
+				break
+			}
+		}
+		if catchAll < 0 {
+			route := gateway.RouteInfo(lookupService(cfg, defaultUpstreamURL))
+			route.Service = "default"
+			routes = append(routes, route)
+			catchAll = len(routes) - 1
+		}
+		routes[catchAll].PathPrefix = "/"
+
+		// The route table, as configured, decides which service gets a request first
 		return c.JSON(fiber.Map{
-			"nestjs": "connected",
+			"routes":      routes,
+			"route_table": cfg.Upstream.Routes,
 		})
 	})
 }
 
-// setupMetricsRoutes adds Prometheus-style metrics endpoints
-func SetupMetricsRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger) {
-	// Prometheus metrics endpoint
-	app.Get("/metrics", func(c *fiber.Ctx) error {
-		return c.SendString("# HELP requests_total Total requests\n# TYPE requests_total counter\nrequests_total 1000\n")
-	})
+// setupMetricsRoutes exposes the Prometheus collectors at /metrics
+func SetupMetricsRoutes(app *fiber.App, cfg *config.Config, log *zap.Logger, responseCache cache.Cache) {
+	if responseCache != nil {
+		collector := &cacheCollector{store: responseCache, backend: cfg.Cache.Backend, log: log}
+		if err := metrics.Registry.Register(collector); err != nil {
+			log.Warn("Cache metrics not registered", zap.Error(err))
+		}
+	}
+
+	handlers := []fiber.Handler{}
+	if len(cfg.Metrics.AllowedIPs) > 0 {
+		allowlist, err := middleware.IPAllowlistFiber(cfg.Metrics.AllowedIPs)
+		if err != nil {
+			log.Fatal("Invalid metrics allowlist", zap.Error(err))
+		}
+		handlers = append(handlers, allowlist)
+	}
+	handlers = append(handlers, adaptor.HTTPHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+
+	app.Get("/metrics", handlers...)
+}
+
+var (
+	cacheLookupsDesc   = prometheus.NewDesc("gateway_cache_lookups_total", "Cache lookups by result", []string{"result"}, nil)
+	cacheEntriesDesc   = prometheus.NewDesc("gateway_cache_entries", "Cached entries", nil, nil)
+	cacheBytesDesc     = prometheus.NewDesc("gateway_cache_bytes", "Approximate cached bytes", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("gateway_cache_evictions_total", "Entries evicted for space", nil, nil)
+)
+
+// cacheCollector reports response cache stats on every scrape
+type cacheCollector struct {
+	store   cache.Cache
+	backend string
+	log     *zap.Logger
+}
+
+func (cc *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheLookupsDesc
+	ch <- cacheEntriesDesc
+	ch <- cacheBytesDesc
+	ch <- cacheEvictionsDesc
+}
+
+func (cc *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := middleware.CacheStats(context.Background(), cc.store, cc.backend)
+	if err != nil {
+		cc.log.Warn("Cache backend stats unavailable", zap.Error(err))
+	}
+
+	for _, result := range []struct {
+		name  string
+		value int64
+	}{
+		{"hit", stats.Hits},
+		{"miss", stats.Misses},
+		{"stale", stats.Stale},
+		{"bypass", stats.Bypass},
+		{"coalesced", stats.Coalesced},
+		{"negative", stats.Negative},
+	} {
+		ch <- prometheus.MustNewConstMetric(cacheLookupsDesc, prometheus.CounterValue, float64(result.value), result.name)
+	}
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+	ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(stats.Bytes))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
 }
@@ -0,0 +1,253 @@
+package router
+
+import (
+	"io"
+	"main/internal/api/middleware"
+	"main/internal/config"
+	"main/internal/gateway"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jwtv4 "github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+)
+
+// newBackend answers with its name and the user the gateway forwarded
+func newBackend(t *testing.T, name string) string {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name+" "+r.Header.Get("X-User-ID"))
+	}))
+	t.Cleanup(backend.Close)
+	return backend.URL
+}
+
+func signedToken(t *testing.T, claims jwtv4.MapClaims) string {
+	t.Helper()
+	token, err := jwtv4.NewWithClaims(jwtv4.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRouteTable(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "secret"
+	cfg.JWT.ClaimHeaders = map[string]string{"user_id": "X-User-ID"}
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, MinRequests: 100, FailureRatio: 1}
+	cfg.Upstream.Services = []config.ServiceConfig{
+		{Name: "catalog", URL: newBackend(t, "catalog"), Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none"},
+		{Name: "orders", URL: newBackend(t, "orders"), Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none"},
+	}
+	cfg.Upstream.Routes = []config.RouteConfig{
+		{Path: "/api/catalog", Methods: []string{"GET"}, Service: "catalog", Auth: config.RouteAuthPublic},
+		{Path: "/api/orders", Service: "orders", Auth: config.RouteAuthRequired,
+			RateLimit: &config.RouteRateLimitConfig{RequestsPerMinute: 60, BurstSize: 1}},
+		{Path: "/api/orders/:id/cancel", Methods: []string{"POST"}, Service: "orders", Auth: config.RouteAuthRequired, Roles: []string{"admin"}},
+		{Path: "/api/reviews", Service: "catalog", Auth: config.RouteAuthOptional},
+		{Path: "/api/reports", Service: "orders", Auth: config.RouteAuthRequired, Scopes: []string{"reports:read"}},
+	}
+	proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+	SetupPublicRoutes(app, NewReloader(cfg, zap.NewNop()), zap.NewNop(), proxy, nil, middleware.NewMaintenance(time.Minute), nil)
+
+	user := signedToken(t, jwtv4.MapClaims{"user_id": "alice", "role": "user"})
+	admin := signedToken(t, jwtv4.MapClaims{"user_id": "root", "role": "admin"})
+	analyst := signedToken(t, jwtv4.MapClaims{"user_id": "ann", "scope": "orders:read reports:read"})
+	tests := []struct {
+		name, method, path, token string
+		status                    int
+		body                      string
+	}{
+		{"public route", "GET", "/api/catalog/items", "", 200, "catalog "},
+		{"public route ignores a token", "GET", "/api/catalog/items", "not-a-token", 200, "catalog "},
+		{"method the route doesn't take", "POST", "/api/catalog/items", user, 405, ""},
+		{"method refused only once authenticated", "POST", "/api/catalog/items", "", 401, ""},
+		{"required route", "GET", "/api/orders/1", "", 401, ""},
+		{"required route with a token", "GET", "/api/orders/1", user, 200, "orders alice"},
+		{"missing role", "POST", "/api/orders/1/cancel", user, 403, ""},
+		{"role held", "POST", "/api/orders/1/cancel", admin, 200, "orders root"},
+		{"optional route anonymously", "GET", "/api/reviews", "", 200, "catalog "},
+		{"optional route with a token", "GET", "/api/reviews", user, 200, "catalog alice"},
+		{"optional route with a bad token", "GET", "/api/reviews", "not-a-token", 401, ""},
+		{"missing scope", "GET", "/api/reports", user, 403, ""},
+		{"scope granted", "GET", "/api/reports", analyst, 200, "orders ann"},
+		{"route rate limit", "GET", "/api/orders/2", user, 429, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		// Anonymous callers can't pass an identity to the backend
+		req.Header.Set("X-User-ID", "mallory")
+		if tt.token != "" {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.token)
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != tt.status || (tt.body != "" && string(body) != tt.body) {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, resp.StatusCode, body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestRouteTableReload(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "secret"
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, MinRequests: 100, FailureRatio: 1}
+	cfg.Upstream.Services = []config.ServiceConfig{
+		{Name: "catalog", URL: newBackend(t, "catalog"), Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none"},
+	}
+	cfg.Upstream.Routes = []config.RouteConfig{
+		{Path: "/api/catalog", Service: "catalog", Auth: config.RouteAuthPublic},
+	}
+	proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := NewReloader(cfg, zap.NewNop())
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+	SetupPublicRoutes(app, reloader, zap.NewNop(), proxy, nil, middleware.NewMaintenance(time.Minute), nil)
+
+	status := func(path string) int {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	if got := status("/api/catalog"); got != fiber.StatusOK {
+		t.Fatalf("public route before reload: %d, want 200", got)
+	}
+
+	next := *cfg
+	next.Upstream.Routes = []config.RouteConfig{
+		{Path: "/api/catalog", Service: "catalog", Auth: config.RouteAuthPublic,
+			RateLimit: &config.RouteRateLimitConfig{RequestsPerMinute: 60, BurstSize: 1}},
+		// Its service only exists after a restart
+		{Path: "/api/billing", Service: "billing", Auth: config.RouteAuthPublic},
+	}
+	reloader.Apply(&next)
+
+	if got := status("/api/catalog"); got != fiber.StatusOK {
+		t.Errorf("first request after reload: %d, want 200", got)
+	}
+	if got := status("/api/catalog"); got != fiber.StatusTooManyRequests {
+		t.Errorf("second request after reload: %d, want the reloaded route limit's 429", got)
+	}
+	if got := status("/api/billing"); got != fiber.StatusServiceUnavailable {
+		t.Errorf("route to a service not running: %d, want 503", got)
+	}
+}
+
+func TestCORSPreflightBeforeAuth(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "secret"
+	cfg.CORS = config.CORSConfig{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}
+	cfg.Server.SecurityHeaders = config.SecurityHeadersConfig{Enabled: true, NoSniff: true}
+	cfg.Upstream.Services = []config.ServiceConfig{
+		{Name: "orders", URL: newBackend(t, "orders"), Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none"},
+	}
+	cfg.Upstream.Routes = []config.RouteConfig{{Path: "/api/orders", Service: "orders", Auth: config.RouteAuthRequired}}
+	proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := NewReloader(cfg, zap.NewNop())
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+	SetupCoreMiddleware(app, reloader, zap.NewNop(), nil, middleware.NewInFlightLimiter(0, 0), middleware.NewSlowRequests(0))
+	SetupPublicRoutes(app, reloader, zap.NewNop(), proxy, nil, middleware.NewMaintenance(time.Minute), nil)
+
+	tests := []struct {
+		name, method, origin string
+		status               int
+		allowOrigin          string
+		hardened             bool
+	}{
+		{"preflight", fiber.MethodOptions, "https://shop.example.com", 204, "https://shop.example.com", false},
+		{"preflight from another origin", fiber.MethodOptions, "https://evil.example.net", 204, "", false},
+		{"request still needs a token", fiber.MethodGet, "https://shop.example.com", 401, "https://shop.example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/orders", nil)
+			req.Header.Set(fiber.HeaderOrigin, tt.origin)
+			req.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodGet)
+			resp, err := app.Test(req, 5000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(fiber.HeaderAccessControlAllowOrigin); got != tt.allowOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.allowOrigin)
+			}
+			// Security headers come after CORS, so preflights go without them
+			if got := resp.Header.Get(fiber.HeaderXContentTypeOptions) == "nosniff"; got != tt.hardened {
+				t.Errorf("security headers set %v, want %v", got, tt.hardened)
+			}
+		})
+	}
+}
+
+func TestAPIKeyBypassesJWT(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "secret"
+	cfg.JWT.ClaimHeaders = map[string]string{"user_id": "X-User-ID"}
+	cfg.APIKeys.Enabled = true
+	cfg.APIKeys.Keys = []config.APIKeyEntry{{Key: "key-billing", ClientID: "billing", Role: "service"}}
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, MinRequests: 100, FailureRatio: 1}
+	cfg.Upstream.Services = []config.ServiceConfig{
+		{Name: "orders", URL: newBackend(t, "orders"), Timeout: 5 * time.Second, MaxRetry: 1, Affinity: "none"},
+	}
+	cfg.Upstream.Routes = []config.RouteConfig{
+		{Path: "/api/orders", Service: "orders", Auth: config.RouteAuthRequired},
+	}
+	proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandlerFiber})
+	SetupPublicRoutes(app, NewReloader(cfg, zap.NewNop()), zap.NewNop(), proxy, nil, middleware.NewMaintenance(time.Minute), nil)
+
+	user := signedToken(t, jwtv4.MapClaims{"user_id": "alice"})
+	tests := []struct {
+		name, key, token string
+		status           int
+		body             string
+	}{
+		{"API key without a token", "key-billing", "", 200, "orders billing"},
+		{"API key with a bad token", "key-billing", "not-a-token", 200, "orders billing"},
+		{"invalid API key", "key-other", user, 401, ""},
+		{"token alone", "", user, 200, "orders alice"},
+		{"neither", "", "", 401, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, "/api/orders/1", nil)
+		req.Header.Set("X-User-ID", "mallory")
+		if tt.key != "" {
+			req.Header.Set(middleware.APIKeyHeader, tt.key)
+		}
+		if tt.token != "" {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.token)
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != tt.status || (tt.body != "" && string(body) != tt.body) {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, resp.StatusCode, body, tt.status, tt.body)
+		}
+	}
+}
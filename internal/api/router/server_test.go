@@ -0,0 +1,158 @@
+package router
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"main/internal/api/middleware"
+	"main/internal/config"
+	"main/internal/gateway"
+	"main/internal/models"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// newServerApp builds an app with the Fiber settings of cfg
+func newServerApp(cfg *config.Config) *fiber.App {
+	appConfig := AppConfig(cfg)
+	appConfig.DisableStartupMessage = true
+	return fiber.New(appConfig)
+}
+
+// serve runs app on a local port until the test ends and returns its URL.
+// Connection timeouts only apply to real connections, not app.Test.
+func serve(t *testing.T, app *fiber.App) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + ln.Addr().String()
+}
+
+func TestAppConfigWriteTimeout(t *testing.T) {
+	t.Parallel()
+	cfg := &config.Config{}
+	cfg.Server.WriteTimeout = time.Second
+	app := newServerApp(cfg)
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			w.WriteString("start\n")
+			w.Flush()
+			time.Sleep(1500 * time.Millisecond)
+			w.WriteString("end\n")
+			w.Flush()
+		})
+		return nil
+	})
+
+	resp, err := http.Get(serve(t, app) + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err == nil || strings.Contains(string(body), "end") {
+		t.Fatalf("read %q, %v: want the response cut off at the write timeout", body, err)
+	}
+}
+
+func TestEventStreamOutlivesWriteTimeout(t *testing.T) {
+	t.Parallel()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(1500 * time.Millisecond)
+		w.Write([]byte("data: second\n\n"))
+	}))
+	defer backend.Close()
+
+	service := config.ServiceConfig{Name: "events", URL: backend.URL, Timeout: 10 * time.Second, MaxRetry: 1}
+	cfg := &config.Config{}
+	cfg.Server.WriteTimeout = time.Second
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, MinRequests: 100, FailureRatio: 1}
+	cfg.Upstream.Services = []config.ServiceConfig{service}
+	proxy, err := gateway.NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := newServerApp(cfg)
+	app.Get("/events", func(c *fiber.Ctx) error {
+		return ForwardRequest(c, cfg, proxy, service, c.Path(), zap.NewNop())
+	})
+
+	resp, err := http.Get(serve(t, app) + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || !strings.Contains(string(body), "data: second") {
+		t.Fatalf("read %q, %v: want the whole event stream", body, err)
+	}
+}
+
+func TestAppConfigBodyLimit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.BodyLimit = 16
+	app := newServerApp(cfg)
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	resp, err := http.Post(serve(t, app)+"/upload", "text/plain", strings.NewReader(strings.Repeat("x", 17)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := errorCode(t, resp); resp.StatusCode != fiber.StatusRequestEntityTooLarge || code != models.ErrCodeBodyTooLarge {
+		t.Fatalf("oversized body: %d %s, want 413 %s", resp.StatusCode, code, models.ErrCodeBodyTooLarge)
+	}
+}
+
+func TestAppConfigHeaderLimit(t *testing.T) {
+	cfg := &config.Config{}
+	// A buffer large enough that the header count limit, not parsing, refuses
+	cfg.Server.ReadBufferSize = 1 << 20
+	cfg.Server.MaxHeaderCount = 100
+	app := newServerApp(cfg)
+	SetupCoreMiddleware(app, NewReloader(cfg, zap.NewNop()), zap.NewNop(), nil, middleware.NewInFlightLimiter(0, 0), middleware.NewSlowRequests(0))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		headers int
+		status  int
+	}{
+		{50, fiber.StatusNoContent},
+		{10000, fiber.StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		for i := range tt.headers {
+			req.Header.Set(fmt.Sprintf("X-Header-%d", i), "v")
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Fatalf("%d headers: %d, want %d", tt.headers, resp.StatusCode, tt.status)
+		}
+		if tt.status != fiber.StatusNoContent {
+			if code := errorCode(t, resp); code != models.ErrCodeHeadersTooLarge {
+				t.Errorf("%d headers: code %s, want %s", tt.headers, code, models.ErrCodeHeadersTooLarge)
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package audit keeps a durable record of security-relevant events in
+// PostgreSQL. Events are queued and written in batches by a background writer,
+// so a slow or unavailable database never holds up a request.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"main/internal/config"
+	"main/internal/metrics"
+	"main/internal/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Audited events, stored as the entry's message
+const (
+	EventAuthFailure  = "auth_failure"
+	EventAdminRequest = "admin_request"
+	EventRouteError   = "route_error"
+)
+
+// writeTimeout bounds a migration or batch insert
+const writeTimeout = 5 * time.Second
+
+const schema = `
+CREATE TABLE IF NOT EXISTS audit_log (
+	id        BIGSERIAL PRIMARY KEY,
+	timestamp TIMESTAMPTZ NOT NULL,
+	level     TEXT NOT NULL,
+	app_id    TEXT NOT NULL DEFAULT '',
+	message   TEXT NOT NULL,
+	user_id   TEXT NOT NULL DEFAULT '',
+	fields    JSONB
+);
+CREATE INDEX IF NOT EXISTS audit_log_timestamp ON audit_log (timestamp);
+CREATE INDEX IF NOT EXISTS audit_log_user_id ON audit_log (user_id, timestamp);
+`
+
+// Log writes audit entries to the audit_log table. A nil *Log records nothing.
+type Log struct {
+	pool          *pgxpool.Pool
+	logger        *zap.Logger
+	queue         chan models.LogEntry
+	batchSize     int
+	flushInterval time.Duration
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// Owned by the writer goroutine
+	migrated bool
+	failing  bool
+}
+
+// New connects to the database at dsn and starts the writer. The schema is
+// migrated now, or by the writer once the database becomes reachable.
+func New(ctx context.Context, cfg config.AuditConfig, dsn string, log *zap.Logger) (*Log, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit database config: %w", err)
+	}
+
+	l := &Log{
+		pool:          pool,
+		logger:        log,
+		queue:         make(chan models.LogEntry, cfg.QueueSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	if err := l.migrate(ctx); err != nil {
+		log.Warn("Audit log schema not migrated, retrying when writing", zap.Error(err))
+	}
+
+	go l.run()
+	return l, nil
+}
+
+func (l *Log) migrate(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	if _, err := l.pool.Exec(ctx, schema); err != nil {
+		return err
+	}
+	l.migrated = true
+	return nil
+}
+
+// Record queues entry for writing without ever blocking; when the queue is
+// full the entry is dropped and counted
+func (l *Log) Record(entry models.LogEntry) {
+	if l == nil {
+		return
+	}
+
+	select {
+	case l.queue <- entry:
+	default:
+		metrics.AuditEventsDropped.WithLabelValues("queue_full").Inc()
+	}
+}
+
+// run writes queued entries in batches of batchSize, or whatever has queued up
+// every flushInterval
+func (l *Log) run() {
+	defer close(l.stopped)
+
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.LogEntry, 0, l.batchSize)
+	for {
+		select {
+		case entry := <-l.queue:
+			batch = append(batch, entry)
+			if len(batch) < l.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-l.stop:
+			// Write what is left before exiting
+			for {
+				select {
+				case entry := <-l.queue:
+					batch = append(batch, entry)
+					if len(batch) == l.batchSize {
+						l.write(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						l.write(batch)
+					}
+					return
+				}
+			}
+		}
+
+		l.write(batch)
+		batch = batch[:0]
+	}
+}
+
+// write inserts batch, counting it as dropped when the database fails.
+// Failures are logged when they start and stop, not on every batch.
+func (l *Log) write(batch []models.LogEntry) {
+	err := l.insert(batch)
+	if err != nil {
+		metrics.AuditEventsDropped.WithLabelValues("write_failed").Add(float64(len(batch)))
+		if !l.failing {
+			l.logger.Error("Audit log writes failing, dropping events", zap.Error(err))
+		}
+		l.failing = true
+		return
+	}
+	if l.failing {
+		l.logger.Info("Audit log writes recovered")
+	}
+	l.failing = false
+}
+
+func (l *Log) insert(batch []models.LogEntry) error {
+	if !l.migrated {
+		if err := l.migrate(context.Background()); err != nil {
+			return fmt.Errorf("migrate schema: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	rows := make([][]any, len(batch))
+	for i, entry := range batch {
+		fields, err := json.Marshal(entry.Fields)
+		if err != nil {
+			return err
+		}
+		userID, _ := entry.Fields["user_id"].(string)
+		rows[i] = []any{entry.Timestamp, entry.Level, entry.AppID, entry.Message, userID, fields}
+	}
+
+	_, err := l.pool.CopyFrom(ctx, pgx.Identifier{"audit_log"},
+		[]string{"timestamp", "level", "app_id", "message", "user_id", "fields"},
+		pgx.CopyFromRows(rows))
+	return err
+}
+
+// Close writes the queued entries, waiting at most until ctx is done, and
+// closes the database connections
+func (l *Log) Close(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.stopOnce.Do(func() { close(l.stop) })
+	defer l.pool.Close()
+
+	select {
+	case <-l.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Query selects entries for GET /admin/audit. Zero fields don't filter.
+type Query struct {
+	UserID string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	// Cursor continues from a previous page's NextCursor
+	Cursor string
+}
+
+// Page is one page of entries, newest first. NextCursor is empty on the last page.
+type Page struct {
+	Entries    []models.LogEntry `json:"entries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// Find returns the entries matching q, newest first
+func (l *Log) Find(ctx context.Context, q Query) (*Page, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if q.UserID != "" {
+		where("user_id = $%d", q.UserID)
+	}
+	if !q.From.IsZero() {
+		where("timestamp >= $%d", q.From)
+	}
+	if !q.To.IsZero() {
+		where("timestamp < $%d", q.To)
+	}
+	if q.Cursor != "" {
+		before, err := strconv.ParseInt(q.Cursor, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor %q", q.Cursor)
+		}
+		where("id < $%d", before)
+	}
+
+	sql := "SELECT id, timestamp, level, app_id, message, fields FROM audit_log"
+	if len(conditions) > 0 {
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// One extra row tells whether there is a next page
+	args = append(args, q.Limit+1)
+	sql += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := l.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &Page{Entries: []models.LogEntry{}}
+	var lastID int64
+	for rows.Next() {
+		var id int64
+		var entry models.LogEntry
+		if err := rows.Scan(&id, &entry.Timestamp, &entry.Level, &entry.AppID, &entry.Message, &entry.Fields); err != nil {
+			return nil, err
+		}
+		if len(page.Entries) == q.Limit {
+			page.NextCursor = strconv.FormatInt(lastID, 10)
+			break
+		}
+		page.Entries = append(page.Entries, entry)
+		lastID = id
+	}
+	return page, rows.Err()
+}
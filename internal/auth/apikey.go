@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"main/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// APIKeyIdentity is the service identity a valid API key authenticates as
+type APIKeyIdentity struct {
+	ClientID string
+	Role     string
+	Scopes   []string
+}
+
+type APIKeyValidator struct {
+	keys   []config.APIKeyEntry
+	logger *zap.Logger
+}
+
+func NewAPIKeyValidator(cfg *config.Config, log *zap.Logger) *APIKeyValidator {
+	return &APIKeyValidator{
+		keys:   cfg.APIKeys.Keys,
+		logger: log,
+	}
+}
+
+// ValidateKey returns the identity bound to the given API key.
+// Every configured key is compared in constant time to avoid timing leaks.
+func (v *APIKeyValidator) ValidateKey(key string) (*APIKeyIdentity, error) {
+	if key == "" {
+		return nil, fmt.Errorf("api key is empty")
+	}
+
+	// Hash first so the comparison doesn't depend on key length
+	given := sha256.Sum256([]byte(key))
+
+	var match *config.APIKeyEntry
+	for i := range v.keys {
+		expected := sha256.Sum256([]byte(v.keys[i].Key))
+		if subtle.ConstantTimeCompare(given[:], expected[:]) == 1 {
+			match = &v.keys[i]
+		}
+	}
+
+	if match == nil {
+		v.logger.Debug("API key rejected")
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	return &APIKeyIdentity{
+		ClientID: match.ClientID,
+		Role:     match.Role,
+		Scopes:   match.Scopes,
+	}, nil
+}
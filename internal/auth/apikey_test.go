@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"main/internal/config"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestValidateKey(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.APIKeys.Keys = []config.APIKeyEntry{
+		{Key: "key-billing", ClientID: "billing", Role: "service", Scopes: []string{"orders:read"}},
+		{Key: "key-reports", ClientID: "reports", Role: "admin"},
+	}
+	validator := NewAPIKeyValidator(cfg, zap.NewNop())
+
+	tests := map[string]struct {
+		key        string
+		wantClient string // "" when the key is refused
+	}{
+		"first key":            {key: "key-billing", wantClient: "billing"},
+		"second key":           {key: "key-reports", wantClient: "reports"},
+		"unknown key":          {key: "key-other"},
+		"prefix of a key":      {key: "key-bill"},
+		"key with a suffix":    {key: "key-billing2"},
+		"empty key":            {key: ""},
+		"differs only by case": {key: "KEY-BILLING"},
+	}
+	for name, tt := range tests {
+		identity, err := validator.ValidateKey(tt.key)
+		if tt.wantClient == "" {
+			if err == nil {
+				t.Errorf("%s: accepted as %+v", name, identity)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if identity.ClientID != tt.wantClient {
+			t.Errorf("%s: client %q, want %q", name, identity.ClientID, tt.wantClient)
+		}
+	}
+
+	identity, err := validator.ValidateKey("key-billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Role != "service" || !slices.Equal(identity.Scopes, []string{"orders:read"}) {
+		t.Errorf("identity = %+v, want the configured role and scopes", identity)
+	}
+}
+
+func TestValidateKeyWithoutKeys(t *testing.T) {
+	validator := NewAPIKeyValidator(&config.Config{}, zap.NewNop())
+	for _, key := range []string{"", "anything"} {
+		if identity, err := validator.ValidateKey(key); err == nil {
+			t.Errorf("%q accepted as %+v with no keys configured", key, identity)
+		}
+	}
+}
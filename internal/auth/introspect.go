@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+	"main/internal/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Introspect validates tokenString like ValidateToken and describes it,
+// including the reason it was rejected
+func (tv *TokenValidator) Introspect(tokenString string) models.TokenIntrospection {
+	var result models.TokenIntrospection
+
+	claims := jwt.MapClaims{}
+	if token, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err == nil {
+		result.Header = token.Header
+		result.Claims = claims
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			result.ExpiresAt = exp.UTC()
+			result.ExpiresInSeconds = int64(time.Until(exp.Time).Seconds())
+		}
+	}
+
+	if _, err := tv.ValidateToken(tokenString); err != nil {
+		result.Reason = TokenFailureReason(err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Valid = true
+	return result
+}
+
+// TokenFailureReason names the reason ValidateToken returned err
+func TokenFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, ErrTokenNotYetValid):
+		return "not_yet_valid"
+	case errors.Is(err, ErrTokenSignature):
+		return "bad_signature"
+	case errors.Is(err, ErrTokenIssuer):
+		return "wrong_issuer"
+	case errors.Is(err, ErrTokenAudience):
+		return "wrong_audience"
+	default:
+		return "malformed"
+	}
+}
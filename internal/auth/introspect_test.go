@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"main/internal/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+func TestIntrospect(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "secret"
+	cfg.JWT.Issuer = "janus"
+	cfg.JWT.Audience = "api"
+	validator := NewTokenValidator(cfg, zap.NewNop())
+
+	sign := func(claims jwt.MapClaims, key string) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{"user_id": "alice", "iss": "janus", "aud": "api", "exp": time.Now().Add(time.Hour).Unix()}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := map[string]struct {
+		token      string
+		wantReason string // "" for a valid token
+		wantClaims bool
+	}{
+		"valid":          {token: sign(claims(nil), "secret"), wantClaims: true},
+		"expired":        {token: sign(claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), "secret"), wantReason: "expired", wantClaims: true},
+		"not yet valid":  {token: sign(claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()}), "secret"), wantReason: "not_yet_valid", wantClaims: true},
+		"bad signature":  {token: sign(claims(nil), "other"), wantReason: "bad_signature", wantClaims: true},
+		"wrong issuer":   {token: sign(claims(jwt.MapClaims{"iss": "elsewhere"}), "secret"), wantReason: "wrong_issuer", wantClaims: true},
+		"wrong audience": {token: sign(claims(jwt.MapClaims{"aud": "web"}), "secret"), wantReason: "wrong_audience", wantClaims: true},
+		"malformed":      {token: "not-a-token", wantReason: "malformed"},
+	}
+	for name, tt := range tests {
+		result := validator.Introspect(tt.token)
+		if result.Valid != (tt.wantReason == "") || result.Reason != tt.wantReason {
+			t.Errorf("%s: valid %v, reason %q, want reason %q", name, result.Valid, result.Reason, tt.wantReason)
+		}
+		if tt.wantReason != "" && result.Error == "" {
+			t.Errorf("%s: rejected without an error", name)
+		}
+		// Claims are decoded even when the token is rejected
+		if got := result.Claims["user_id"] == "alice"; got != tt.wantClaims {
+			t.Errorf("%s: claims %v, want decoded %v", name, result.Claims, tt.wantClaims)
+		}
+	}
+
+	result := validator.Introspect(sign(claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), "secret"))
+	if result.ExpiresAt.IsZero() || result.ExpiresInSeconds >= 0 {
+		t.Errorf("expired token: expires at %v in %ds, want a time in the past", result.ExpiresAt, result.ExpiresInSeconds)
+	}
+}
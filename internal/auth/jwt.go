@@ -1,8 +1,10 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"main/internal/config"
+	"main/internal/redact"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -17,6 +19,16 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// Reasons ValidateToken rejects a token, matched with errors.Is
+var (
+	ErrTokenMalformed   = errors.New("malformed token")
+	ErrTokenSignature   = errors.New("bad signature")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotYetValid = errors.New("token not valid yet")
+	ErrTokenIssuer      = errors.New("invalid issuer")
+	ErrTokenAudience    = errors.New("invalid audience")
+)
+
 type TokenValidator struct {
 	config *config.Config
 	logger *zap.Logger
@@ -44,9 +56,9 @@ func (tv *TokenValidator) ValidateToken(tokenString string) (*Claims, error) {
 	if err != nil {
 		tv.logger.Debug("Token parsing failed",
 			zap.Error(err),
-			zap.String("token_preview", tokenString[:20]+"..."),
+			zap.String("token_fingerprint", redact.Fingerprint(tokenString)),
 		)
-		return nil, fmt.Errorf("invalid token: %w", err)
+		return nil, fmt.Errorf("invalid token: %w: %w", parseFailure(err), err)
 	}
 
 	if !token.Valid {
@@ -66,12 +78,12 @@ func (tv *TokenValidator) verifyClaims(claims *Claims) error {
 
 	// Check expiration
 	if claims.ExpiresAt != nil && claims.ExpiresAt.Unix() < now {
-		return fmt.Errorf("token has expired")
+		return ErrTokenExpired
 	}
 
 	// Check issuer if configured
 	if tv.config.JWT.Issuer != "" && claims.Issuer != tv.config.JWT.Issuer {
-		return fmt.Errorf("invalid issuer: expected %s, got %s", tv.config.JWT.Issuer, claims.Issuer)
+		return fmt.Errorf("%w: expected %s, got %s", ErrTokenIssuer, tv.config.JWT.Issuer, claims.Issuer)
 	}
 
 	// Check audience if configured
@@ -85,13 +97,27 @@ func (tv *TokenValidator) verifyClaims(claims *Claims) error {
 			}
 		}
 		if !found {
-			return fmt.Errorf("invalid audience")
+			return fmt.Errorf("%w: expected %s, got %v", ErrTokenAudience, tv.config.JWT.Audience, []string(audiences))
 		}
 	}
 
 	return nil
 }
 
+// parseFailure maps a jwt parsing error to the reason it was rejected
+func parseFailure(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ErrTokenSignature
+	default:
+		return ErrTokenMalformed
+	}
+}
+
 // GenerateToken generates a new JWT token (for testing/internal use)
 func (tv *TokenValidator) GenerateToken(userID, username, email, role string) (string, error) {
 	claims := &Claims{
@@ -100,7 +126,7 @@ func (tv *TokenValidator) GenerateToken(userID, username, email, role string) (s
 		Email:    email,
 		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(tv.config.JWT.ExpiresIn) * time.Second)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tv.config.JWT.ExpiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    tv.config.JWT.Issuer,
@@ -134,7 +160,7 @@ func ExtractToken(authHeader string) (string, error) {
 
 // RefreshToken generates a new token with extended expiration
 func (tv *TokenValidator) RefreshToken(claims *Claims) (string, error) {
-	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Duration(tv.config.JWT.ExpiresIn) * time.Second))
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(tv.config.JWT.ExpiresIn))
 	claims.IssuedAt = jwt.NewNumericDate(time.Now())
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Entry is a cached upstream response
+type Entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time
+	ExpiresAt  time.Time
+	// Revalidate marks no-cache entries that must be confirmed upstream before use
+	Revalidate bool
+	// Variants, when set, makes this a record of the Vary headers that select the
+	// actual response, which is stored under a key including their values
+	Variants []string
+}
+
+// Age returns how long ago the entry was stored
+func (e *Entry) Age() time.Duration {
+	return time.Since(e.StoredAt)
+}
+
+// Expired reports whether the entry's TTL has elapsed
+func (e *Entry) Expired() bool {
+	return time.Now().After(e.ExpiresAt)
+}
+
+// Size returns the approximate memory footprint of the entry in bytes
+func (e *Entry) Size() int {
+	size := len(e.Body)
+	for key, values := range e.Header {
+		size += len(key)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	return size
+}
+
+// Cache stores responses by key. Implementations may be process-local or shared.
+type Cache interface {
+	Get(ctx context.Context, key string) (*Entry, bool, error)
+	Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error
+	// Delete removes key and reports whether it was cached
+	Delete(ctx context.Context, key string) (bool, error)
+	// DeletePrefix removes every key starting with prefix and returns how many were removed
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+	// Flush removes every entry and returns how many were removed
+	Flush(ctx context.Context) (int, error)
+	// Stats reports the backend's size and evictions
+	Stats(ctx context.Context) (Stats, error)
+	// Keys lists up to limit keys starting with prefix, in key order after cursor.
+	// The returned cursor is empty once the listing is complete.
+	Keys(ctx context.Context, prefix, cursor string, limit int) ([]KeyInfo, string, error)
+}
+
+// Stats describes the contents of a cache backend
+type Stats struct {
+	Entries   int64
+	Bytes     int64
+	Evictions int64
+}
+
+// KeyInfo describes one cached key
+type KeyInfo struct {
+	Key  string
+	TTL  time.Duration
+	Size int
+}
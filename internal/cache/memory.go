@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process LRU cache bounded by entry count
+type MemoryCache struct {
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	bytes      int64
+	evictions  int64
+	mu         sync.Mutex
+}
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry for key unless it is missing or expired
+func (m *MemoryCache) Get(ctx context.Context, key string) (*Entry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, exists := m.items[key]
+	if !exists {
+		return nil, false, nil
+	}
+
+	item := elem.Value.(*memoryItem)
+	if item.entry.Expired() {
+		m.removeElement(elem)
+		return nil, false, nil
+	}
+
+	m.ll.MoveToFront(elem)
+	return item.entry, true, nil
+}
+
+// Set stores entry under key for ttl, evicting the least recently used entries when full
+func (m *MemoryCache) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.ExpiresAt = time.Now().Add(ttl)
+
+	if elem, exists := m.items[key]; exists {
+		item := elem.Value.(*memoryItem)
+		m.bytes += int64(entry.Size() - item.entry.Size())
+		item.entry = entry
+		m.ll.MoveToFront(elem)
+		return nil
+	}
+
+	m.items[key] = m.ll.PushFront(&memoryItem{key: key, entry: entry})
+	m.bytes += int64(entry.Size())
+
+	for m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		m.removeElement(m.ll.Back())
+		m.evictions++
+	}
+
+	return nil
+}
+
+// Delete removes key from the cache
+func (m *MemoryCache) Delete(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, exists := m.items[key]
+	if exists {
+		m.removeElement(elem)
+	}
+	return exists, nil
+}
+
+// DeletePrefix removes every key starting with prefix
+func (m *MemoryCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := 0
+	for key, elem := range m.items {
+		if strings.HasPrefix(key, prefix) {
+			m.removeElement(elem)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Flush empties the cache
+func (m *MemoryCache) Flush(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := m.ll.Len()
+	m.ll.Init()
+	m.items = make(map[string]*list.Element)
+	m.bytes = 0
+	return removed, nil
+}
+
+// Stats reports entry count, approximate byte size and LRU evictions
+func (m *MemoryCache) Stats(ctx context.Context) (Stats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return Stats{
+		Entries:   int64(m.ll.Len()),
+		Bytes:     m.bytes,
+		Evictions: m.evictions,
+	}, nil
+}
+
+// Keys lists cached keys in key order
+func (m *MemoryCache) Keys(ctx context.Context, prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for key := range m.items {
+		if strings.HasPrefix(key, prefix) && key > cursor {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	next := ""
+	if len(keys) > limit {
+		keys = keys[:limit]
+		next = keys[limit-1]
+	}
+
+	infos := make([]KeyInfo, len(keys))
+	for i, key := range keys {
+		entry := m.items[key].Value.(*memoryItem).entry
+		infos[i] = KeyInfo{Key: key, TTL: time.Until(entry.ExpiresAt), Size: entry.Size()}
+	}
+	return infos, next, nil
+}
+
+// Len returns the number of cached entries
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+func (m *MemoryCache) removeElement(elem *list.Element) {
+	item := elem.Value.(*memoryItem)
+	m.ll.Remove(elem)
+	delete(m.items, item.key)
+	m.bytes -= int64(item.entry.Size())
+}
@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"main/internal/config"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisBackoff is how long a failing Redis is skipped before it is tried again
+const redisBackoff = time.Second
+
+// RedisCache stores entries in Redis so every gateway process shares one cache.
+// A small local cache keeps recent hits and misses to spare Redis round trips,
+// and lookups pass through for a short while after Redis fails. Purges reach
+// other processes' local caches only once their local TTL runs out.
+//
+// Every key is also recorded in a sorted set with a constant score, so prefix
+// purges are a ZRANGEBYLEX range query instead of a scan of the keyspace.
+type RedisCache struct {
+	client   *redis.Client
+	prefix   string
+	index    string
+	local    *MemoryCache
+	localTTL time.Duration
+
+	errors    atomic.Uint64
+	downUntil atomic.Int64
+}
+
+func NewRedisCache(cfg config.RedisConfig, localSize int, localTTL time.Duration) *RedisCache {
+	return NewRedisStore(cfg, "cache", localSize, localTTL)
+}
+
+// NewRedisStore is a RedisCache whose keys live under namespace, apart from
+// the response cache and untouched by its purges
+func NewRedisStore(cfg config.RedisConfig, namespace string, localSize int, localTTL time.Duration) *RedisCache {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Host + ":" + cfg.Port,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		// Keep timeouts tight: a slow Redis must not stall every request
+		DialTimeout:  200 * time.Millisecond,
+		ReadTimeout:  100 * time.Millisecond,
+		WriteTimeout: 100 * time.Millisecond,
+		MaxRetries:   -1,
+	})
+
+	return &RedisCache{
+		client:   client,
+		prefix:   namespace + ":",
+		index:    namespace + "-index",
+		local:    NewMemoryCache(localSize),
+		localTTL: localTTL,
+	}
+}
+
+// Get returns the entry for key. A zero StatusCode in the local cache records a known miss.
+func (r *RedisCache) Get(ctx context.Context, key string) (*Entry, bool, error) {
+	if entry, found, _ := r.local.Get(ctx, key); found {
+		return entry, entry.StatusCode != 0, nil
+	}
+	if r.unavailable() {
+		return nil, false, nil
+	}
+
+	data, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		r.remember(ctx, key, &Entry{StoredAt: time.Now()}, r.localTTL)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, r.fail(err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, err
+	}
+	r.remember(ctx, key, &entry, time.Until(entry.ExpiresAt))
+	return &entry, true, nil
+}
+
+// Set stores entry under key and applies ttl with EXPIRE
+func (r *RedisCache) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	entry.ExpiresAt = entry.StoredAt.Add(ttl)
+	r.remember(ctx, key, entry, ttl)
+	if r.unavailable() {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, r.prefix+key, data, 0)
+	pipe.Expire(ctx, r.prefix+key, ttl)
+	pipe.ZAdd(ctx, r.index, redis.Z{Member: key})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return r.fail(err)
+	}
+	return nil
+}
+
+// Delete removes key from Redis and the local cache
+func (r *RedisCache) Delete(ctx context.Context, key string) (bool, error) {
+	r.local.Delete(ctx, key)
+
+	removed, err := r.deleteKeys(ctx, []string{key})
+	return removed > 0, err
+}
+
+// DeletePrefix removes every key starting with prefix
+func (r *RedisCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	r.local.DeletePrefix(ctx, prefix)
+
+	keys, err := r.client.ZRangeByLex(ctx, r.index, &redis.ZRangeBy{
+		Min: "[" + prefix,
+		Max: "[" + prefix + "\xff",
+	}).Result()
+	if err != nil {
+		return 0, r.fail(err)
+	}
+	return r.deleteKeys(ctx, keys)
+}
+
+// Flush removes every indexed entry
+func (r *RedisCache) Flush(ctx context.Context) (int, error) {
+	r.local.Flush(ctx)
+
+	keys, err := r.client.ZRange(ctx, r.index, 0, -1).Result()
+	if err != nil {
+		return 0, r.fail(err)
+	}
+	return r.deleteKeys(ctx, keys)
+}
+
+// Stats reports the indexed key count, which includes entries that expired since
+// the last purge, and the server-wide eviction count. Byte size is not tracked.
+func (r *RedisCache) Stats(ctx context.Context) (Stats, error) {
+	entries, err := r.client.ZCard(ctx, r.index).Result()
+	if err != nil {
+		return Stats{}, r.fail(err)
+	}
+
+	stats := Stats{Entries: entries}
+	info, err := r.client.Info(ctx, "stats").Result()
+	if err != nil {
+		return stats, r.fail(err)
+	}
+	for _, line := range strings.Split(info, "\r\n") {
+		if value, found := strings.CutPrefix(line, "evicted_keys:"); found {
+			stats.Evictions, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return stats, nil
+}
+
+// Keys pages through the index, so listings never scan the keyspace
+func (r *RedisCache) Keys(ctx context.Context, prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	min := "[" + prefix
+	if cursor > prefix {
+		min = "(" + cursor
+	}
+	keys, err := r.client.ZRangeByLex(ctx, r.index, &redis.ZRangeBy{
+		Min:   min,
+		Max:   "[" + prefix + "\xff",
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, "", r.fail(err)
+	}
+
+	pipe := r.client.Pipeline()
+	ttls := make([]*redis.DurationCmd, len(keys))
+	sizes := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		ttls[i] = pipe.PTTL(ctx, r.prefix+key)
+		sizes[i] = pipe.StrLen(ctx, r.prefix+key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, "", r.fail(err)
+	}
+
+	infos := make([]KeyInfo, 0, len(keys))
+	for i, key := range keys {
+		// Expired entries linger in the index until purged
+		if ttls[i].Val() < 0 {
+			continue
+		}
+		infos = append(infos, KeyInfo{Key: key, TTL: ttls[i].Val(), Size: int(sizes[i].Val())})
+	}
+
+	next := ""
+	if len(keys) == limit {
+		next = keys[len(keys)-1]
+	}
+	return infos, next, nil
+}
+
+// deleteKeys removes keys and their index entries. Index members of entries
+// that already expired are cleaned up here too but not counted.
+func (r *RedisCache) deleteKeys(ctx context.Context, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	redisKeys := make([]string, len(keys))
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = r.prefix + key
+		members[i] = key
+	}
+
+	pipe := r.client.TxPipeline()
+	deleted := pipe.Del(ctx, redisKeys...)
+	pipe.ZRem(ctx, r.index, members...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, r.fail(err)
+	}
+	return int(deleted.Val()), nil
+}
+
+// Errors returns the number of failed Redis operations
+func (r *RedisCache) Errors() uint64 {
+	return r.errors.Load()
+}
+
+func (r *RedisCache) Close() error {
+	return r.client.Close()
+}
+
+// remember keeps entry in the local cache for at most the local TTL
+func (r *RedisCache) remember(ctx context.Context, key string, entry *Entry, ttl time.Duration) {
+	if r.localTTL <= 0 {
+		return
+	}
+	ttl = min(ttl, r.localTTL)
+	local := *entry
+	r.local.Set(ctx, key, &local, ttl)
+}
+
+func (r *RedisCache) unavailable() bool {
+	return time.Now().UnixNano() < r.downUntil.Load()
+}
+
+func (r *RedisCache) fail(err error) error {
+	r.errors.Add(1)
+	r.downUntil.Store(time.Now().Add(redisBackoff).UnixNano())
+	return err
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"main/internal/config"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// unreachableRedis returns the address of a port nothing listens on
+func unreachableRedis(t *testing.T) config.RedisConfig {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+	return config.RedisConfig{Host: host, Port: port}
+}
+
+func TestRedisCacheDegradesWhenRedisIsDown(t *testing.T) {
+	ctx := context.Background()
+	r := NewRedisCache(unreachableRedis(t), 100, time.Minute)
+	defer r.Close()
+
+	if _, found, err := r.Get(ctx, "GET /missing"); found || err == nil {
+		t.Fatalf("first lookup: found %v, err %v; want a miss reporting the failure", found, err)
+	}
+	if r.Errors() != 1 {
+		t.Fatalf("errors = %d, want 1", r.Errors())
+	}
+
+	// While backing off, Redis is skipped: lookups are plain misses and
+	// writes still reach the local cache
+	if _, found, err := r.Get(ctx, "GET /missing"); found || err != nil {
+		t.Fatalf("lookup during backoff: found %v, err %v; want a silent miss", found, err)
+	}
+	entry := &Entry{StatusCode: http.StatusOK, Body: []byte("items"), StoredAt: time.Now()}
+	if err := r.Set(ctx, "GET /items", entry, time.Minute); err != nil {
+		t.Fatalf("store during backoff: %v", err)
+	}
+	got, found, err := r.Get(ctx, "GET /items")
+	if err != nil || !found || string(got.Body) != "items" {
+		t.Fatalf("local hit: %v %v %v", got, found, err)
+	}
+	if r.Errors() != 1 {
+		t.Fatalf("errors = %d, want Redis left alone during backoff", r.Errors())
+	}
+}
+
+// TestRedisCacheSharesEntries needs a Redis server at TEST_REDIS_ADDR
+func TestRedisCacheSharesEntries(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	cfg := config.RedisConfig{Host: host, Port: port}
+
+	// Two processes: without local caches every lookup reaches Redis
+	writer, reader := NewRedisCache(cfg, 100, 0), NewRedisCache(cfg, 100, 0)
+	defer writer.Close()
+	defer reader.Close()
+	writer.prefix, reader.prefix = "test-cache:", "test-cache:"
+	writer.index, reader.index = "test-cache-index", "test-cache-index"
+	defer writer.Flush(ctx)
+
+	entry := &Entry{StatusCode: http.StatusOK, Body: []byte("items"), StoredAt: time.Now()}
+	if err := writer.Set(ctx, "GET /catalog/items", entry, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	got, found, err := reader.Get(ctx, "GET /catalog/items")
+	if err != nil || !found || string(got.Body) != "items" {
+		t.Fatalf("shared lookup: %v %v %v", got, found, err)
+	}
+
+	removed, err := reader.DeletePrefix(ctx, "GET /catalog")
+	if err != nil || removed != 1 {
+		t.Fatalf("purge removed %d, err %v", removed, err)
+	}
+	if _, found, _ := writer.Get(ctx, "GET /catalog/items"); found {
+		t.Fatal("purged entry still served")
+	}
+}
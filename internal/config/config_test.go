@@ -0,0 +1,430 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadAppliesServiceDefaults(t *testing.T) {
+	t.Setenv("UPSTREAM_DEFAULT_TIMEOUT", "12")
+	t.Setenv("UPSTREAM_DEFAULT_MAX_RETRY", "4")
+	t.Setenv("UPSTREAM_SERVICE_COUNT", "2")
+	t.Setenv("UPSTREAM_SERVICE_0_NAME", "orders")
+	t.Setenv("UPSTREAM_SERVICE_0_URL", "http://orders:3000")
+	t.Setenv("UPSTREAM_SERVICE_1_NAME", "payments")
+	t.Setenv("UPSTREAM_SERVICE_1_URL", "http://payments:3000")
+	t.Setenv("UPSTREAM_SERVICE_1_TIMEOUT", "5")
+	t.Setenv("UPSTREAM_SERVICE_1_MAX_RETRY", "1")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Upstream.Services) != 2 {
+		t.Fatalf("loaded %d services, want 2", len(cfg.Upstream.Services))
+	}
+	if orders := cfg.Upstream.Services[0]; orders.Timeout != 12*time.Second || orders.MaxRetry != 4 {
+		t.Errorf("orders = %v/%d, want the defaults 12s/4", orders.Timeout, orders.MaxRetry)
+	}
+	if payments := cfg.Upstream.Services[1]; payments.Timeout != 5*time.Second || payments.MaxRetry != 1 {
+		t.Errorf("payments = %v/%d, want its own 5s/1", payments.Timeout, payments.MaxRetry)
+	}
+}
+
+func TestLoadAppliesServiceDefaultsToServicesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	services := "- name: orders\n  url: http://orders:3000\n- name: payments\n  url: http://payments:3000\n  timeout: 5\n"
+	if err := os.WriteFile(path, []byte(services), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("UPSTREAM_SERVICES_FILE", path)
+	t.Setenv("UPSTREAM_DEFAULT_TIMEOUT", "12")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Upstream.Services[0].Timeout; got != 12*time.Second {
+		t.Errorf("orders timeout = %v, want the default 12s", got)
+	}
+	if got := cfg.Upstream.Services[1].Timeout; got != 5*time.Second {
+		t.Errorf("payments timeout = %v, want its own 5s", got)
+	}
+}
+
+func TestValidateRejectsBadServiceTimeouts(t *testing.T) {
+	tests := map[string]struct {
+		env  map[string]string
+		want string
+	}{
+		"zero default timeout": {map[string]string{"UPSTREAM_DEFAULT_TIMEOUT": "0"}, "default upstream timeout"},
+		"negative service retry": {map[string]string{
+			"UPSTREAM_SERVICE_0_NAME": "orders", "UPSTREAM_SERVICE_0_URL": "http://orders:3000", "UPSTREAM_SERVICE_0_MAX_RETRY": "-1",
+		}, "must not be negative"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("ENVIRONMENT", "development")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadServiceAllowedMethods(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("UPSTREAM_SERVICE_0_NAME", "replica")
+	t.Setenv("UPSTREAM_SERVICE_0_ALLOWED_METHODS", "get,Head")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Upstream.Services[0].AllowedMethods; strings.Join(got, ",") != "GET,HEAD" {
+		t.Fatalf("allowed methods %v, want them upper-cased", got)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Upstream.Services[0].AllowedMethods = []string{"GET", "FETCH"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `unknown allowed method "FETCH"`) {
+		t.Fatalf("Validate = %v, want the unknown method rejected", err)
+	}
+}
+
+func TestLoadServiceHealthCheck(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, `
+health:
+  check_interval: 10s
+  check_timeout: 3s
+upstream:
+  services:
+    - name: orders
+      url: http://orders:3000
+      healthcheck:
+        path: /status
+    - name: legacy
+      url: http://legacy:8080
+      healthcheck:
+        path: /
+        method: head
+        expected_status: [204]
+        interval: 30s
+        unhealthy_threshold: 3
+    - name: payments
+      url: http://payments:3000
+`))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	orders, legacy, payments := cfg.Upstream.Services[0], cfg.Upstream.Services[1], cfg.Upstream.Services[2]
+	want := HealthCheckConfig{Path: "/status", Method: "GET", Interval: 10 * time.Second, Timeout: 3 * time.Second, HealthyThreshold: 1, UnhealthyThreshold: 1}
+	if got := *orders.HealthCheck; !reflect.DeepEqual(got, want) {
+		t.Errorf("orders health check = %+v, want the defaults %+v", got, want)
+	}
+	if got := legacy.HealthCheck; got.Method != "HEAD" || got.Interval != 30*time.Second || got.UnhealthyThreshold != 3 ||
+		!got.Healthy(204) || got.Healthy(200) {
+		t.Errorf("legacy health check = %+v, want HEAD every 30s passing only on 204", got)
+	}
+	if payments.HealthCheck != nil {
+		t.Errorf("payments health check = %+v, want none", payments.HealthCheck)
+	}
+
+	tests := map[string]struct {
+		modify func(*HealthCheckConfig)
+		want   string
+	}{
+		"unknown method":      {func(h *HealthCheckConfig) { h.Method = "PING" }, `unknown health check method "PING"`},
+		"interval < timeout":  {func(h *HealthCheckConfig) { h.Interval = time.Second }, "interval 1s is shorter than its timeout 3s"},
+		"relative path":       {func(h *HealthCheckConfig) { h.Path = "status" }, "path must start with /"},
+		"bad expected status": {func(h *HealthCheckConfig) { h.ExpectedStatus = []int{42} }, "expected status 42"},
+		"zero threshold":      {func(h *HealthCheckConfig) { h.HealthyThreshold = 0 }, "thresholds must be at least 1"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			check := *orders.HealthCheck
+			tt.modify(&check)
+			cfg.Upstream.Services[0].HealthCheck = &check
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRetryPolicy(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, `
+upstream:
+  retry:
+    retry_on: [connection_error, 5xx]
+    backoff_base_ms: 50
+  services:
+    - name: orders
+      url: http://orders:3000
+      maxretry: 2
+    - name: payments
+      url: http://payments:3000
+      timeout: 5s
+      retry:
+        attempts: 4
+        statuses: [429]
+        jitter: 0.2
+        deadline: 3s
+  routes:
+    - path: /payments/refunds
+      service: payments
+      retry:
+        attempts: 1
+`))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	orders, payments := cfg.Upstream.Services[0], cfg.Upstream.Services[1]
+	want := RetryPolicy{
+		Attempts: 2, RetryOn: []string{RetryOnConnectionError, RetryOn5xx},
+		BackoffBase: 50 * time.Millisecond, BackoffCap: 2 * time.Second,
+	}
+	if got := *orders.Retry; !reflect.DeepEqual(got, want) {
+		t.Errorf("orders retry = %+v, want the global policy with its MaxRetry %+v", got, want)
+	}
+	want.Attempts, want.Statuses, want.Jitter, want.Deadline = 4, []int{429}, 0.2, 3*time.Second
+	if got := *payments.Retry; !reflect.DeepEqual(got, want) || payments.MaxRetry != 4 {
+		t.Errorf("payments retry = %+v, max retry %d, want %+v", got, payments.MaxRetry, want)
+	}
+	want.Attempts = 1
+	if got := *cfg.Upstream.Routes[0].Retry; !reflect.DeepEqual(got, want) {
+		t.Errorf("route retry = %+v, want the service's policy with one attempt %+v", got, want)
+	}
+
+	tests := map[string]struct {
+		modify func(*RetryPolicy)
+		want   string
+	}{
+		"too many attempts":   {func(p *RetryPolicy) { p.Attempts = 11 }, "attempts must be between 1 and 10"},
+		"unknown condition":   {func(p *RetryPolicy) { p.RetryOn = []string{"4xx"} }, `unknown retry condition "4xx"`},
+		"bad status":          {func(p *RetryPolicy) { p.Statuses = []int{42} }, "retry status 42"},
+		"cap below base":      {func(p *RetryPolicy) { p.BackoffCap = time.Millisecond }, "cap at least the base"},
+		"jitter above one":    {func(p *RetryPolicy) { p.Jitter = 1.5 }, "jitter must be between 0 and 1"},
+		"deadline past limit": {func(p *RetryPolicy) { p.Deadline = 10 * time.Second }, "longer than the timeout 5s"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			policy := *payments.Retry
+			tt.modify(&policy)
+			cfg.Upstream.Services[1].Retry = &policy
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRoutesFromServicesFile(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	servicesFile := filepath.Join(t.TempDir(), "services.yaml")
+	if err := os.WriteFile(servicesFile, []byte(`
+services:
+  - name: catalog
+    url: http://catalog:3000
+routes:
+  - path: /api/catalog
+    methods: [get]
+    service: catalog
+    auth: public
+    cache_ttl: 5m
+  - path: /api/catalog/:id
+    methods: [put]
+    service: catalog
+    roles: [admin]
+    timeout: 2s
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("UPSTREAM_SERVICES_FILE", servicesFile)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	routes := cfg.Upstream.Routes
+	if len(cfg.Upstream.Services) != 1 || len(routes) != 2 {
+		t.Fatalf("services %v, routes %v", cfg.Upstream.Services, routes)
+	}
+	if routes[0].Methods[0] != "GET" || routes[1].Auth != RouteAuthRequired || routes[1].Timeout != 2*time.Second {
+		t.Errorf("routes not normalized: %+v", routes)
+	}
+	// Cached routes join the cached paths
+	if !slices.Contains(cfg.Cache.Paths, "/api/catalog") || cfg.Cache.PathTTLs["/api/catalog"] != 5*time.Minute {
+		t.Errorf("cache paths %v, TTLs %v", cfg.Cache.Paths, cfg.Cache.PathTTLs)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Upstream.Routes = append(cfg.Upstream.Routes,
+		RouteConfig{Path: "/api/catalog", Methods: []string{"GET", "POST"}, Service: "catalog", Auth: RouteAuthRequired},
+		RouteConfig{Path: "/api/search", Service: "search", Auth: "anyone"},
+		RouteConfig{Path: "/api/catalog/:id/*", Service: "catalog", Auth: RouteAuthPublic, CacheTTL: time.Minute},
+	)
+	err = cfg.Validate()
+	for _, want := range []string{
+		"route 2 (/api/catalog): duplicates another route for GET /api/catalog",
+		`route 3 (/api/search): unknown service "search"`,
+		"route 3 (/api/search): auth must be required, optional or public",
+		"route 4 (/api/catalog/:id/*): only prefix routes can be cached",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want mention of %q", err, want)
+		}
+	}
+}
+
+func writeEnvFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	t.Setenv("TEST_ENV_PRESET", "from process")
+	path := writeEnvFile(t, "TEST_ENV_LOADED=from file\nTEST_ENV_PRESET=from file\n")
+	t.Cleanup(func() { os.Unsetenv("TEST_ENV_LOADED") })
+
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatalf("LoadEnvFile: %v", err)
+	}
+	if got := os.Getenv("TEST_ENV_LOADED"); got != "from file" {
+		t.Errorf("TEST_ENV_LOADED = %q, want it loaded", got)
+	}
+	// Variables already set win over the file
+	if got := os.Getenv("TEST_ENV_PRESET"); got != "from process" {
+		t.Errorf("TEST_ENV_PRESET = %q, want it left alone", got)
+	}
+}
+
+func TestLoadEnvFileFromEnv(t *testing.T) {
+	t.Setenv("ENV_FILE", writeEnvFile(t, "TEST_ENV_SELECTED=yes\n"))
+	t.Cleanup(func() { os.Unsetenv("TEST_ENV_SELECTED") })
+
+	if err := LoadEnvFile(""); err != nil {
+		t.Fatalf("LoadEnvFile: %v", err)
+	}
+	if got := os.Getenv("TEST_ENV_SELECTED"); got != "yes" {
+		t.Errorf("TEST_ENV_SELECTED = %q, want the ENV_FILE file loaded", got)
+	}
+}
+
+func TestLoadEnvFileErrors(t *testing.T) {
+	// Without an explicit file a missing .env is fine
+	t.Chdir(t.TempDir())
+	if err := LoadEnvFile(""); err != nil {
+		t.Fatalf("missing default .env: %v", err)
+	}
+
+	if err := LoadEnvFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("expected an error for a missing explicit file")
+	}
+	if err := LoadEnvFile(writeEnvFile(t, "TEST_ENV_BROKEN=\"unterminated\n")); err == nil {
+		t.Error("expected an error for a malformed file")
+	}
+}
+
+// clearEnv unsets every environment variable for the rest of the test, so
+// Load sees none of the runner's settings; t.Setenv restores them afterwards.
+// Clearing all of them also covers the per-service and _FILE variables whose
+// names depend on the configuration.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestLoadEmptyEnvironmentIsRunnable(t *testing.T) {
+	clearEnv(t)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// Only the JWT secret has no default
+	err = cfg.Validate()
+	if err == nil || strings.Count(err.Error(), "\n") != 0 || !strings.Contains(err.Error(), "JWT_SECRET_KEY") {
+		t.Fatalf("Validate = %v, want only the missing JWT secret", err)
+	}
+	cfg.JWT.SecretKey = "secret"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate with a secret: %v", err)
+	}
+
+	if cfg.Server.Port != "8080" || cfg.Server.ReadTimeout != 15*time.Second || cfg.Server.WriteTimeout != 15*time.Second ||
+		cfg.Server.IdleTimeout != time.Minute {
+		t.Errorf("server = %+v", cfg.Server)
+	}
+	if cfg.JWT.ExpiresIn != time.Hour || cfg.Logging.Level != "info" || cfg.Cache.TTL != time.Minute || cfg.RateLimit.RequestsPerMinute != 120 {
+		t.Errorf("expiry %v, log level %q, cache TTL %v, rate limit %d",
+			cfg.JWT.ExpiresIn, cfg.Logging.Level, cfg.Cache.TTL, cfg.RateLimit.RequestsPerMinute)
+	}
+	if !strings.Contains(cfg.String(), `"server.port"`) {
+		t.Errorf("defaulted settings missing from String(): %s", cfg.String())
+	}
+
+	// Enabling the optional features on their defaults still validates
+	t.Setenv("RATE_LIMIT_ENABLED", "true")
+	t.Setenv("CACHE_ENABLED", "true")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg.JWT.SecretKey = "secret"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate with rate limiting and caching: %v", err)
+	}
+}
+
+func TestLoadKeepsSetValuesOverDefaults(t *testing.T) {
+	t.Setenv("SERVER_PORT", "9090")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != "9090" || cfg.Logging.Level != "warn" {
+		t.Fatalf("port %q, log level %q: want the environment's", cfg.Server.Port, cfg.Logging.Level)
+	}
+	if strings.Contains(cfg.String(), `"server.port"`) {
+		t.Fatal("a set port is reported as defaulted")
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// parseDuration accepts a Go duration string such as "30s" or "5m", or a bare
+// integer counted in unit, as these settings were before durations
+func parseDuration(value string, unit time.Duration) (time.Duration, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+// fieldUnit is the unit of bare integers for a duration field: seconds, or
+// milliseconds for fields tagged unit:"ms"
+func fieldUnit(field reflect.StructField) time.Duration {
+	if field.Tag.Get("unit") == "ms" {
+		return time.Millisecond
+	}
+	return time.Second
+}
+
+// getEnvDuration reads a duration from key, keeping defaultValue when it is
+// unset or malformed
+func (c *Config) getEnvDuration(key string, unit, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := parseDuration(value, unit)
+	if err != nil {
+		c.malformedEnv(key, "", "duration", value)
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvDurationMap reads "key=duration" pairs separated by commas, skipping
+// entries without a key; malformed durations are recorded and skipped
+func (c *Config) getEnvDurationMap(key string, defaultValue map[string]time.Duration) map[string]time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result := make(map[string]time.Duration)
+	for _, entry := range parseStringSlice(value) {
+		name, durationStr, found := strings.Cut(entry, "=")
+		if !found {
+			continue
+		}
+		d, err := parseDuration(strings.TrimSpace(durationStr), time.Second)
+		if err != nil {
+			c.malformedEnv(key, strings.TrimSpace(name), "duration", strings.TrimSpace(durationStr))
+			continue
+		}
+		result[strings.TrimSpace(name)] = d
+	}
+	return result
+}
+
+// normalizeDurations rewrites the duration values in the YAML node decoded
+// into t as duration strings, so bare integers keep their unit. A value that
+// doesn't parse is recorded for Validate under its dotted key, and cleared
+// so the rest of the file still decodes.
+func (c *Config) normalizeDurations(node *yaml.Node, t reflect.Type, unit time.Duration, key string) {
+	if node.Kind == yaml.DocumentNode {
+		for _, child := range node.Content {
+			c.normalizeDurations(child, t, unit, key)
+		}
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() == "!!null" {
+			return
+		}
+		d, err := parseDuration(node.Value, unit)
+		if err != nil {
+			c.invalid = append(c.invalid, fmt.Errorf("%s: %w", key, err))
+		}
+		node.Value, node.Tag, node.Style = d.String(), "!!str", 0
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.StructField)
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			fields[name] = field
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i].Value
+			if field, ok := fields[name]; ok {
+				c.normalizeDurations(node.Content[i+1], field.Type, fieldUnit(field), joinKey(key, name))
+			}
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			c.normalizeDurations(node.Content[i+1], t.Elem(), unit, joinKey(key, node.Content[i].Value))
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, child := range node.Content {
+			c.normalizeDurations(child, t.Elem(), unit, fmt.Sprintf("%s[%d]", key, i))
+		}
+	}
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
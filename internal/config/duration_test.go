@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDurations(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, `
+server:
+  read_timeout: 30s
+  write_timeout: 20
+  in_flight_queue_timeout_ms: 250
+cache:
+  path_ttls:
+    /catalog: 5m
+    /prices: 10
+health:
+  startup_wait:
+    timeout: 90
+    backoff_base_ms: 50
+upstream:
+  services:
+    - name: orders
+      url: http://orders:3000
+      timeout: 1m30s
+      queuetimeout: 100
+`))
+	t.Setenv("SERVER_IDLE_TIMEOUT", "2m")
+	t.Setenv("CACHE_LOCAL_TTL_MS", "500")
+	t.Setenv("HEALTH_STARTUP_BACKOFF_CAP_MS", "2000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// Bare integers keep their old unit: seconds, or milliseconds for _ms keys
+	tests := map[string]struct {
+		got, want time.Duration
+	}{
+		"read timeout":        {cfg.Server.ReadTimeout, 30 * time.Second},
+		"write timeout":       {cfg.Server.WriteTimeout, 20 * time.Second},
+		"idle timeout":        {cfg.Server.IdleTimeout, 2 * time.Minute},
+		"queue timeout":       {cfg.Server.InFlightQueueTimeout, 250 * time.Millisecond},
+		"catalog TTL":         {cfg.Cache.PathTTLs["/catalog"], 5 * time.Minute},
+		"prices TTL":          {cfg.Cache.PathTTLs["/prices"], 10 * time.Second},
+		"local TTL":           {cfg.Cache.LocalTTL, 500 * time.Millisecond},
+		"service timeout":     {cfg.Upstream.Services[0].Timeout, 90 * time.Second},
+		"service queue limit": {cfg.Upstream.Services[0].QueueTimeout, 100 * time.Millisecond},
+		"startup wait":        {cfg.Health.StartupWait.Timeout, 90 * time.Second},
+		"startup backoff":     {cfg.Health.StartupWait.BackoffBase, 50 * time.Millisecond},
+		"startup backoff cap": {cfg.Health.StartupWait.BackoffCap, 2 * time.Second},
+	}
+	for name, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestInvalidDurationsNameTheKey(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("CONFIG_STRICT_ENV", "true")
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "health:\n  check_interval: soon\n"))
+	servicesFile := filepath.Join(t.TempDir(), "services.yaml")
+	if err := os.WriteFile(servicesFile, []byte("- name: orders\n  url: http://orders:3000\n  timeout: 5 s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("UPSTREAM_SERVICES_FILE", servicesFile)
+	t.Setenv("SERVER_REQUEST_TIMEOUT", "1 minute")
+	t.Setenv("CACHE_PATH_TTLS", "/catalog=forever")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted invalid durations")
+	}
+	for _, want := range []string{
+		`health.check_interval: invalid duration "soon"`,
+		`upstream.services[0].timeout: invalid duration "5 s"`,
+		`SERVER_REQUEST_TIMEOUT: invalid duration "1 minute"`,
+		`CACHE_PATH_TTLS: /catalog: invalid duration "forever"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want mention of %s", err, want)
+		}
+	}
+}
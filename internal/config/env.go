@@ -0,0 +1,42 @@
+package config
+
+import "fmt"
+
+// EnvError describes an environment variable whose value doesn't parse as
+// the type its setting needs
+type EnvError struct {
+	Key string
+	// Entry names the entry within a list of key=value pairs, if any
+	Entry string
+	Type  string
+	Value string
+}
+
+func (e *EnvError) Error() string {
+	key := e.Key
+	if e.Entry != "" {
+		key += ": " + e.Entry
+	}
+	return fmt.Sprintf("%s: invalid %s %q", key, e.Type, e.Value)
+}
+
+// malformedEnv records an environment variable that didn't parse; the
+// setting keeps its previous value
+func (c *Config) malformedEnv(key, entry, typ, value string) {
+	c.malformed = append(c.malformed, &EnvError{Key: key, Entry: entry, Type: typ, Value: value})
+}
+
+// MalformedEnv returns the environment variables that didn't parse and were
+// ignored. Outside strict mode they don't fail Validate, so log them.
+func (c *Config) MalformedEnv() []error {
+	return c.malformed
+}
+
+// strictEnv reports whether malformed environment variables fail Validate:
+// as StrictEnv says, or outside development when it is unset
+func (c *Config) strictEnv() bool {
+	if c.StrictEnv != nil {
+		return *c.StrictEnv
+	}
+	return c.Environment != "development"
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMalformedEnv(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", "secret")
+	t.Setenv("RATE_LIMIT_REQUESTS_PER_MINUTE", "60/min")
+	t.Setenv("RATE_LIMIT_ENABLED", "enabled")
+	t.Setenv("RATE_LIMIT_ROUTE_COSTS", "/api/search=5,/api/export=lots")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// The settings keep their defaults
+	if cfg.RateLimit.RequestsPerMinute != 120 || cfg.RateLimit.Enabled || cfg.RateLimit.RouteCosts["/api/search"] != 5 {
+		t.Errorf("rate limit = %+v", cfg.RateLimit)
+	}
+
+	var envErr *EnvError
+	if malformed := cfg.MalformedEnv(); len(malformed) != 3 || !errors.As(malformed[0], &envErr) {
+		t.Fatalf("MalformedEnv = %v", malformed)
+	}
+	// Strict by default outside development
+	err = cfg.Validate()
+	for _, want := range []string{
+		`RATE_LIMIT_REQUESTS_PER_MINUTE: invalid integer "60/min"`,
+		`RATE_LIMIT_ENABLED: invalid boolean "enabled"`,
+		`RATE_LIMIT_ROUTE_COSTS: /api/export: invalid integer "lots"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want mention of %s", err, want)
+		}
+	}
+
+	tests := map[string]map[string]string{
+		"development":      {"ENVIRONMENT": "development"},
+		"strictness off":   {"CONFIG_STRICT_ENV": "off"},
+		"strictness false": {"ENVIRONMENT": "production", "CONFIG_STRICT_ENV": "false"},
+	}
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("Validate = %v, want malformed variables only logged", err)
+			}
+			if len(cfg.MalformedEnv()) != 3 {
+				t.Errorf("MalformedEnv = %v", cfg.MalformedEnv())
+			}
+		})
+	}
+}
+
+func TestGetEnvBool(t *testing.T) {
+	tests := map[string]bool{
+		"true": true, "TRUE": true, "1": true, "yes": true, "On": true,
+		"false": false, "False": false, "0": false, "no": false, "OFF": false,
+	}
+	for value, want := range tests {
+		t.Setenv("TEST_BOOL", value)
+		c := &Config{}
+		if got := c.getEnvBool("TEST_BOOL", !want); got != want || len(c.malformed) != 0 {
+			t.Errorf("%q = %v (malformed %v), want %v", value, got, c.malformed, want)
+		}
+	}
+
+	t.Setenv("TEST_BOOL", "y")
+	c := &Config{}
+	if !c.getEnvBool("TEST_BOOL", true) || len(c.malformed) != 1 {
+		t.Errorf("unrecognized value: malformed %v, want the default kept and recorded", c.malformed)
+	}
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envReference matches ${VAR} in configuration file values; $$ is a literal $
+var envReference = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// loadFile reads the YAML configuration at path over the current settings,
+// deep-merged with the overlay for the environment next to it when one exists:
+// gateway.production.yaml for gateway.yaml with ENVIRONMENT=production, or the
+// environment the base file sets. The overlay's mappings merge into the base
+// file's; its scalars and lists replace them.
+//
+// ${VAR} in values is replaced by the environment variable VAR, which must be
+// set, and unknown keys are rejected so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	doc, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	source := "file " + path
+
+	environment := os.Getenv("ENVIRONMENT")
+	if environment == "" {
+		if node := mappingValue(doc, "environment"); node != nil && node.Kind == yaml.ScalarNode {
+			environment = node.Value
+		}
+	}
+	if environment != "" {
+		overlayPath := overlayFile(path, environment)
+		overlay, err := readConfigFile(overlayPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		default:
+			doc = mergeNodes(doc, overlay)
+			source += ", file " + overlayPath
+		}
+	}
+	if doc == nil {
+		// Empty files
+		c.setSource("default", source)
+		return nil
+	}
+
+	c.normalizeDurations(doc, reflect.TypeFor[Config](), time.Second, "")
+
+	// Decode from the interpolated document, rejecting unknown keys
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", source, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", source, err)
+	}
+
+	c.setSource("default", source)
+	return nil
+}
+
+// readConfigFile parses the YAML file at path with its environment variable
+// references replaced, returning its top-level node, or nil when it is empty
+func readConfigFile(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	undefined := make(map[string]bool)
+	interpolate(&doc, undefined)
+	if len(undefined) > 0 {
+		names := make([]string, 0, len(undefined))
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("config file %s references unset environment variables: %s", path, strings.Join(names, ", "))
+	}
+	return doc.Content[0], nil
+}
+
+// overlayFile names the overlay of the config file at path for environment
+func overlayFile(path, environment string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + environment + ext
+}
+
+// mergeNodes merges overlay into base: mappings merge key by key, anything
+// else in overlay replaces what base has
+func mergeNodes(base, overlay *yaml.Node) *yaml.Node {
+	if base == nil || overlay == nil {
+		if overlay != nil {
+			return overlay
+		}
+		return base
+	}
+	if base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return overlay
+	}
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		if j := mappingIndex(base, key.Value); j >= 0 {
+			base.Content[j+1] = mergeNodes(base.Content[j+1], value)
+		} else {
+			base.Content = append(base.Content, key, value)
+		}
+	}
+	return base
+}
+
+// mappingIndex returns the index of key in the mapping node's content, or -1
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
+
+// interpolate replaces environment variable references in the scalar values
+// under node, recording the names of unset variables in undefined
+func interpolate(node *yaml.Node, undefined map[string]bool) {
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "$") {
+		node.Value = envReference.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			if ref == "$$" {
+				return "$"
+			}
+			name := ref[2 : len(ref)-1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				undefined[name] = true
+			}
+			return value
+		})
+		// Resolve unquoted values again so ${PORT} can fill a number
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+	for _, child := range node.Content {
+		interpolate(child, undefined)
+	}
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  port: "9000"
+  read_timeout: ${TEST_READ_TIMEOUT}
+jwt:
+  secret_key: "${TEST_SECRET}"
+rate_limit:
+  requests_per_minute: 30
+upstream:
+  services:
+    - name: payments
+      url: http://payments:3000
+      rewritetarget:
+        pattern: ^/v1/(?P<rest>.*)
+        replacement: /$${rest}
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("TEST_READ_TIMEOUT", "7")
+	t.Setenv("TEST_SECRET", "s3cret: with colon")
+	t.Setenv("RATE_LIMIT_REQUESTS_PER_MINUTE", "90")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != "9000" || cfg.Server.ReadTimeout != 7*time.Second {
+		t.Errorf("server = %q/%v, want 9000/7s", cfg.Server.Port, cfg.Server.ReadTimeout)
+	}
+	if cfg.JWT.SecretKey != "s3cret: with colon" {
+		t.Errorf("secret = %q", cfg.JWT.SecretKey)
+	}
+	if cfg.RateLimit.RequestsPerMinute != 90 {
+		t.Errorf("requests per minute = %d, want the env override 90", cfg.RateLimit.RequestsPerMinute)
+	}
+	if cfg.Upstream.DefaultTimeout != 30*time.Second {
+		t.Errorf("default timeout = %v, want the default 30s", cfg.Upstream.DefaultTimeout)
+	}
+	if len(cfg.Upstream.Services) != 1 || cfg.Upstream.Services[0].RewriteTarget.Replacement != "/${rest}" {
+		t.Errorf("services = %+v", cfg.Upstream.Services)
+	}
+	if cfg.Upstream.Services[0].Timeout != 30*time.Second {
+		t.Errorf("service timeout = %v, want it normalized to 30s", cfg.Upstream.Services[0].Timeout)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    string
+	}{
+		"unset variable": {"jwt:\n  secret_key: ${TEST_UNSET_SECRET}\n", "TEST_UNSET_SECRET"},
+		"unknown key":    {"server:\n  prot: \"80\"\n", "prot"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.content))
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load error = %v, want mention of %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileOverlay(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"gateway.yaml": `
+environment: staging
+server:
+  port: "9000"
+  read_timeout: 10s
+cors:
+  allowed_origins: [https://app.example.com, https://admin.example.com]
+rate_limit:
+  requests_per_minute: 30
+  burst_size: 5
+`,
+		"gateway.staging.yaml": `
+server:
+  read_timeout: 20s
+cors:
+  allowed_origins: [https://staging.example.com]
+rate_limit:
+  requests_per_minute: 60
+`,
+		"gateway.production.yaml": "server:\n  port: \"443\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("CONFIG_FILE", filepath.Join(dir, "gateway.yaml"))
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("RATE_LIMIT_BURST_SIZE", "8")
+
+	// The base file's environment picks the overlay
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != "9000" || cfg.Server.ReadTimeout != 20*time.Second {
+		t.Errorf("server = %q/%v, want the base port and overlay timeout", cfg.Server.Port, cfg.Server.ReadTimeout)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 1 || cfg.CORS.AllowedOrigins[0] != "https://staging.example.com" {
+		t.Errorf("origins = %v, want the overlay's list in place of the base's", cfg.CORS.AllowedOrigins)
+	}
+	if cfg.RateLimit.RequestsPerMinute != 60 || cfg.RateLimit.BurstSize != 8 {
+		t.Errorf("rate limit = %d/%d, want the overlay's 60 and the env's 8", cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.BurstSize)
+	}
+	if !strings.Contains(cfg.String(), "gateway.staging.yaml") {
+		t.Errorf("String() doesn't name the overlay: %s", cfg.String())
+	}
+
+	// ENVIRONMENT picks another
+	t.Setenv("ENVIRONMENT", "production")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != "443" || cfg.Server.ReadTimeout != 10*time.Second {
+		t.Errorf("server = %q/%v, want the production port and base timeout", cfg.Server.Port, cfg.Server.ReadTimeout)
+	}
+
+	// Environments without an overlay use the base file alone
+	t.Setenv("ENVIRONMENT", "development")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != "9000" || cfg.Server.ReadTimeout != 10*time.Second {
+		t.Errorf("server = %q/%v, want the base file's", cfg.Server.Port, cfg.Server.ReadTimeout)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// envFile remembers the env file LoadEnvFile read and the variables it set,
+// so ReloadEnvFile can pick up edits without touching the real environment
+var envFile struct {
+	sync.Mutex
+	path string
+	keys map[string]bool
+}
+
+// ReloadEnvFile re-reads the env file loaded at startup. Variables it set are
+// updated or, when removed from the file, unset; variables set by the process
+// environment still take precedence. Without an env file it does nothing.
+func ReloadEnvFile() error {
+	envFile.Lock()
+	defer envFile.Unlock()
+	if envFile.path == "" {
+		return nil
+	}
+
+	values, err := godotenv.Read(envFile.path)
+	if err != nil {
+		return fmt.Errorf("env file %s: %w", envFile.path, err)
+	}
+	for key := range envFile.keys {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(envFile.keys, key)
+		}
+	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); !set || envFile.keys[key] {
+			os.Setenv(key, value)
+			envFile.keys[key] = true
+		}
+	}
+	return nil
+}
+
+// RestartRequired lists the settings that differ between c and next but are
+// only read at startup, as dotted YAML keys such as "server.port". CORS, rate
+// limits, routes, response cache behaviour, the log level, the upstream
+// settings read per request and each service's instances, timeouts, retries,
+// size limit, allowed methods and credentials apply on reload; everything
+// else needs a restart.
+func (c *Config) RestartRequired(next *Config) []string {
+	var changed []string
+	diffFields("", reflect.ValueOf(*c.startupOnly()), reflect.ValueOf(*next.startupOnly()), &changed)
+	return changed
+}
+
+// ReloadServices returns the services to run with after reloading to next:
+// c's services, with the settings a reload applies taken from their
+// namesakes in next. Services added, removed or renamed in next, and their
+// other settings, keep their current values until a restart, as the proxy's
+// breakers, transports and limiters are built from them once.
+func (c *Config) ReloadServices(next *Config) []ServiceConfig {
+	services := make([]ServiceConfig, len(c.Upstream.Services))
+	for i, service := range c.Upstream.Services {
+		if j := slices.IndexFunc(next.Upstream.Services, func(s ServiceConfig) bool { return s.Name == service.Name }); j >= 0 {
+			reloaded := next.Upstream.Services[j]
+			service.Instances = reloaded.Instances
+			service.Timeout = reloaded.Timeout
+			service.MaxRetry = reloaded.MaxRetry
+			service.Retry = reloaded.Retry
+			service.RetryAfter = reloaded.RetryAfter
+			service.MaxResponseBytes = reloaded.MaxResponseBytes
+			service.AllowedMethods = reloaded.AllowedMethods
+			service.BasicAuth = reloaded.BasicAuth
+		}
+		services[i] = service
+	}
+	return services
+}
+
+// startupOnly returns a copy of c with the settings a reload applies cleared
+func (c *Config) startupOnly() *Config {
+	s := *c
+	s.CORS = CORSConfig{}
+	s.RateLimit = RateLimitConfig{}
+	// The cache store itself is built once; how it is used is not
+	s.Cache = CacheConfig{
+		Enabled:  c.Cache.Enabled,
+		Backend:  c.Cache.Backend,
+		MaxSize:  c.Cache.MaxSize,
+		LocalTTL: c.Cache.LocalTTL,
+		Redis:    c.Cache.Redis,
+	}
+	s.Logging.Level = ""
+	// Read by the proxy handler on every request
+	s.Server.DebugHeaders = false
+	s.Server.UpstreamHeader = ""
+	s.Upstream.DeadlineHeader = ""
+	s.Upstream.Dedup = false
+	s.Upstream.DedupTimeout = 0
+	s.Upstream.DefaultMaxResponseBytes = 0
+	s.Upstream.DefaultRetryAfter = 0
+	// Folded into each service's settings below
+	s.Upstream.DefaultTimeout = 0
+	s.Upstream.DefaultMaxRetry = 0
+	s.Upstream.Retry = RetryPolicy{}
+	s.Upstream.Routes = nil
+	s.Upstream.Services = make([]ServiceConfig, len(c.Upstream.Services))
+	for i, service := range c.Upstream.Services {
+		service.Instances = nil
+		service.Timeout = 0
+		service.MaxRetry = 0
+		service.Retry = nil
+		service.RetryAfter = 0
+		service.MaxResponseBytes = 0
+		service.AllowedMethods = nil
+		service.BasicAuth = nil
+		s.Upstream.Services[i] = service
+	}
+	return &s
+}
+
+// diffFields appends the keys of the exported fields that differ between a
+// and b, descending into nested structs
+func diffFields(prefix string, a, b reflect.Value, changed *[]string) {
+	for i := range a.NumField() {
+		field := a.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		key = prefix + key
+
+		if field.Type.Kind() == reflect.Struct {
+			diffFields(key+".", a.Field(i), b.Field(i), changed)
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			*changed = append(*changed, key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReloadEnvFile(t *testing.T) {
+	t.Setenv("TEST_RELOAD_PRESET", "from process")
+	path := writeEnvFile(t, "TEST_RELOAD_CHANGED=before\nTEST_RELOAD_REMOVED=yes\nTEST_RELOAD_PRESET=from file\n")
+	t.Cleanup(func() {
+		for _, key := range []string{"TEST_RELOAD_CHANGED", "TEST_RELOAD_REMOVED", "TEST_RELOAD_ADDED"} {
+			os.Unsetenv(key)
+		}
+	})
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatalf("LoadEnvFile: %v", err)
+	}
+
+	content := "TEST_RELOAD_CHANGED=after\nTEST_RELOAD_ADDED=yes\nTEST_RELOAD_PRESET=from file\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadEnvFile(); err != nil {
+		t.Fatalf("ReloadEnvFile: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"TEST_RELOAD_CHANGED": "after",
+		"TEST_RELOAD_ADDED":   "yes",
+		// The process environment still wins over the file
+		"TEST_RELOAD_PRESET": "from process",
+	} {
+		if got := os.Getenv(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if _, set := os.LookupEnv("TEST_RELOAD_REMOVED"); set {
+		t.Error("TEST_RELOAD_REMOVED is still set after its removal from the file")
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := defaults()
+	old.Upstream.Services = []ServiceConfig{{Name: "orders", URL: "http://orders:3000", Timeout: 10 * time.Second}}
+
+	next := defaults()
+	next.Upstream.Services = []ServiceConfig{{
+		Name: "orders", URL: "http://orders:3000", Timeout: 5 * time.Second, Instances: []string{"http://orders-1:3000"},
+		MaxResponseBytes: 1024, AllowedMethods: []string{"GET"}, BasicAuth: &BasicAuthConfig{Username: "gateway"},
+	}}
+	next.Upstream.Dedup = true
+	next.Upstream.DeadlineHeader = "X-Deadline-Ms"
+	next.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	next.RateLimit.RequestsPerMinute = 10
+	next.Cache.TTL = 30 * time.Second
+	next.Logging.Level = "debug"
+	if changed := old.RestartRequired(next); len(changed) != 0 {
+		t.Fatalf("reloadable changes reported as needing a restart: %v", changed)
+	}
+
+	next.Server.Port = "9090"
+	next.Cache.MaxSize = 5
+	next.Upstream.Services = append(next.Upstream.Services, ServiceConfig{Name: "payments"})
+	changed := old.RestartRequired(next)
+	want := []string{"server.port", "upstream.services", "cache.max_size"}
+	slices.Sort(changed)
+	slices.Sort(want)
+	if !slices.Equal(changed, want) {
+		t.Errorf("RestartRequired = %v, want %v", changed, want)
+	}
+}
+
+func TestReloadServices(t *testing.T) {
+	old := defaults()
+	old.Upstream.Services = []ServiceConfig{
+		{Name: "orders", URL: "http://orders:3000", Timeout: 10 * time.Second},
+		{Name: "payments", URL: "http://payments:3000"},
+	}
+
+	next := defaults()
+	next.Upstream.Services = []ServiceConfig{
+		// The URL needs a restart, the timeout doesn't
+		{Name: "orders", URL: "http://orders-v2:3000", Timeout: 5 * time.Second},
+		// Renamed: the proxy has nothing built for it
+		{Name: "billing", URL: "http://payments:3000"},
+	}
+	want := []ServiceConfig{
+		{Name: "orders", URL: "http://orders:3000", Timeout: 5 * time.Second},
+		{Name: "payments", URL: "http://payments:3000"},
+	}
+	if got := old.ReloadServices(next); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReloadServices = %+v, want %+v", got, want)
+	}
+}
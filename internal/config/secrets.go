@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secret is a setting that may be read from the file named by its
+// environment variable with a _FILE suffix, as Docker and Kubernetes mount
+// secrets, keeping the value out of the process environment
+type secret struct {
+	env   string
+	value *string
+}
+
+func (c *Config) secrets() []secret {
+	return []secret{
+		{"JWT_SECRET_KEY", &c.JWT.SecretKey},
+		{"REDIS_PASSWORD", &c.Cache.Redis.Password},
+		{"DATABASE_PASSWORD", &c.Database.Password},
+		{"CONSUL_TOKEN", &c.Discovery.ConsulToken},
+	}
+}
+
+// loadSecretFiles reads each secret whose _FILE variable is set, in
+// preference to the plain variable and the config file. The contents are
+// trimmed; an unreadable or empty file is an error.
+func (c *Config) loadSecretFiles() error {
+	for _, s := range c.secrets() {
+		path := os.Getenv(s.env + "_FILE")
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", s.env, err)
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "" {
+			return fmt.Errorf("%s_FILE: %s is empty", s.env, path)
+		}
+		*s.value = value
+		c.setSource(s.env, "file:"+path)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"main/internal/redact"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSecretFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	jwtPath := writeSecretFile(t, "jwt-from-file\n")
+	t.Setenv("JWT_SECRET_KEY", "jwt-from-env")
+	t.Setenv("JWT_SECRET_KEY_FILE", jwtPath)
+	t.Setenv("DATABASE_PASSWORD", "db-from-env")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// The file wins over the plain variable and is trimmed
+	if cfg.JWT.SecretKey != "jwt-from-file" {
+		t.Errorf("JWT secret %q, want the file contents", cfg.JWT.SecretKey)
+	}
+	if cfg.Database.Password != "db-from-env" {
+		t.Errorf("database password %q, want the plain variable", cfg.Database.Password)
+	}
+
+	out := cfg.String()
+	if strings.Contains(out, "jwt-from-file") || strings.Contains(out, "db-from-env") {
+		t.Fatalf("String() leaks a secret: %s", out)
+	}
+	var rendered struct {
+		JWT      struct{ SecretKey string }
+		Database struct{ Password string }
+	}
+	if err := json.Unmarshal([]byte(out), &rendered); err != nil {
+		t.Fatal(err)
+	}
+	if rendered.JWT.SecretKey != "file:"+jwtPath || rendered.Database.Password != redact.Fingerprint("db-from-env") {
+		t.Errorf("rendered secrets %+v, want the JWT file and a fingerprinted password", rendered)
+	}
+}
+
+func TestLoadSecretFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"missing", filepath.Join(t.TempDir(), "missing"), "REDIS_PASSWORD_FILE"},
+		{"empty", writeSecretFile(t, " \n"), "is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REDIS_PASSWORD_FILE", tt.path)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/internal/redact"
+)
+
+// String renders the configuration as JSON with secrets fingerprinted, or
+// replaced by the file they were read from, along with where each section was
+// loaded from and which settings were defaulted, so it can be logged safely.
+// Secrets are found by field name, see redact.IsSecretField.
+func (c *Config) String() string {
+	redacted := redact.Struct(*c)
+	// Secrets read from files show the file instead
+	for _, s := range redacted.secrets() {
+		if source, ok := c.sources[s.env]; ok {
+			*s.value = source
+		}
+	}
+
+	out, err := json.Marshal(struct {
+		*Config
+		Sources  map[string]string
+		Defaults []string `json:",omitempty"`
+	}{&redacted, c.Sources(), c.defaulted})
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(out)
+}
+
+// Sources maps each configuration section not read from the environment, and
+// each secret read from a file, to where it came from; "default" is the
+// source of everything else
+func (c *Config) Sources() map[string]string {
+	sources := map[string]string{"default": "env"}
+	for section, source := range c.sources {
+		sources[section] = source
+	}
+	return sources
+}
+
+// setSource records where a section of the configuration came from
+func (c *Config) setSource(section, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[section] = source
+}
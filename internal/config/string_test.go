@@ -0,0 +1,65 @@
+package config
+
+import (
+	"encoding/json"
+	"main/internal/redact"
+	"strings"
+	"testing"
+)
+
+func TestStringMasksSecrets(t *testing.T) {
+	cfg := &Config{}
+	cfg.JWT.SecretKey = "jwt-secret-value"
+	cfg.Cache.Redis.Password = "redis-secret-value"
+	cfg.Database.Password = "db-secret-value"
+	cfg.Discovery.ConsulToken = "consul-secret-value"
+	cfg.APIKeys.Keys = []APIKeyEntry{{Key: "api-secret-value", ClientID: "billing"}}
+	cfg.Upstream.Services = []ServiceConfig{{Name: "legacy", BasicAuth: &BasicAuthConfig{Username: "gateway", Password: "basic-secret-value"}}}
+	cfg.setSource("upstream.services", "file services.yaml")
+
+	out := cfg.String()
+	for _, secret := range []string{"jwt-secret-value", "redis-secret-value", "db-secret-value", "consul-secret-value", "api-secret-value", "basic-secret-value"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("String() leaks %s", secret)
+		}
+	}
+	// Masking works on a copy
+	if cfg.JWT.SecretKey != "jwt-secret-value" || cfg.APIKeys.Keys[0].Key != "api-secret-value" ||
+		cfg.Upstream.Services[0].BasicAuth.Password != "basic-secret-value" {
+		t.Fatal("String() modified the configuration")
+	}
+
+	var rendered struct {
+		JWT      struct{ SecretKey string }
+		APIKeys  struct{ Keys []APIKeyEntry }
+		Upstream struct{ Services []ServiceConfig }
+		Sources  map[string]string
+	}
+	if err := json.Unmarshal([]byte(out), &rendered); err != nil {
+		t.Fatalf("String() is not JSON: %v", err)
+	}
+	// Secrets are fingerprinted so renderings from one process can still be compared
+	if rendered.JWT.SecretKey != redact.Fingerprint("jwt-secret-value") ||
+		rendered.APIKeys.Keys[0].Key != redact.Fingerprint("api-secret-value") || rendered.APIKeys.Keys[0].ClientID != "billing" {
+		t.Errorf("rendered %+v", rendered)
+	}
+	if auth := rendered.Upstream.Services[0].BasicAuth; auth.Username != "gateway" || auth.Password != redact.Fingerprint("basic-secret-value") {
+		t.Errorf("rendered basic auth %+v", auth)
+	}
+	if rendered.Sources["default"] != "env" || rendered.Sources["upstream.services"] != "file services.yaml" {
+		t.Errorf("sources = %v", rendered.Sources)
+	}
+}
+
+func TestStringLeavesUnsetSecretsEmpty(t *testing.T) {
+	var rendered struct {
+		Database struct{ Password string }
+	}
+	if err := json.Unmarshal([]byte((&Config{}).String()), &rendered); err != nil {
+		t.Fatal(err)
+	}
+	// An unset secret stays visibly unset
+	if rendered.Database.Password != "" {
+		t.Fatalf("unset password rendered as %q", rendered.Database.Password)
+	}
+}
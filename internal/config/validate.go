@@ -0,0 +1,401 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks the configuration for values the gateway can't run with
+// and returns every problem found, joined, or nil
+func (c *Config) Validate() error {
+	// Settings that didn't parse come first, named by their key; malformed
+	// environment variables only in strict mode, otherwise they are logged
+	errs := append([]error(nil), c.invalid...)
+	if c.strictEnv() {
+		errs = append(errs, c.malformed...)
+	}
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.JWT.SecretKey == "" && c.Environment != "development" {
+		add("JWT_SECRET_KEY must be set outside the development environment")
+	}
+	if !validPort(c.Server.Port) {
+		add("SERVER_PORT %q is not a port number", c.Server.Port)
+	}
+	if c.Server.GRPCPort != "" && !validPort(c.Server.GRPCPort) {
+		add("SERVER_GRPC_PORT %q is not a port number", c.Server.GRPCPort)
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 || c.Server.RequestTimeout < 0 {
+		add("server timeouts must not be negative")
+	}
+	if c.Server.BodyLimit < 0 || c.Server.ReadBufferSize < 0 {
+		add("server body limit and read buffer size must not be negative")
+	}
+	if c.Server.MaxHeaderBytes < 0 || c.Server.MaxHeaderCount < 0 {
+		add("server max header bytes and count must not be negative")
+	}
+
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		add("CORS_ALLOW_CREDENTIALS can't be used with the \"*\" origin; list the origins instead")
+	}
+
+	c.validateUpstream(add)
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Backend != "memory" && c.RateLimit.Backend != "redis" {
+			add("rate limit backend must be memory or redis")
+		}
+		switch c.RateLimit.Strategy {
+		case "ip", "user", "user_or_ip":
+		default:
+			add("rate limit strategy must be ip, user or user_or_ip")
+		}
+		if c.RateLimit.RequestsPerMinute <= 0 || c.RateLimit.BurstSize <= 0 {
+			add("rate limit requests per minute and burst size must be positive")
+		}
+		if c.RateLimit.Strategy != "ip" && (c.RateLimit.UserRequestsPerMinute <= 0 || c.RateLimit.UserBurstSize <= 0) {
+			add("user rate limit requests per minute and burst size must be positive")
+		}
+		if c.RateLimit.AdminRequestsPerMinute < 0 || c.RateLimit.AdminBurstSize < 0 {
+			add("admin rate limit must not be negative")
+		}
+	}
+	if c.Cache.Enabled {
+		if c.Cache.Backend != "memory" && c.Cache.Backend != "redis" {
+			add("cache backend must be memory or redis")
+		}
+		if c.Cache.TTL <= 0 || c.Cache.MaxSize <= 0 || c.Cache.MaxObjectBytes <= 0 {
+			add("cache TTL, max size and max object bytes must be positive")
+		}
+		if c.Cache.NegativeEnabled && c.Cache.NegativeTTL <= 0 {
+			add("negative cache TTL must be positive")
+		}
+	}
+	if c.Idempotency.Enabled {
+		if c.Idempotency.Backend != "memory" && c.Idempotency.Backend != "redis" {
+			add("idempotency backend must be memory or redis")
+		}
+		if c.Idempotency.Header == "" || c.Idempotency.TTL <= 0 || c.Idempotency.MaxKeys <= 0 {
+			add("idempotency needs a header, and a positive TTL and max keys")
+		}
+	}
+
+	if headers := c.Server.SecurityHeaders; headers.Enabled {
+		switch strings.ToUpper(headers.FrameOptions) {
+		case "", "OFF", "DENY", "SAMEORIGIN":
+		default:
+			add("security headers frame options must be DENY, SAMEORIGIN or off")
+		}
+		if headers.HSTSMaxAge < 0 {
+			add("security headers HSTS max age must not be negative")
+		}
+		// Browsers only preload sites that commit to HSTS for their subdomains
+		// for at least a year
+		if headers.HSTSPreload && (!headers.HSTSIncludeSubdomains || headers.HSTSMaxAge < 365*24*time.Hour) {
+			add("security headers HSTS preload needs include subdomains and a max age of at least a year")
+		}
+	}
+	if c.Health.CheckInterval <= 0 || c.Health.CheckTimeout <= 0 {
+		add("health check interval and timeout must be positive")
+	} else if c.Health.CheckInterval < c.Health.CheckTimeout {
+		add("health check interval must not be shorter than the timeout")
+	}
+	if c.Health.Mode != HealthModeLight && c.Health.Mode != HealthModeDeep {
+		add("health mode must be light or deep")
+	}
+	if wait := c.Health.StartupWait; wait.Timeout < 0 {
+		add("health startup wait must not be negative")
+	} else if wait.Timeout > 0 && (wait.BackoffBase <= 0 || wait.BackoffCap < wait.BackoffBase) {
+		add("health startup backoff base must be positive and its cap at least the base")
+	}
+	if c.Metrics.MaxUnmatchedRoutes < 0 {
+		add("metrics max unmatched routes must not be negative")
+	}
+	switch c.Discovery.Provider {
+	case DiscoveryStatic:
+	case DiscoveryConsul:
+		if c.Discovery.ConsulAddress == "" || c.Discovery.WaitTime <= 0 {
+			add("consul discovery needs CONSUL_ADDRESS and a positive CONSUL_WAIT_TIME")
+		}
+	default:
+		add("service discovery must be static or consul")
+	}
+	if c.Tenancy.Enabled && c.Tenancy.Claim == "" && (c.Tenancy.BaseDomain == "" || !c.Tenancy.AllowAnonymousHost) {
+		add("tenancy needs TENANCY_CLAIM, or TENANCY_BASE_DOMAIN with TENANCY_ALLOW_ANONYMOUS_HOST")
+	}
+	for _, rate := range []float64{c.Logging.AccessLogSampleRate, c.Logging.AccessLogSuccessSampleRate} {
+		if rate < 0 || rate > 1 {
+			add("access log sample rates must be between 0 and 1")
+			break
+		}
+	}
+	if c.Logging.StreamBuffer <= 0 {
+		add("log stream buffer must be positive")
+	}
+	if c.Audit.Enabled {
+		if c.Database.Host == "" {
+			add("audit log needs DATABASE_HOST")
+		}
+		if c.Audit.QueueSize <= 0 || c.Audit.BatchSize <= 0 || c.Audit.FlushInterval <= 0 {
+			add("audit queue size, batch size and flush interval must be positive")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateUpstream checks the upstream defaults and every service
+func (c *Config) validateUpstream(add func(format string, args ...any)) {
+	if c.Upstream.DefaultTimeout <= 0 || c.Upstream.DefaultMaxRetry <= 0 || c.Upstream.DefaultRetryAfter <= 0 {
+		add("default upstream timeout, max retry and retry after must be positive")
+	}
+	if c.Upstream.DefaultMaxResponseBytes < NoLimit || c.Upstream.DefaultMaxResponseBytes == 0 {
+		add("default max response bytes must be positive, or -1 for no limit")
+	}
+	if c.Upstream.DNSCache.Enabled && c.Upstream.DNSCache.TTL <= 0 {
+		add("upstream DNS cache TTL must be positive")
+	}
+	if c.Upstream.RetryBudgetRatio < 0 || (c.Upstream.RetryBudgetRatio > 0 && c.Upstream.RetryBudgetMax < 1) {
+		add("retry budget ratio must not be negative, and a retry budget needs a max of at least 1")
+	}
+	if breaker := c.Upstream.CircuitBreaker; breaker.MaxRequests <= 0 || breaker.Interval <= 0 || breaker.Timeout <= 0 ||
+		breaker.MinRequests <= 0 || breaker.FailureRatio <= 0 || breaker.FailureRatio > 1 {
+		add("circuit breaker settings must be positive, with a failure ratio of at most 1")
+	}
+	if outlier := c.Upstream.Outlier; outlier.Consecutive5xx > 0 && (outlier.BaseEjectionTime <= 0 ||
+		outlier.MaxEjectionTime < outlier.BaseEjectionTime || outlier.MaxEjectionPercent <= 0 || outlier.MaxEjectionPercent > 100) {
+		add("outlier ejection times must be positive with max >= base, and max ejection percent within 1-100")
+	}
+
+	names := make(map[string]bool)
+	tenants := make(map[string]string)
+	for i, service := range c.Upstream.Services {
+		if service.Name == "" {
+			add("service %d: name must be set", i)
+		} else if names[service.Name] {
+			add("service %s is configured more than once", service.Name)
+		}
+		names[service.Name] = true
+
+		if service.Tenant != "" {
+			if other, exists := tenants[service.Tenant]; exists {
+				add("services %s and %s are both scoped to tenant %s", other, service.Name, service.Tenant)
+			}
+			tenants[service.Tenant] = service.Name
+		}
+		for _, target := range append([]string{service.URL}, service.Instances...) {
+			if err := validUpstreamURL(target); err != nil {
+				add("service %s: %w", service.Name, err)
+			}
+		}
+		if service.Mirror != nil {
+			if err := validUpstreamURL(service.Mirror.URL); err != nil {
+				add("service %s: mirror: %w", service.Name, err)
+			}
+			if service.Mirror.Percent <= 0 || service.Mirror.Percent > 100 {
+				add("service %s: mirror percent must be above 0 and at most 100", service.Name)
+			}
+		}
+		if service.Canary != nil {
+			if err := validUpstreamURL(service.Canary.URL); err != nil {
+				add("service %s: canary: %w", service.Name, err)
+			}
+			if service.Canary.Weight < 0 || service.Canary.Weight > 100 {
+				add("service %s: canary weight must be between 0 and 100", service.Name)
+			}
+			if service.Canary.Weight == 0 && service.Canary.Header == "" && service.Canary.Cookie == "" {
+				add("service %s: canary needs a weight, a header or a cookie", service.Name)
+			}
+		}
+		if service.BasicAuth != nil && (service.BasicAuth.Username == "" || strings.Contains(service.BasicAuth.Username, ":")) {
+			add("service %s: basic auth needs a username without a colon", service.Name)
+		}
+		if service.MaxResponseBytes < NoLimit {
+			add("service %s: max response bytes must be positive, or -1 for no limit", service.Name)
+		}
+		if check := service.HealthCheck; check != nil {
+			validateHealthCheck(service.Name, check, add)
+		}
+		if service.Retry != nil {
+			validateRetryPolicy("service "+service.Name, service.Retry, service.Timeout, add)
+		}
+		if transform := service.Transform; transform != nil {
+			if len(transform.RequestRenames) == 0 && len(transform.ResponseRenames) == 0 {
+				add("service %s: transform needs request or response renames", service.Name)
+			}
+			for _, renames := range []map[string]string{transform.RequestRenames, transform.ResponseRenames} {
+				for from, to := range renames {
+					if from == "" || to == "" {
+						add("service %s: transform can't rename %q to %q", service.Name, from, to)
+					}
+				}
+			}
+		}
+		if service.Timeout < 0 || service.MaxRetry < 0 || service.RetryAfter < 0 || service.MaxConcurrent < 0 || service.QueueTimeout < 0 {
+			add("service %s: timeout, max retry, retry after, max concurrent and queue timeout must not be negative", service.Name)
+		}
+		for _, method := range service.AllowedMethods {
+			if !slices.Contains(httpMethods, method) {
+				add("service %s: unknown allowed method %q", service.Name, method)
+			}
+		}
+		switch service.Affinity {
+		case "none", "cookie", "user":
+		default:
+			add("service %s: affinity must be none, cookie or user", service.Name)
+		}
+		if service.Type != "http" && service.Type != ServiceTypeGRPC {
+			add("service %s: type must be http or grpc", service.Name)
+		}
+	}
+
+	c.validateRoutes(names, add)
+}
+
+// maxRetryAttempts bounds RetryPolicy.Attempts
+const maxRetryAttempts = 10
+
+// validateRetryPolicy checks a service's or route's retry policy, after
+// defaults; timeout is the one its calls run under
+func validateRetryPolicy(name string, policy *RetryPolicy, timeout time.Duration, add func(format string, args ...any)) {
+	if policy.Attempts < 1 || policy.Attempts > maxRetryAttempts {
+		add("%s: retry attempts must be between 1 and %d", name, maxRetryAttempts)
+	}
+	for _, condition := range policy.RetryOn {
+		if condition != RetryOnConnectionError && condition != RetryOn5xx {
+			add("%s: unknown retry condition %q, want %s or %s", name, condition, RetryOnConnectionError, RetryOn5xx)
+		}
+	}
+	for _, status := range policy.Statuses {
+		if status < 100 || status > 599 {
+			add("%s: retry status %d is not an HTTP status", name, status)
+		}
+	}
+	if policy.BackoffBase <= 0 || policy.BackoffCap < policy.BackoffBase {
+		add("%s: retry backoff base must be positive and its cap at least the base", name)
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		add("%s: retry jitter must be between 0 and 1", name)
+	}
+	if policy.Deadline < 0 || (timeout > 0 && policy.Deadline > timeout) {
+		add("%s: retry deadline must not be negative or longer than the timeout %v", name, timeout)
+	}
+}
+
+// validateHealthCheck checks a service's HTTP health check, after defaults
+func validateHealthCheck(service string, check *HealthCheckConfig, add func(format string, args ...any)) {
+	if !strings.HasPrefix(check.Path, "/") {
+		add("service %s: health check path must start with /", service)
+	}
+	if !slices.Contains(httpMethods, check.Method) {
+		add("service %s: unknown health check method %q", service, check.Method)
+	}
+	for _, status := range check.ExpectedStatus {
+		if status < 100 || status > 599 {
+			add("service %s: health check expected status %d is not an HTTP status", service, status)
+		}
+	}
+	if check.Interval <= 0 || check.Timeout <= 0 {
+		add("service %s: health check interval and timeout must be positive", service)
+	} else if check.Interval < check.Timeout {
+		add("service %s: health check interval %v is shorter than its timeout %v", service, check.Interval, check.Timeout)
+	}
+	if check.HealthyThreshold < 1 || check.UnhealthyThreshold < 1 {
+		add("service %s: health check thresholds must be at least 1", service)
+	}
+}
+
+// validateRoutes checks each route names a known service and valid policies,
+// and that no two routes claim the same method on the same path
+func (c *Config) validateRoutes(services map[string]bool, add func(format string, args ...any)) {
+	claimed := make(map[string]map[string]bool)
+	for i, route := range c.Upstream.Routes {
+		name := fmt.Sprintf("route %d (%s)", i, route.Path)
+		if !strings.HasPrefix(route.Path, "/") {
+			add("%s: path must start with /", name)
+		}
+		if !services[route.Service] {
+			add("%s: unknown service %q", name, route.Service)
+		}
+		switch route.Auth {
+		case RouteAuthRequired, RouteAuthOptional, RouteAuthPublic:
+		default:
+			add("%s: auth must be required, optional or public", name)
+		}
+		if route.Auth == RouteAuthPublic && (len(route.Roles) > 0 || len(route.Scopes) > 0) {
+			add("%s: a public route can't require roles or scopes", name)
+		}
+		for _, method := range route.Methods {
+			if !slices.Contains(httpMethods, method) {
+				add("%s: unknown method %q", name, method)
+			}
+		}
+		if limit := route.RateLimit; limit != nil && (limit.RequestsPerMinute <= 0 || limit.BurstSize <= 0) {
+			add("%s: rate limit requests per minute and burst size must be positive", name)
+		}
+		if route.CacheTTL < 0 || route.Timeout < 0 {
+			add("%s: cache TTL and timeout must not be negative", name)
+		}
+		if route.MaxResponseBytes < NoLimit {
+			add("%s: max response bytes must be positive, or -1 for no limit", name)
+		}
+		if route.Retry != nil {
+			timeout := route.Timeout
+			if i := slices.IndexFunc(c.Upstream.Services, func(s ServiceConfig) bool { return s.Name == route.Service }); timeout == 0 && i >= 0 {
+				timeout = c.Upstream.Services[i].Timeout
+			}
+			validateRetryPolicy(name, route.Retry, timeout, add)
+		}
+		if route.CacheTTL > 0 && route.IsPattern() {
+			add("%s: only prefix routes can be cached", name)
+		}
+
+		// Routes for the same path must take different methods
+		methods := route.Methods
+		if len(methods) == 0 {
+			methods = []string{"*"}
+		}
+		if claimed[route.Path] == nil {
+			claimed[route.Path] = make(map[string]bool)
+		}
+		for _, method := range methods {
+			if claimed[route.Path][method] || claimed[route.Path]["*"] || (method == "*" && len(claimed[route.Path]) > 0) {
+				add("%s: duplicates another route for %s %s", name, method, route.Path)
+				break
+			}
+		}
+		for _, method := range methods {
+			claimed[route.Path][method] = true
+		}
+	}
+}
+
+// httpMethods are the methods a service may be restricted to
+var httpMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace,
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// validUpstreamURL accepts absolute http and https URLs
+func validUpstreamURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid upstream URL %q: %w", target, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("upstream URL %q must be an absolute http or https URL", target)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"main/internal/config"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Failed queries are retried after a backoff doubling up to consulMaxBackoff
+const (
+	consulMinBackoff = time.Second
+	consulMaxBackoff = 30 * time.Second
+)
+
+// ConsulRegistry watches the passing instances of each configured service in
+// Consul with blocking queries. A service's settings still come from the
+// static configuration; Consul only supplies its instances.
+type ConsulRegistry struct {
+	cfg      config.DiscoveryConfig
+	services []config.ServiceConfig
+	client   *http.Client
+	logger   *zap.Logger
+
+	mu        sync.Mutex
+	instances map[string][]string // service name -> discovered instances
+}
+
+// consulHealthEntry is the part of a /v1/health/service entry we use
+type consulHealthEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+func NewConsul(cfg config.DiscoveryConfig, services []config.ServiceConfig, log *zap.Logger) *ConsulRegistry {
+	return &ConsulRegistry{
+		cfg:      cfg,
+		services: services,
+		// Blocking queries are held for up to WaitTime, plus Consul's jitter
+		client:    &http.Client{Timeout: cfg.WaitTime + 30*time.Second},
+		logger:    log,
+		instances: make(map[string][]string),
+	}
+}
+
+// Watch runs one blocking query loop per service and returns once ctx is done
+func (r *ConsulRegistry) Watch(ctx context.Context, update func([]config.ServiceConfig)) error {
+	var wg sync.WaitGroup
+	for _, service := range r.services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.watchService(ctx, service, update)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (r *ConsulRegistry) watchService(ctx context.Context, service config.ServiceConfig, update func([]config.ServiceConfig)) {
+	var index uint64
+	backoff := consulMinBackoff
+	for ctx.Err() == nil {
+		instances, next, err := r.query(ctx, service, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.logger.Warn("Consul query failed",
+				zap.String("service", service.Name),
+				zap.Duration("retry_in", backoff),
+				zap.Error(err),
+			)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff = min(backoff*2, consulMaxBackoff)
+			continue
+		}
+		backoff = consulMinBackoff
+
+		// Consul may reset its index; start over rather than block on a stale one
+		if next < index {
+			next = 0
+		}
+		index = next
+
+		r.mu.Lock()
+		changed := !slices.Equal(r.instances[service.Name], instances)
+		r.instances[service.Name] = instances
+		r.mu.Unlock()
+		if changed {
+			update(r.snapshot())
+		}
+	}
+}
+
+// query fetches the passing instances of service, blocking until they change
+// from index. It returns them sorted, with the index to wait on next.
+func (r *ConsulRegistry) query(ctx context.Context, service config.ServiceConfig, index uint64) ([]string, uint64, error) {
+	params := url.Values{"passing": {"true"}}
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", r.cfg.WaitTime.String())
+	}
+	if r.cfg.ConsulDatacenter != "" {
+		params.Set("dc", r.cfg.ConsulDatacenter)
+	}
+	endpoint := r.cfg.ConsulAddress + "/v1/health/service/" + url.PathEscape(service.DiscoveryName) + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.cfg.ConsulToken != "" {
+		req.Header.Set("X-Consul-Token", r.cfg.ConsulToken)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul responded %d", resp.StatusCode)
+	}
+
+	var entries []consulHealthEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("decoding consul response: %w", err)
+	}
+	next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul response has no valid X-Consul-Index")
+	}
+
+	// Instances keep the scheme of the service's configured URL
+	scheme := "http"
+	if u, err := url.Parse(service.URL); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	instances := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		instances = append(instances, scheme+"://"+net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	slices.Sort(instances)
+	return slices.Compact(instances), next, nil
+}
+
+// snapshot returns the configured services with their discovered instances;
+// services not discovered yet are left out
+func (r *ConsulRegistry) snapshot() []config.ServiceConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	services := make([]config.ServiceConfig, 0, len(r.instances))
+	for _, service := range r.services {
+		instances, found := r.instances[service.Name]
+		if !found {
+			continue
+		}
+		service.Instances = instances
+		services = append(services, service)
+	}
+	return services
+}
@@ -0,0 +1,137 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"main/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeConsul serves /v1/health/service/orders-svc, answering each query with
+// the next of responses and holding further blocking queries until the
+// client gives up
+type fakeConsul struct {
+	t         *testing.T
+	responses []string
+	blocked   chan struct{} // closed when the first query is held
+
+	mu      sync.Mutex
+	queries []*http.Request
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/health/service/orders-svc" {
+		f.t.Errorf("queried %s", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	n := len(f.queries)
+	f.queries = append(f.queries, r)
+	f.mu.Unlock()
+	if n >= len(f.responses) {
+		if n == len(f.responses) {
+			close(f.blocked)
+		}
+		<-r.Context().Done()
+		return
+	}
+	w.Header().Set("X-Consul-Index", fmt.Sprint(10+n))
+	fmt.Fprint(w, f.responses[n])
+}
+
+func TestConsulWatch(t *testing.T) {
+	consul := &fakeConsul{t: t, blocked: make(chan struct{}), responses: []string{
+		// A service without its own address is reached on its node's
+		`[{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "", "Port": 3000}},
+		  {"Node": {"Address": "10.0.0.9"}, "Service": {"Address": "10.0.1.1", "Port": 3000}}]`,
+		`[{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "", "Port": 3000}}]`,
+	}}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	cfg := config.DiscoveryConfig{ConsulAddress: server.URL, ConsulToken: "token", ConsulDatacenter: "eu1", WaitTime: time.Minute}
+	services := []config.ServiceConfig{
+		{Name: "orders", DiscoveryName: "orders-svc", URL: "https://orders:443", Timeout: 5 * time.Second},
+	}
+	registry := NewConsul(cfg, services, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan []config.ServiceConfig, 2)
+	done := make(chan error)
+	go func() {
+		done <- registry.Watch(ctx, func(services []config.ServiceConfig) { updates <- services })
+	}()
+
+	// Instances keep the configured URL's scheme and the service its settings
+	for _, want := range [][]string{
+		{"https://10.0.0.2:3000", "https://10.0.1.1:3000"},
+		{"https://10.0.0.2:3000"},
+	} {
+		select {
+		case got := <-updates:
+			if len(got) != 1 || got[0].Name != "orders" || got[0].Timeout != 5*time.Second || !slices.Equal(got[0].Instances, want) {
+				t.Fatalf("update = %+v, want orders at %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no update for %v", want)
+		}
+	}
+
+	select {
+	case <-consul.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no blocking query after the last change")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Watch returned %v, want context.Canceled", err)
+	}
+
+	consul.mu.Lock()
+	defer consul.mu.Unlock()
+	for i, query := range consul.queries[:3] {
+		params := query.URL.Query()
+		if params.Get("passing") != "true" || params.Get("dc") != "eu1" || query.Header.Get("X-Consul-Token") != "token" {
+			t.Errorf("query %d: %s without the passing filter, datacenter or token", i, query.URL)
+		}
+		// The first query returns at once; later ones block on the last index
+		wantIndex, wantWait := "", ""
+		if i > 0 {
+			wantIndex, wantWait = fmt.Sprint(10+i-1), "1m0s"
+		}
+		if params.Get("index") != wantIndex || params.Get("wait") != wantWait {
+			t.Errorf("query %d: index %q wait %q, want %q %q", i, params.Get("index"), params.Get("wait"), wantIndex, wantWait)
+		}
+	}
+}
+
+func TestConsulQueryErrors(t *testing.T) {
+	tests := map[string]http.HandlerFunc{
+		"error status": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+		"no index": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[]`))
+		},
+		"bad body": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Consul-Index", "1")
+			w.Write([]byte(`{`))
+		},
+	}
+	for name, handler := range tests {
+		server := httptest.NewServer(handler)
+		registry := NewConsul(config.DiscoveryConfig{ConsulAddress: server.URL, WaitTime: time.Second}, nil, zap.NewNop())
+		if _, _, err := registry.query(context.Background(), config.ServiceConfig{Name: "orders", DiscoveryName: "orders"}, 0); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		server.Close()
+	}
+}
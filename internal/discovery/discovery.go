@@ -0,0 +1,44 @@
+// Package discovery supplies the upstream services' instances, either from
+// the static configuration or from a service registry watched for changes
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"main/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// ServiceRegistry supplies the upstream services. Watch calls update with
+// the current services, then again whenever they change, until ctx is done.
+type ServiceRegistry interface {
+	Watch(ctx context.Context, update func([]config.ServiceConfig)) error
+}
+
+// New returns the registry selected by cfg.Discovery
+func New(cfg *config.Config, log *zap.Logger) (ServiceRegistry, error) {
+	switch cfg.Discovery.Provider {
+	case config.DiscoveryStatic:
+		return NewStatic(cfg.Upstream.Services), nil
+	case config.DiscoveryConsul:
+		return NewConsul(cfg.Discovery, cfg.Upstream.Services, log), nil
+	default:
+		return nil, fmt.Errorf("unknown service discovery provider %q", cfg.Discovery.Provider)
+	}
+}
+
+// StaticRegistry serves the services loaded from the services file or the
+// environment, which never change
+type StaticRegistry struct {
+	services []config.ServiceConfig
+}
+
+func NewStatic(services []config.ServiceConfig) *StaticRegistry {
+	return &StaticRegistry{services: services}
+}
+
+func (r *StaticRegistry) Watch(ctx context.Context, update func([]config.ServiceConfig)) error {
+	update(r.services)
+	return nil
+}
@@ -0,0 +1,292 @@
+package gateway
+
+import (
+	"hash/fnv"
+	"main/internal/config"
+	"main/internal/metrics"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Affinity modes for ServiceConfig.Affinity
+const (
+	AffinityNone   = "none"
+	AffinityCookie = "cookie"
+	AffinityUser   = "user"
+)
+
+const (
+	// ringReplicas is the number of points each instance gets on the hash ring
+	ringReplicas = 100
+	// instanceCooldown is how long an instance is skipped after a failed request
+	instanceCooldown = 10 * time.Second
+)
+
+type ringPoint struct {
+	hash     uint32
+	instance string
+}
+
+// balancer spreads requests over a service's instances: round robin without an
+// affinity key, otherwise a consistent hash so adding or removing an instance
+// only remaps the keys that hashed to it
+type balancer struct {
+	outlier config.OutlierConfig
+
+	mu        sync.Mutex
+	instances []string
+	ring      []ringPoint
+	next      int
+	downUntil map[string]time.Time
+	outliers  map[string]*outlierState
+}
+
+// outlierState tracks an instance's failure streak and ejections
+type outlierState struct {
+	consecutive  int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// InstanceOutcome is the result of a request to an instance
+type InstanceOutcome int
+
+const (
+	InstanceSuccess InstanceOutcome = iota
+	// InstanceUnreachable is a transport error: the instance is skipped for
+	// instanceCooldown and the failure counts towards its ejection
+	InstanceUnreachable
+	// InstanceServerError is a 5xx response, counted towards ejection only
+	InstanceServerError
+)
+
+// Outcome classifies a request by its transport error and response status
+func Outcome(err error, status int) InstanceOutcome {
+	switch {
+	case err != nil:
+		return InstanceUnreachable
+	case status >= http.StatusInternalServerError:
+		return InstanceServerError
+	default:
+		return InstanceSuccess
+	}
+}
+
+func newBalancer(service config.ServiceConfig, outlier config.OutlierConfig) *balancer {
+	b := &balancer{
+		outlier:   outlier,
+		downUntil: make(map[string]time.Time),
+		outliers:  make(map[string]*outlierState),
+	}
+	instances := service.Instances
+	if len(instances) == 0 {
+		instances = []string{service.URL}
+	}
+	b.setInstances(instances)
+	return b
+}
+
+// setInstances replaces the instances and rebuilds the ring. Instances that
+// stay keep their failure cooldown.
+func (b *balancer) setInstances(instances []string) {
+	ring := make([]ringPoint, 0, len(instances)*ringReplicas)
+	for _, instance := range instances {
+		for i := 0; i < ringReplicas; i++ {
+			ring = append(ring, ringPoint{hash: hashKey(instance + "#" + strconv.Itoa(i)), instance: instance})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.instances = slices.Clone(instances)
+	b.ring = ring
+	for instance := range b.downUntil {
+		if !slices.Contains(instances, instance) {
+			delete(b.downUntil, instance)
+		}
+	}
+	for instance := range b.outliers {
+		if !slices.Contains(instances, instance) {
+			delete(b.outliers, instance)
+		}
+	}
+}
+
+// size returns the number of instances
+func (b *balancer) size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.instances)
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// pick returns the instance for key, skipping unhealthy ones. When every
+// instance is unhealthy the preferred one is returned anyway.
+func (b *balancer) pick(key string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.instances) == 1 {
+		return b.instances[0]
+	}
+	now := time.Now()
+
+	if key == "" {
+		start := b.next % len(b.instances)
+		b.next++
+		for i := range b.instances {
+			instance := b.instances[(start+i)%len(b.instances)]
+			if b.healthy(instance, now) {
+				return instance
+			}
+		}
+		return b.instances[start]
+	}
+
+	// Walk the ring clockwise from the key's point to the first healthy instance
+	hash := hashKey(key)
+	start := sort.Search(len(b.ring), func(i int) bool {
+		return b.ring[i].hash >= hash
+	})
+	for i := range b.ring {
+		point := b.ring[(start+i)%len(b.ring)]
+		if b.healthy(point.instance, now) {
+			return point.instance
+		}
+	}
+	return b.ring[start%len(b.ring)].instance
+}
+
+func (b *balancer) healthy(instance string, now time.Time) bool {
+	return !now.Before(b.downUntil[instance])
+}
+
+// available returns the instances not cooling down after a failure
+func (b *balancer) available() []string {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var instances []string
+	for _, instance := range b.instances {
+		if b.healthy(instance, now) {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+// report records the outcome of a request to instance. It returns how long
+// the instance is ejected for when this failure ejects it, and 0 otherwise.
+func (b *balancer) report(instance string, outcome InstanceOutcome) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.instances) == 1 || !slices.Contains(b.instances, instance) {
+		return 0
+	}
+
+	now := time.Now()
+	state := b.outliers[instance]
+	if state == nil {
+		state = &outlierState{}
+		b.outliers[instance] = state
+	}
+
+	switch outcome {
+	case InstanceSuccess:
+		state.consecutive = 0
+		if !now.Before(state.ejectedUntil) {
+			delete(b.downUntil, instance)
+		}
+		return 0
+	case InstanceUnreachable:
+		b.downUntil[instance] = later(b.downUntil[instance], now.Add(instanceCooldown))
+	}
+
+	state.consecutive++
+	if b.outlier.Consecutive5xx <= 0 || state.consecutive < b.outlier.Consecutive5xx ||
+		now.Before(state.ejectedUntil) || !b.canEject(now) {
+		return 0
+	}
+
+	// The ejection count is forgotten once the instance has stayed in for
+	// the maximum ejection time
+	maxEjection := b.outlier.MaxEjectionTime
+	if now.Sub(state.ejectedUntil) > maxEjection {
+		state.ejections = 0
+	}
+	state.ejections++
+	state.consecutive = 0
+
+	ejection := min(time.Duration(state.ejections)*b.outlier.BaseEjectionTime, maxEjection)
+	state.ejectedUntil = now.Add(ejection)
+	b.downUntil[instance] = later(b.downUntil[instance], state.ejectedUntil)
+	return ejection
+}
+
+// canEject reports whether one more instance may be ejected without
+// exceeding the maximum ejection percentage; one always may. Must be called
+// with mu held.
+func (b *balancer) canEject(now time.Time) bool {
+	ejected := 0
+	for _, state := range b.outliers {
+		if now.Before(state.ejectedUntil) {
+			ejected++
+		}
+	}
+	return ejected == 0 || (ejected+1)*100 <= b.outlier.MaxEjectionPercent*len(b.instances)
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// PickInstance returns the base URL of the service instance to forward to.
+// Requests with the same non-empty key go to the same instance while it is healthy.
+func (p *Proxy) PickInstance(serviceName, key string) string {
+	if b, exists := p.balancers[serviceName]; exists {
+		return b.pick(key)
+	}
+	return p.GetServiceURL(serviceName)
+}
+
+// ReportInstance records the outcome of a request to an instance. An
+// unreachable instance is skipped for a while, and one failing repeatedly is
+// ejected by outlier detection; a success makes it healthy again.
+func (p *Proxy) ReportInstance(serviceName, instance string, outcome InstanceOutcome) {
+	b, exists := p.balancers[serviceName]
+	if !exists {
+		return
+	}
+	ejection := b.report(instance, outcome)
+	if ejection == 0 {
+		return
+	}
+
+	metrics.OutlierEjections.WithLabelValues(serviceName).Inc()
+	metrics.OutlierEjected.WithLabelValues(serviceName).Inc()
+	time.AfterFunc(ejection, func() {
+		metrics.OutlierEjected.WithLabelValues(serviceName).Dec()
+	})
+	p.logger.Warn("Instance ejected by outlier detection",
+		zap.String("service", serviceName),
+		zap.String("instance", instance),
+		zap.Duration("ejection", ejection),
+	)
+}
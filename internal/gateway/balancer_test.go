@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"main/internal/metrics"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+func newTestProxy(t *testing.T, services ...config.ServiceConfig) *Proxy {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Upstream.Services = services
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Second, Timeout: time.Second, MinRequests: 1, FailureRatio: 1}
+	p, err := NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewProxy: %v", err)
+	}
+	return p
+}
+
+func TestPickInstanceUsesDiscoveredInstances(t *testing.T) {
+	p := newTestProxy(t, config.ServiceConfig{Name: "orders", URL: "http://orders:3000"})
+	if got := p.PickInstance("orders", ""); got != "http://orders:3000" {
+		t.Fatalf("before discovery picked %s, want the configured URL", got)
+	}
+
+	p.Reload([]config.ServiceConfig{{Name: "orders", Instances: []string{"http://10.0.0.5:3000"}}})
+	if got := p.PickInstance("orders", ""); got != "http://10.0.0.5:3000" {
+		t.Fatalf("after discovery picked %s, want the discovered instance", got)
+	}
+}
+
+func TestPickInstanceAffinity(t *testing.T) {
+	p := newTestProxy(t, config.ServiceConfig{
+		Name:      "carts",
+		Instances: []string{"http://a:1", "http://b:1", "http://c:1"},
+	})
+	first := p.PickInstance("carts", "user-42")
+	for i := 0; i < 10; i++ {
+		if got := p.PickInstance("carts", "user-42"); got != first {
+			t.Fatalf("key moved from %s to %s", first, got)
+		}
+	}
+
+	// An unreachable instance is skipped until it recovers
+	p.ReportInstance("carts", first, InstanceUnreachable)
+	if got := p.PickInstance("carts", "user-42"); got == first {
+		t.Fatalf("picked unreachable instance %s", got)
+	}
+}
+
+func TestOutlierEjection(t *testing.T) {
+	outlier := config.OutlierConfig{Consecutive5xx: 3, BaseEjectionTime: time.Minute, MaxEjectionTime: 3 * time.Minute, MaxEjectionPercent: 50}
+	b := newBalancer(config.ServiceConfig{Name: "carts", Instances: []string{"http://a:1", "http://b:1", "http://c:1", "http://d:1"}}, outlier)
+	fail := func(instance string, n int) time.Duration {
+		t.Helper()
+		var ejection time.Duration
+		for range n {
+			ejection = b.report(instance, InstanceServerError)
+		}
+		return ejection
+	}
+
+	// A success breaks the streak
+	fail("http://a:1", 2)
+	b.report("http://a:1", InstanceSuccess)
+	if got := fail("http://a:1", 2); got != 0 {
+		t.Fatalf("ejected after a broken streak for %v", got)
+	}
+	if got := fail("http://a:1", 1); got != time.Minute {
+		t.Fatalf("third failure in a row ejected for %v, want %v", got, time.Minute)
+	}
+	if slices.Contains(b.available(), "http://a:1") {
+		t.Fatal("ejected instance still available")
+	}
+
+	// Half of the four instances may be out at once
+	if got := fail("http://b:1", 3); got != time.Minute {
+		t.Fatalf("second instance ejected for %v, want %v", got, time.Minute)
+	}
+	if got := fail("http://c:1", 3); got != 0 {
+		t.Fatalf("ejection past the maximum percentage for %v", got)
+	}
+	if !slices.Contains(b.available(), "http://c:1") {
+		t.Fatal("instance over the ejection limit taken out")
+	}
+
+	// Each ejection in a row lasts longer, up to the maximum
+	for _, want := range []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		b.outliers["http://a:1"].ejectedUntil = time.Now().Add(-time.Second)
+		if got := fail("http://a:1", 3); got != want {
+			t.Fatalf("repeated ejection lasted %v, want %v", got, want)
+		}
+	}
+	// and the count is forgotten once the instance has stayed in long enough
+	b.outliers["http://a:1"].ejectedUntil = time.Now().Add(-4 * time.Minute)
+	if got := fail("http://a:1", 3); got != time.Minute {
+		t.Fatalf("ejection after a long healthy spell lasted %v, want %v", got, time.Minute)
+	}
+}
+
+func TestOutlierEjectionSingleInstance(t *testing.T) {
+	outlier := config.OutlierConfig{Consecutive5xx: 1, BaseEjectionTime: time.Minute, MaxEjectionTime: time.Minute, MaxEjectionPercent: 100}
+	b := newBalancer(config.ServiceConfig{Name: "orders", URL: "http://orders:3000"}, outlier)
+	for range 3 {
+		if got := b.report("http://orders:3000", InstanceServerError); got != 0 {
+			t.Fatalf("only instance ejected for %v", got)
+		}
+	}
+}
+
+func TestReportInstanceCountsEjections(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Upstream.Services = []config.ServiceConfig{{Name: "carts", Instances: []string{"http://a:1", "http://b:1"}}}
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Second, Timeout: time.Second, MinRequests: 1, FailureRatio: 1}
+	cfg.Upstream.Outlier = config.OutlierConfig{Consecutive5xx: 2, BaseEjectionTime: time.Minute, MaxEjectionTime: time.Minute, MaxEjectionPercent: 50}
+	p, err := NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := testutil.ToFloat64(metrics.OutlierEjections.WithLabelValues("carts"))
+	p.ReportInstance("carts", "http://a:1", InstanceServerError)
+	p.ReportInstance("carts", "http://a:1", InstanceServerError)
+	if got := testutil.ToFloat64(metrics.OutlierEjections.WithLabelValues("carts")) - before; got != 1 {
+		t.Fatalf("ejections counted = %v, want 1", got)
+	}
+	for range 10 {
+		if got := p.PickInstance("carts", ""); got != "http://b:1" {
+			t.Fatalf("picked %s while it is ejected", got)
+		}
+	}
+}
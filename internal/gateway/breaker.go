@@ -0,0 +1,176 @@
+package gateway
+
+import (
+	"fmt"
+	"main/internal/config"
+	"main/internal/metrics"
+	"sync"
+
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+// Manual circuit breaker actions for OverrideBreaker
+const (
+	// BreakerOpen holds the circuit open, rejecting every request, until reset
+	BreakerOpen = "open"
+	// BreakerClose holds the circuit closed, letting every request through
+	// without counting failures, until reset
+	BreakerClose = "close"
+	// BreakerReset clears any override and restarts the circuit closed with
+	// its failure counts cleared
+	BreakerReset = "reset"
+)
+
+// circuitBreaker is a service's gobreaker with a manual override, which
+// gobreaker can't express itself. A reset swaps in a fresh breaker.
+type circuitBreaker struct {
+	name     string
+	settings config.CircuitBreakerConfig
+	log      *zap.Logger
+
+	mu       sync.RWMutex
+	cb       *gobreaker.TwoStepCircuitBreaker
+	override string
+}
+
+// newCircuitBreaker builds a service's breaker from its merged settings
+func newCircuitBreaker(name string, cfg config.CircuitBreakerConfig, log *zap.Logger) *circuitBreaker {
+	b := &circuitBreaker{name: name, settings: cfg, log: log}
+	b.cb = b.build()
+	return b
+}
+
+func (b *circuitBreaker) build() *gobreaker.TwoStepCircuitBreaker {
+	metrics.CircuitBreakerState.WithLabelValues(b.name).Set(float64(gobreaker.StateClosed))
+
+	cfg := b.settings
+	return gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:        b.name,
+		MaxRequests: uint32(cfg.MaxRequests),
+		Interval:    cfg.Interval,
+		Timeout:     cfg.Timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
+			return counts.Requests >= uint32(cfg.MinRequests) && failureRatio >= cfg.FailureRatio
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+			b.log.Info("Circuit breaker state changed",
+				zap.String("service", name),
+				zap.String("from", from.String()),
+				zap.String("to", to.String()),
+			)
+		},
+	})
+}
+
+// State returns the circuit's state, as forced by an override if any
+func (b *circuitBreaker) State() gobreaker.State {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	switch b.override {
+	case BreakerOpen:
+		return gobreaker.StateOpen
+	case BreakerClose:
+		return gobreaker.StateClosed
+	}
+	return b.cb.State()
+}
+
+// Override returns the manual action in force, or "" when there is none
+func (b *circuitBreaker) Override() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.override
+}
+
+// Allow asks the circuit to let a request through, as gobreaker's Allow
+func (b *circuitBreaker) Allow() (func(success bool), error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	switch b.override {
+	case BreakerOpen:
+		return nil, gobreaker.ErrOpenState
+	case BreakerClose:
+		return func(bool) {}, nil
+	}
+	return b.cb.Allow()
+}
+
+// apply carries out a manual action
+func (b *circuitBreaker) apply(action string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch action {
+	case BreakerOpen:
+		metrics.CircuitBreakerState.WithLabelValues(b.name).Set(float64(gobreaker.StateOpen))
+		b.override = action
+	case BreakerClose:
+		metrics.CircuitBreakerState.WithLabelValues(b.name).Set(float64(gobreaker.StateClosed))
+		b.override = action
+	case BreakerReset:
+		b.override = ""
+		b.cb = b.build()
+	default:
+		return fmt.Errorf("unknown circuit breaker action %q", action)
+	}
+	return nil
+}
+
+// OverrideBreaker applies a manual action, BreakerOpen, BreakerClose or
+// BreakerReset, to a service's circuit breaker and returns the resulting
+// state
+func (p *Proxy) OverrideBreaker(serviceName, action string) (gobreaker.State, error) {
+	cb, exists := p.circuitBreakers[serviceName]
+	if !exists {
+		return 0, fmt.Errorf("unknown service %s", serviceName)
+	}
+	if err := cb.apply(action); err != nil {
+		return 0, err
+	}
+	return cb.State(), nil
+}
+
+// Allow asks a service's circuit breaker to let a request through. It fails
+// with gobreaker.ErrOpenState or ErrTooManyRequests when the circuit is open
+// or its half-open probes are all in flight. Otherwise the caller must call
+// done with the request's failure, or nil on success. Services without a
+// breaker always pass.
+func (p *Proxy) Allow(serviceName string) (done func(failure error), err error) {
+	cb, exists := p.circuitBreakers[serviceName]
+	if !exists {
+		return func(error) {}, nil
+	}
+
+	probe := cb.State() == gobreaker.StateHalfOpen
+	report, err := cb.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(failure error) {
+		report(failure == nil)
+		if !probe {
+			return
+		}
+		if failure != nil {
+			metrics.CircuitBreakerProbes.WithLabelValues(serviceName, "failure").Inc()
+			p.logger.Warn("Half-open probe failed, circuit re-opened",
+				zap.String("service", serviceName),
+				zap.Error(failure),
+			)
+			return
+		}
+		metrics.CircuitBreakerProbes.WithLabelValues(serviceName, "success").Inc()
+		p.logger.Info("Half-open probe succeeded",
+			zap.String("service", serviceName),
+			zap.String("state", cb.State().String()),
+		)
+	}, nil
+}
+
+// BreakerConfig returns the circuit breaker settings in force for a service
+func (p *Proxy) BreakerConfig(serviceName string) config.CircuitBreakerConfig {
+	return p.breakerConfigs[serviceName]
+}
@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"errors"
+	"main/internal/config"
+	"testing"
+
+	"github.com/sony/gobreaker"
+)
+
+func TestOverrideBreaker(t *testing.T) {
+	p := newTestProxy(t, config.ServiceConfig{Name: "orders", URL: "http://orders:3000"})
+
+	// Forced open rejects every request, and the circuit's own state is hidden
+	if state, err := p.OverrideBreaker("orders", BreakerOpen); err != nil || state != gobreaker.StateOpen {
+		t.Fatalf("open = %v, %v, want open", state, err)
+	}
+	if _, err := p.Allow("orders"); !errors.Is(err, gobreaker.ErrOpenState) {
+		t.Fatalf("Allow with the circuit forced open = %v, want ErrOpenState", err)
+	}
+
+	// Reset clears the override and a single failure trips the fresh breaker
+	if state, err := p.OverrideBreaker("orders", BreakerReset); err != nil || state != gobreaker.StateClosed {
+		t.Fatalf("reset = %v, %v, want closed", state, err)
+	}
+	done, err := p.Allow("orders")
+	if err != nil {
+		t.Fatalf("Allow after reset: %v", err)
+	}
+	done(errors.New("refused"))
+	if got := p.GetServiceHealth("orders"); got != gobreaker.StateOpen.String() {
+		t.Fatalf("after a failure the circuit is %s, want open", got)
+	}
+
+	// Forced closed lets requests through a tripped circuit without counting them
+	if _, err := p.OverrideBreaker("orders", BreakerClose); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		done, err := p.Allow("orders")
+		if err != nil {
+			t.Fatalf("Allow with the circuit forced closed: %v", err)
+		}
+		done(errors.New("refused"))
+	}
+
+	// Resetting a tripped circuit closes it with its counts cleared
+	if _, err := p.OverrideBreaker("orders", BreakerReset); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Allow("orders"); err != nil {
+		t.Fatalf("Allow after resetting a tripped circuit: %v", err)
+	}
+	if counts := p.circuitBreakers["orders"].cb.Counts(); counts.TotalFailures != 0 {
+		t.Errorf("failures after reset = %d, want 0", counts.TotalFailures)
+	}
+}
+
+func TestOverrideBreakerRejectsUnknown(t *testing.T) {
+	p := newTestProxy(t, config.ServiceConfig{Name: "orders", URL: "http://orders:3000"})
+	if _, err := p.OverrideBreaker("billing", BreakerOpen); err == nil {
+		t.Error("OverrideBreaker accepted an unknown service")
+	}
+	if _, err := p.OverrideBreaker("orders", "half-open"); err == nil {
+		t.Error("OverrideBreaker accepted an unknown action")
+	}
+}
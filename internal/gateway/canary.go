@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"main/internal/metrics"
+)
+
+// Canary tracks for metrics.CanaryRequests
+const (
+	TrackCanary = "canary"
+	TrackStable = "stable"
+)
+
+// canary serves a share of a service's clients from a canary backend
+type canary struct {
+	target string
+	// bucketsServed of every 10000 buckets go to the canary
+	bucketsServed uint32
+}
+
+func newCanary(cfg *config.CanaryConfig) *canary {
+	if cfg == nil {
+		return nil
+	}
+	return &canary{target: cfg.URL, bucketsServed: uint32(cfg.Weight * 100)}
+}
+
+// selects reports whether the client with key falls in the canary's share.
+// The same key always lands in the same bucket.
+func (c *canary) selects(key string) bool {
+	return hashKey(key)%10000 < c.bucketsServed
+}
+
+// Canary returns the canary backend of a service when it serves this request:
+// when the request opted in, or when its client key falls in the canary's
+// share. Services without a canary report false and are not counted.
+func (p *Proxy) Canary(serviceName, key string, optedIn bool) (string, bool) {
+	c := p.canaries[serviceName]
+	if c == nil {
+		return "", false
+	}
+	if optedIn || c.selects(key) {
+		metrics.CanaryRequests.WithLabelValues(serviceName, TrackCanary).Inc()
+		return c.target, true
+	}
+	metrics.CanaryRequests.WithLabelValues(serviceName, TrackStable).Inc()
+	return "", false
+}
@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"fmt"
+	"main/internal/config"
+	"main/internal/metrics"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCanaryWeight(t *testing.T) {
+	p := newTestProxy(t, config.ServiceConfig{
+		Name: "search", URL: "http://search:3000",
+		Canary: &config.CanaryConfig{URL: "http://search-canary:3000", Weight: 20},
+	})
+
+	canaryBefore := testutil.ToFloat64(metrics.CanaryRequests.WithLabelValues("search", TrackCanary))
+	stableBefore := testutil.ToFloat64(metrics.CanaryRequests.WithLabelValues("search", TrackStable))
+	served := 0
+	for i := range 10000 {
+		key := fmt.Sprintf("user:%d", i)
+		target, ok := p.Canary("search", key, false)
+		if ok {
+			served++
+			if target != "http://search-canary:3000" {
+				t.Fatalf("canary target = %s", target)
+			}
+		}
+		// A client always stays on its track
+		if _, again := p.Canary("search", key, false); again != ok {
+			t.Fatalf("%s switched tracks", key)
+		}
+	}
+	if served < 1800 || served > 2200 {
+		t.Fatalf("canary served %d of 10000 clients, want about 20%%", served)
+	}
+	if got := testutil.ToFloat64(metrics.CanaryRequests.WithLabelValues("search", TrackCanary)) - canaryBefore; got != float64(2*served) {
+		t.Fatalf("canary requests counted = %v, want %d", got, 2*served)
+	}
+	if got := testutil.ToFloat64(metrics.CanaryRequests.WithLabelValues("search", TrackStable)) - stableBefore; got != float64(2*(10000-served)) {
+		t.Fatalf("stable requests counted = %v, want %d", got, 2*(10000-served))
+	}
+}
+
+func TestCanaryOptIn(t *testing.T) {
+	p := newTestProxy(t,
+		config.ServiceConfig{Name: "orders", URL: "http://orders:3000",
+			Canary: &config.CanaryConfig{URL: "http://orders-canary:3000", Header: "X-Canary"}},
+		config.ServiceConfig{Name: "plain", URL: "http://plain:3000"},
+	)
+
+	// Without a weight only opted-in requests reach the canary
+	if _, ok := p.Canary("orders", "user:1", false); ok {
+		t.Fatal("zero-weight canary served a request that did not opt in")
+	}
+	if target, ok := p.Canary("orders", "user:1", true); !ok || target != "http://orders-canary:3000" {
+		t.Fatalf("opted-in request went to %q, %v", target, ok)
+	}
+	if _, ok := p.Canary("plain", "user:1", true); ok {
+		t.Fatal("service without a canary routed to one")
+	}
+}
@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrServiceBusy is returned when a service's concurrency limit is reached
+var ErrServiceBusy = errors.New("service concurrency limit reached")
+
+// concurrencyLimiter caps in-flight requests to one service. Without a limit
+// it only counts them. The queue depth is also exported in queueDepth.
+type concurrencyLimiter struct {
+	sem        *semaphore.Weighted
+	wait       time.Duration
+	inFlight   atomic.Int64
+	queued     atomic.Int64
+	queueDepth prometheus.Gauge
+}
+
+func newConcurrencyLimiter(max int, wait time.Duration, queueDepth prometheus.Gauge) *concurrencyLimiter {
+	l := &concurrencyLimiter{wait: wait, queueDepth: queueDepth}
+	if max > 0 {
+		l.sem = semaphore.NewWeighted(int64(max))
+	}
+	return l
+}
+
+func (l *concurrencyLimiter) acquire(ctx context.Context) (func(), error) {
+	// Acquire may succeed on a done context, so cancelled callers are turned away first
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if l.sem != nil && !l.sem.TryAcquire(1) {
+		if l.wait <= 0 {
+			return nil, ErrServiceBusy
+		}
+		if err := l.queue(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	l.inFlight.Add(1)
+	return func() {
+		l.inFlight.Add(-1)
+		if l.sem != nil {
+			l.sem.Release(1)
+		}
+	}, nil
+}
+
+// queue waits up to l.wait for a slot. A caller cancelled while queued leaves
+// at once without taking a slot.
+func (l *concurrencyLimiter) queue(ctx context.Context) error {
+	l.queued.Add(1)
+	l.queueDepth.Inc()
+	defer func() {
+		l.queued.Add(-1)
+		l.queueDepth.Dec()
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, l.wait)
+	defer cancel()
+
+	if err := l.sem.Acquire(waitCtx, 1); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrServiceBusy
+	}
+	return nil
+}
+
+// Acquire reserves a request slot for the service, queueing for the service's
+// queue timeout when it is at capacity. It returns ErrServiceBusy when no slot
+// frees up in time, or the context error when ctx ends first. The returned
+// function releases the slot. Unknown services are not limited.
+func (p *Proxy) Acquire(ctx context.Context, serviceName string) (func(), error) {
+	limiter, exists := p.limiters[serviceName]
+	if !exists {
+		return func() {}, nil
+	}
+	return limiter.acquire(ctx)
+}
+
+// Queued returns the number of requests waiting for a slot of the service
+func (p *Proxy) Queued(serviceName string) int64 {
+	if limiter, exists := p.limiters[serviceName]; exists {
+		return limiter.queued.Load()
+	}
+	return 0
+}
+
+// InFlight returns the number of requests currently sent to the service
+func (p *Proxy) InFlight(serviceName string) int64 {
+	if limiter, exists := p.limiters[serviceName]; exists {
+		return limiter.inFlight.Load()
+	}
+	return 0
+}
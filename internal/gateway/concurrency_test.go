@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"main/internal/config"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	depth := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_queue_depth"})
+	l := newConcurrencyLimiter(1, time.Second, depth)
+
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second request queues until the first releases its slot
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := l.acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	waitFor(t, func() bool { return testutil.ToFloat64(depth) == 1 })
+	if l.queued.Load() != 1 {
+		t.Fatalf("queued = %d, want 1", l.queued.Load())
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Fatalf("queued request: %v", err)
+	}
+	if got := testutil.ToFloat64(depth); got != 0 {
+		t.Fatalf("queue depth after dequeue = %v, want 0", got)
+	}
+}
+
+func TestConcurrencyLimiterQueueTimeout(t *testing.T) {
+	depth := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_queue_depth"})
+	l := newConcurrencyLimiter(1, 20*time.Millisecond, depth)
+	release, _ := l.acquire(context.Background())
+	defer release()
+
+	if _, err := l.acquire(context.Background()); !errors.Is(err, ErrServiceBusy) {
+		t.Fatalf("err = %v, want ErrServiceBusy", err)
+	}
+	if got := testutil.ToFloat64(depth); got != 0 {
+		t.Fatalf("queue depth after timeout = %v, want 0", got)
+	}
+}
+
+func TestLimitedServiceGetsOwnPool(t *testing.T) {
+	p := newTestProxy(t,
+		config.ServiceConfig{Name: "reports", URL: "http://reports:3000", MaxConcurrent: 4},
+		config.ServiceConfig{Name: "orders", URL: "http://orders:3000"},
+	)
+
+	if p.Transport("reports") == p.Transport("orders") {
+		t.Fatal("a limited service shares the pool of unlimited ones")
+	}
+	if got := p.Transport("reports").(*http.Transport).MaxConnsPerHost; got != 4 {
+		t.Errorf("limited service pool allows %d connections, want its limit of 4", got)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition never met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
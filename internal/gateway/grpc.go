@@ -0,0 +1,252 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/internal/auth"
+	"main/internal/config"
+	"main/internal/metrics"
+	"main/internal/models"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// gRPC status codes sent by the gateway itself
+const (
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+// GRPCProxy forwards gRPC calls over HTTP/2 to services of type "grpc".
+// Bodies and trailers are streamed through, so unary and streaming calls work.
+type GRPCProxy struct {
+	proxy   *Proxy
+	logger  *zap.Logger
+	tokens  *auth.TokenValidator
+	keys    *auth.APIKeyValidator
+	routes  map[string]string // gRPC service name -> gateway service
+	catch   string            // service receiving calls not listed in routes
+	proxies map[string]*httputil.ReverseProxy
+}
+
+// NewGRPCProxy routes calls to the grpc services of cfg. Callers authenticate
+// like HTTP clients, with a bearer token or, when keys is set, an API key.
+func NewGRPCProxy(cfg *config.Config, p *Proxy, tokens *auth.TokenValidator, keys *auth.APIKeyValidator, log *zap.Logger) (*GRPCProxy, error) {
+	g := &GRPCProxy{
+		proxy:   p,
+		logger:  log,
+		tokens:  tokens,
+		keys:    keys,
+		routes:  make(map[string]string),
+		proxies: make(map[string]*httputil.ReverseProxy),
+	}
+
+	for _, service := range cfg.Upstream.Services {
+		if service.Type != config.ServiceTypeGRPC {
+			continue
+		}
+		if len(service.GRPCServices) == 0 {
+			if g.catch != "" {
+				return nil, fmt.Errorf("grpc services %s and %s both accept every call", g.catch, service.Name)
+			}
+			g.catch = service.Name
+		}
+		for _, name := range service.GRPCServices {
+			if other, exists := g.routes[name]; exists {
+				return nil, fmt.Errorf("grpc service %s is routed to both %s and %s", name, other, service.Name)
+			}
+			g.routes[name] = service.Name
+		}
+		g.proxies[service.Name] = g.newReverseProxy(service.Name)
+	}
+	return g, nil
+}
+
+func (g *GRPCProxy) newReverseProxy(serviceName string) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			target, _ := url.Parse(r.In.Context().Value(grpcTargetContext{}).(string))
+			r.SetURL(target)
+			r.SetXForwarded()
+		},
+		Transport: g.proxy.clientFor(serviceName).Transport,
+		// Flush every frame so streaming calls aren't held back
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			g.logger.Warn("gRPC upstream call failed",
+				zap.String("service", serviceName),
+				zap.String("method", r.URL.Path),
+				zap.Error(err),
+			)
+			if recorder, ok := w.(*grpcStatusRecorder); ok {
+				recorder.upstreamErr = err
+			}
+			writeGRPCError(w, grpcUnavailable, "upstream unavailable")
+		},
+	}
+}
+
+type grpcTargetContext struct{}
+
+func (g *GRPCProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err := g.authenticate(r); err != nil {
+		writeGRPCError(w, grpcUnauthenticated, "unauthenticated")
+		return
+	}
+
+	// Paths are /package.Service/Method
+	grpcService, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	serviceName, routed := g.routes[grpcService]
+	if !routed {
+		serviceName = g.catch
+	}
+	reverseProxy, exists := g.proxies[serviceName]
+	if !exists {
+		writeGRPCError(w, grpcUnimplemented, "unknown service "+grpcService)
+		return
+	}
+
+	release, err := g.proxy.Acquire(r.Context(), serviceName)
+	if err != nil {
+		writeGRPCError(w, grpcResourceExhausted, "service overloaded")
+		return
+	}
+	defer release()
+
+	// Keep calls off a service whose circuit is open, as on the HTTP path
+	breakerDone, err := g.proxy.Allow(serviceName)
+	if err != nil {
+		metrics.UpstreamErrors.WithLabelValues(serviceName, string(models.ErrCodeCircuitOpen)).Inc()
+		writeGRPCError(w, grpcUnavailable, "service unavailable, circuit open")
+		return
+	}
+
+	// Cookies mean nothing to gRPC clients, so only user affinity applies
+	var affinityKey string
+	if g.proxy.services[serviceName].Affinity == AffinityUser {
+		affinityKey = r.Header.Get("X-User-ID")
+	}
+	instance := g.proxy.PickInstance(serviceName, affinityKey)
+	r = r.WithContext(context.WithValue(r.Context(), grpcTargetContext{}, instance))
+
+	start := time.Now()
+	recorder := &grpcStatusRecorder{ResponseWriter: w}
+	reverseProxy.ServeHTTP(recorder, r)
+	// A caller that went away says nothing about the instance, but must still
+	// settle its breaker slot
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		breakerDone(nil)
+	} else {
+		elapsed, failure := time.Since(start), recorder.failure()
+		breakerDone(failure)
+		switch {
+		case recorder.upstreamErr != nil:
+			g.proxy.ReportInstance(serviceName, instance, InstanceUnreachable)
+		case failure != nil:
+			g.proxy.ReportInstance(serviceName, instance, InstanceServerError)
+		default:
+			g.proxy.ReportInstance(serviceName, instance, InstanceSuccess)
+		}
+		metrics.Proxy.Record(serviceName, elapsed, failure != nil)
+		g.proxy.Observe(serviceName, elapsed, failure)
+	}
+}
+
+// authenticate checks the caller's API key or bearer token and passes its
+// identity upstream in the same headers HTTP requests get
+func (g *GRPCProxy) authenticate(r *http.Request) error {
+	for _, name := range []string{"X-User-ID", "X-Username", "X-User-Email", "X-User-Role"} {
+		r.Header.Del(name)
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" && g.keys != nil {
+		identity, err := g.keys.ValidateKey(key)
+		if err != nil {
+			return err
+		}
+		r.Header.Set("X-User-ID", identity.ClientID)
+		r.Header.Set("X-Username", identity.ClientID)
+		r.Header.Set("X-User-Role", identity.Role)
+		return nil
+	}
+
+	token, err := auth.ExtractToken(r.Header.Get("Authorization"))
+	if err != nil {
+		return err
+	}
+	claims, err := g.tokens.ValidateToken(token)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("X-User-ID", claims.UserID)
+	r.Header.Set("X-Username", claims.Username)
+	r.Header.Set("X-User-Email", claims.Email)
+	r.Header.Set("X-User-Role", claims.Role)
+	return nil
+}
+
+// writeGRPCError answers with a trailers-only gRPC error
+func writeGRPCError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", message)
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcStatusRecorder notes the HTTP status, gRPC status and transport error of a proxied call
+type grpcStatusRecorder struct {
+	http.ResponseWriter
+	status      int
+	upstreamErr error
+}
+
+func (r *grpcStatusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *grpcStatusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush through the recorder
+func (r *grpcStatusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// failure describes a call that failed at the transport level or with a
+// server-side gRPC status, and is nil otherwise
+func (r *grpcStatusRecorder) failure() error {
+	if r.upstreamErr != nil {
+		return r.upstreamErr
+	}
+	if r.status >= http.StatusInternalServerError {
+		return fmt.Errorf("upstream responded %d", r.status)
+	}
+	status := r.Header().Get("Grpc-Status")
+	if status == "" {
+		status = r.Header().Get(http.TrailerPrefix + "Grpc-Status")
+	}
+	switch status {
+	case "13", "14", "15": // INTERNAL, UNAVAILABLE, DATA_LOSS
+		return fmt.Errorf("upstream gRPC status %s", status)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"main/internal/auth"
+	"main/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startH2C serves handler over cleartext HTTP/2
+func startH2C(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func h2cClient() *http.Client {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: &http.Transport{Protocols: protocols}}
+}
+
+func newTestGRPCProxy(t *testing.T, backendURL string) *GRPCProxy {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.APIKeys.Keys = []config.APIKeyEntry{{Key: "test-key", ClientID: "billing", Role: "service"}}
+	cfg.Upstream.CircuitBreaker = config.CircuitBreakerConfig{MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, MinRequests: 1, FailureRatio: 1}
+	cfg.Upstream.Services = []config.ServiceConfig{{
+		Name: "greeter", Type: config.ServiceTypeGRPC, URL: backendURL, Protocol: "h2c", Affinity: AffinityNone,
+	}}
+
+	p, err := NewProxy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewProxy: %v", err)
+	}
+	g, err := NewGRPCProxy(cfg, p, auth.NewTokenValidator(cfg, zap.NewNop()), auth.NewAPIKeyValidator(cfg, zap.NewNop()), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewGRPCProxy: %v", err)
+	}
+	return g
+}
+
+func callGRPC(t *testing.T, gatewayURL string, body []byte) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, gatewayURL+"/helloworld.Greeter/SayHello", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("X-API-Key", "test-key")
+	resp, err := h2cClient().Do(req)
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestGRPCProxyRoundTrip(t *testing.T) {
+	backend := startH2C(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != "/helloworld.Greeter/SayHello" {
+			t.Errorf("backend got %s %s", r.Proto, r.URL.Path)
+		}
+		if got := r.Header.Get("X-User-ID"); got != "billing" {
+			t.Errorf("X-User-ID = %q, want the API key's client", got)
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(body)
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	gateway := startH2C(t, newTestGRPCProxy(t, backend.URL))
+
+	message := []byte{0, 0, 0, 0, 3, 'a', 'b', 'c'}
+	resp := callGRPC(t, gateway.URL, message)
+	body, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(body, message) {
+		t.Fatalf("body = %v, want the echoed message", body)
+	}
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Fatalf("Grpc-Status trailer = %q, want 0", got)
+	}
+}
+
+func TestGRPCProxyCircuitBreaker(t *testing.T) {
+	calls := 0
+	backend := startH2C(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "14")
+		w.WriteHeader(http.StatusOK)
+	}))
+	gateway := startH2C(t, newTestGRPCProxy(t, backend.URL))
+
+	// The first failure opens the circuit; the second call never reaches the backend
+	callGRPC(t, gateway.URL, nil)
+	resp := callGRPC(t, gateway.URL, nil)
+	io.ReadAll(resp.Body)
+	if calls != 1 {
+		t.Fatalf("backend called %d times, want 1", calls)
+	}
+	if got := resp.Header.Get("Grpc-Message"); got != "service unavailable, circuit open" {
+		t.Fatalf("Grpc-Message = %q", got)
+	}
+}
@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"main/internal/config"
+	"main/internal/metrics"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// mirrorTimeout bounds each mirrored request
+	mirrorTimeout = 10 * time.Second
+	// maxMirrorsInFlight caps a service's concurrent mirrored requests, so a
+	// slow shadow backend can't pile up goroutines
+	maxMirrorsInFlight = 100
+)
+
+// mirrorHeader marks mirrored requests so the shadow backend can tell them apart
+const mirrorHeader = "X-Gateway-Mirror"
+
+// credentialHeaders are removed from mirrored requests unless the service opts
+// in, so live credentials don't reach the shadow backend
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key"}
+
+// mirror copies a sample of a service's requests to a shadow backend
+type mirror struct {
+	target             *url.URL
+	percent            float64
+	forwardCredentials bool
+	inFlight           chan struct{}
+}
+
+func newMirror(cfg *config.MirrorConfig) (*mirror, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	target, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	return &mirror{
+		target:             target,
+		percent:            cfg.Percent,
+		forwardCredentials: cfg.ForwardCredentials,
+		inFlight:           make(chan struct{}, maxMirrorsInFlight),
+	}, nil
+}
+
+// Mirror sends a copy of req, as prepared for the service's upstream, to the
+// service's shadow backend when it is sampled. Credentials are stripped unless
+// the service forwards them. body is copied before Mirror
+// returns; the copy is sent in the background with its own context and
+// transport, and its response and errors are ignored.
+func (p *Proxy) Mirror(serviceName string, req *http.Request, body []byte) {
+	m := p.mirrors[serviceName]
+	if m == nil || rand.Float64()*100 >= m.percent {
+		return
+	}
+	select {
+	case m.inFlight <- struct{}{}:
+	default:
+		metrics.MirrorRequests.WithLabelValues(serviceName, "dropped").Inc()
+		return
+	}
+
+	target := *m.target
+	target.Path = req.URL.Path
+	target.RawPath = req.URL.RawPath
+	target.RawQuery = req.URL.RawQuery
+	header := req.Header.Clone()
+	if !m.forwardCredentials {
+		for _, name := range credentialHeaders {
+			header.Del(name)
+		}
+	}
+	header.Set(mirrorHeader, "1")
+	body = bytes.Clone(body)
+
+	go func() {
+		defer func() { <-m.inFlight }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+		defer cancel()
+		shadow, err := http.NewRequestWithContext(ctx, req.Method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			metrics.MirrorRequests.WithLabelValues(serviceName, "failed").Inc()
+			return
+		}
+		shadow.Header = header
+
+		resp, err := p.mirrorClient.Do(shadow)
+		if err != nil {
+			metrics.MirrorRequests.WithLabelValues(serviceName, "failed").Inc()
+			p.logger.Debug("Mirrored request failed", zap.String("service", serviceName), zap.Error(err))
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		metrics.MirrorRequests.WithLabelValues(serviceName, "sent").Inc()
+	}()
+}
@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func mirroredHeaders(t *testing.T, forwardCredentials bool) http.Header {
+	t.Helper()
+	received := make(chan http.Header, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	t.Cleanup(shadow.Close)
+
+	p := newTestProxy(t, config.ServiceConfig{
+		Name:   "payments",
+		URL:    "http://payments:3000",
+		Mirror: &config.MirrorConfig{URL: shadow.URL, Percent: 100, ForwardCredentials: forwardCredentials},
+	})
+	req := httptest.NewRequest(http.MethodPost, "http://payments:3000/charges", nil)
+	req.Header.Set("Authorization", "Bearer live-token")
+	req.Header.Set("Cookie", "session=abc")
+	req.Header.Set("X-API-Key", "live-key")
+	req.Header.Set("X-User-ID", "42")
+	p.Mirror("payments", req, nil)
+
+	select {
+	case header := <-received:
+		return header
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirrored request never arrived")
+		return nil
+	}
+}
+
+func TestMirrorStripsCredentials(t *testing.T) {
+	header := mirroredHeaders(t, false)
+	for _, name := range credentialHeaders {
+		if header.Get(name) != "" {
+			t.Errorf("%s reached the shadow backend", name)
+		}
+	}
+	if header.Get("X-User-ID") != "42" || header.Get(mirrorHeader) != "1" {
+		t.Errorf("headers = %v, want identity and mirror marker kept", header)
+	}
+}
+
+func TestMirrorForwardsCredentialsWhenEnabled(t *testing.T) {
+	header := mirroredHeaders(t, true)
+	if header.Get("Authorization") != "Bearer live-token" || header.Get("X-API-Key") != "live-key" {
+		t.Errorf("headers = %v, want credentials forwarded", header)
+	}
+}
@@ -0,0 +1,294 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"main/internal/config"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// openAPIFetchTimeout bounds fetching one service's document
+	openAPIFetchTimeout = 10 * time.Second
+	// openAPIMaxBytes caps the size of a service's document
+	openAPIMaxBytes = 10 << 20
+)
+
+// OpenAPIAggregator merges the OpenAPI documents of the HTTP services into
+// one document describing the whole gateway, and caches it until the next Refresh
+type OpenAPIAggregator struct {
+	proxy  *Proxy
+	path   string
+	title  string
+	logger *zap.Logger
+
+	mu     sync.RWMutex
+	merged []byte
+}
+
+// NewOpenAPIAggregator fetches each service's document from path
+func NewOpenAPIAggregator(p *Proxy, path, title string, log *zap.Logger) *OpenAPIAggregator {
+	return &OpenAPIAggregator{proxy: p, path: path, title: title, logger: log}
+}
+
+// Document returns the merged document, or nil before the first Refresh
+func (a *OpenAPIAggregator) Document() []byte {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.merged
+}
+
+// Refresh fetches every service's document and replaces the merged one.
+// Services whose document can't be fetched or parsed are left out, as are
+// tenant-scoped services, which serve the same paths as the default one.
+func (a *OpenAPIAggregator) Refresh(ctx context.Context) {
+	a.proxy.mu.RLock()
+	services := make([]config.ServiceConfig, 0, len(a.proxy.services))
+	for _, service := range a.proxy.services {
+		if service.Type != config.ServiceTypeGRPC && service.Tenant == "" {
+			services = append(services, *service)
+		}
+	}
+	a.proxy.mu.RUnlock()
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	docs := make([]map[string]any, len(services))
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc, err := a.fetch(ctx, service)
+			if err != nil {
+				a.logger.Warn("OpenAPI document unavailable, service left out",
+					zap.String("service", service.Name),
+					zap.Error(err),
+				)
+				return
+			}
+			docs[i] = doc
+		}()
+	}
+	wg.Wait()
+
+	merged := newOpenAPIMerger(a.title)
+	included := 0
+	for i, doc := range docs {
+		if doc != nil {
+			merged.add(services[i], doc, a.logger)
+			included++
+		}
+	}
+
+	body, err := json.Marshal(merged.doc)
+	if err != nil {
+		a.logger.Error("Failed to encode merged OpenAPI document", zap.Error(err))
+		return
+	}
+
+	a.mu.Lock()
+	a.merged = body
+	a.mu.Unlock()
+
+	a.logger.Info("OpenAPI documents merged",
+		zap.Int("services", included),
+		zap.Int("unavailable", len(services)-included),
+	)
+}
+
+func (a *OpenAPIAggregator) fetch(ctx context.Context, service config.ServiceConfig) (map[string]any, error) {
+	ctx, cancel := context.WithTimeout(ctx, openAPIFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(service.URL, "/")+a.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := (&http.Client{Transport: a.proxy.Transport(service.Name)}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var doc map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, openAPIMaxBytes)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if _, ok := doc["paths"].(map[string]any); !ok {
+		return nil, fmt.Errorf("OpenAPI document has no paths")
+	}
+	return doc, nil
+}
+
+// openAPIMerger builds the merged document. Each service's paths are prefixed
+// with its StripPrefix, the public path they are reached under, and its
+// components are renamed to "<service>.<name>" so services can't collide.
+type openAPIMerger struct {
+	doc        map[string]any
+	paths      map[string]any
+	components map[string]any
+	tags       map[string]bool
+}
+
+func newOpenAPIMerger(title string) *openAPIMerger {
+	m := &openAPIMerger{
+		paths:      make(map[string]any),
+		components: make(map[string]any),
+		tags:       make(map[string]bool),
+	}
+	m.doc = map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": title, "version": "1.0.0"},
+		"paths":   m.paths,
+	}
+	return m
+}
+
+var componentNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+func (m *openAPIMerger) add(service config.ServiceConfig, doc map[string]any, log *zap.Logger) {
+	namespace := componentNameUnsafe.ReplaceAllString(service.Name, "_")
+	rename := func(name string) string {
+		return namespace + "." + name
+	}
+
+	// The merged document claims the newest OpenAPI version of its parts
+	if version, ok := doc["openapi"].(string); ok && version > m.doc["openapi"].(string) {
+		m.doc["openapi"] = version
+	}
+
+	components, _ := doc["components"].(map[string]any)
+	for section, entries := range components {
+		entries, ok := entries.(map[string]any)
+		if !ok {
+			continue
+		}
+		merged, _ := m.components[section].(map[string]any)
+		if merged == nil {
+			merged = make(map[string]any)
+			m.components[section] = merged
+		}
+		for name, value := range entries {
+			merged[rename(name)] = rewriteRefs(value, rename)
+		}
+	}
+	if len(m.components) > 0 {
+		m.doc["components"] = m.components
+	}
+
+	// Security requirements name schemes, which were renamed with the components
+	renameSecurity := func(requirements any) any {
+		list, ok := requirements.([]any)
+		if !ok {
+			return requirements
+		}
+		renamed := make([]any, len(list))
+		for i, requirement := range list {
+			schemes, ok := requirement.(map[string]any)
+			if !ok {
+				renamed[i] = requirement
+				continue
+			}
+			out := make(map[string]any, len(schemes))
+			for scheme, scopes := range schemes {
+				out[rename(scheme)] = scopes
+			}
+			renamed[i] = out
+		}
+		return renamed
+	}
+	defaultSecurity, hasDefaultSecurity := doc["security"]
+
+	prefix := strings.TrimSuffix(service.StripPrefix, "/")
+	for path, item := range doc["paths"].(map[string]any) {
+		public := prefix + path
+		if _, exists := m.paths[public]; exists {
+			log.Warn("OpenAPI path defined by several services, keeping the first",
+				zap.String("path", public),
+				zap.String("service", service.Name),
+			)
+			continue
+		}
+
+		item = rewriteRefs(item, rename)
+		if operations, ok := item.(map[string]any); ok {
+			for _, method := range httpMethods {
+				operation, ok := operations[method].(map[string]any)
+				if !ok {
+					continue
+				}
+				// The service-wide default no longer applies once merged, so
+				// operations inherit it explicitly
+				if security, ok := operation["security"]; ok {
+					operation["security"] = renameSecurity(security)
+				} else if hasDefaultSecurity {
+					operation["security"] = renameSecurity(defaultSecurity)
+				}
+			}
+		}
+		m.paths[public] = item
+	}
+
+	if tags, ok := doc["tags"].([]any); ok {
+		for _, tag := range tags {
+			fields, _ := tag.(map[string]any)
+			name, _ := fields["name"].(string)
+			if name == "" || m.tags[name] {
+				continue
+			}
+			m.tags[name] = true
+			merged, _ := m.doc["tags"].([]any)
+			m.doc["tags"] = append(merged, tag)
+		}
+	}
+}
+
+// rewriteRefs returns value with every local component reference renamed
+func rewriteRefs(value any, rename func(string) string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if ref, ok := child.(string); key == "$ref" && ok {
+				v[key] = rewriteRef(ref, rename)
+				continue
+			}
+			v[key] = rewriteRefs(child, rename)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = rewriteRefs(child, rename)
+		}
+	}
+	return value
+}
+
+// rewriteRef renames the component in a "#/components/<section>/<name>" reference
+func rewriteRef(ref string, rename func(string) string) string {
+	rest, ok := strings.CutPrefix(ref, "#/components/")
+	if !ok {
+		return ref
+	}
+	section, name, ok := strings.Cut(rest, "/")
+	if !ok {
+		return ref
+	}
+	return "#/components/" + section + "/" + rename(name)
+}
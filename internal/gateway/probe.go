@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"main/internal/config"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sony/gobreaker"
+)
+
+// ProbeService checks that a service can take traffic: its circuit is not open
+// and at least one instance outside its failure cooldown passes its health
+// check, or accepts connections when it has none
+func (p *Proxy) ProbeService(ctx context.Context, serviceName string) error {
+	cb, exists := p.circuitBreakers[serviceName]
+	if !exists {
+		return fmt.Errorf("unknown service %s", serviceName)
+	}
+	if cb.State() == gobreaker.StateOpen {
+		return errors.New("circuit open")
+	}
+
+	instances := []string{p.GetServiceURL(serviceName)}
+	if b, exists := p.balancers[serviceName]; exists {
+		instances = b.available()
+	}
+	if len(instances) == 0 {
+		return errors.New("all instances cooling down after failures")
+	}
+
+	if check := p.services[serviceName].HealthCheck; check != nil {
+		return p.checkInstances(ctx, serviceName, check, instances)
+	}
+
+	var dialer net.Dialer
+	var lastErr error
+	for _, instance := range instances {
+		addr, err := dialAddr(instance)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn.Close()
+		return nil
+	}
+	return lastErr
+}
+
+// checkInstances sends the health check request to each instance in turn
+// until one passes, through the service's own client
+func (p *Proxy) checkInstances(ctx context.Context, serviceName string, check *config.HealthCheckConfig, instances []string) error {
+	client := p.clientFor(serviceName)
+	var lastErr error
+	for _, instance := range instances {
+		req, err := http.NewRequestWithContext(ctx, check.Method, strings.TrimSuffix(instance, "/")+check.Path, nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if check.Healthy(resp.StatusCode) {
+			return nil
+		}
+		lastErr = fmt.Errorf("%s %s answered %d", check.Method, check.Path, resp.StatusCode)
+	}
+	return lastErr
+}
+
+// dialAddr returns host:port of an instance URL, defaulting the port by scheme
+func dialAddr(instance string) (string, error) {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return "", fmt.Errorf("invalid instance URL %q: %w", instance, err)
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
@@ -0,0 +1,40 @@
+package gateway
+
+import (
+	"context"
+	"main/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeServiceHealthCheck(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && r.URL.Path == "/" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(backend.Close)
+
+	check := &config.HealthCheckConfig{Path: "/", Method: http.MethodHead, ExpectedStatus: []int{http.StatusNoContent}}
+	p := newTestProxy(t,
+		config.ServiceConfig{Name: "legacy", URL: backend.URL, HealthCheck: check},
+		config.ServiceConfig{Name: "orders", URL: backend.URL,
+			HealthCheck: &config.HealthCheckConfig{Path: "/health", Method: http.MethodGet}},
+		config.ServiceConfig{Name: "payments", URL: backend.URL},
+	)
+
+	if err := p.ProbeService(context.Background(), "legacy"); err != nil {
+		t.Errorf("legacy: %v, want HEAD / answering 204 to pass", err)
+	}
+	if err := p.ProbeService(context.Background(), "orders"); err == nil || !strings.Contains(err.Error(), "GET /health answered 404") {
+		t.Errorf("orders: %v, want the 404 reported", err)
+	}
+	// Without a health check, accepting connections is enough
+	if err := p.ProbeService(context.Background(), "payments"); err != nil {
+		t.Errorf("payments: %v, want a connection to pass", err)
+	}
+}
@@ -1,16 +1,17 @@
 package gateway
 
 import (
-	"context"
+	"crypto/tls"
 	"fmt"
-	"io"
 	"main/internal/config"
+	"main/internal/gateway/proxy"
+	"main/internal/metrics"
+	"net"
 	"net/http"
-	"net/url"
-	"strings"
+	"slices"
+	"sync"
 	"time"
 
-	"github.com/sony/gobreaker"
 	"go.uber.org/zap"
 )
 
@@ -18,36 +19,62 @@ type Proxy struct {
 	config          *config.Config
 	logger          *zap.Logger
 	client          *http.Client
-	circuitBreakers map[string]*gobreaker.CircuitBreaker
+	clients         map[string]*http.Client
+	circuitBreakers map[string]*circuitBreaker
+	breakerConfigs  map[string]config.CircuitBreakerConfig
 	services        map[string]*config.ServiceConfig
+	windows         map[string]*serviceWindow
+	limiters        map[string]*concurrencyLimiter
+	rewriters       map[string]*pathRewriter
+	queryFilters    map[string]*queryFilter
+	retryBudgets    map[string]*retryBudget
+	balancers       map[string]*balancer
+	mirrors         map[string]*mirror
+	canaries        map[string]*canary
+	transformers    map[string]BodyTransformer
+	// mirrorClient has its own transport so shadow traffic can't exhaust
+	// the connections of the primary path
+	mirrorClient *http.Client
+	mu           sync.RWMutex
 }
 
-type ProxyRequest struct {
-	OriginalRequest *http.Request
-	TargetURL       *url.URL
-	ServiceName     string
-}
-
-type ProxyResponse struct {
-	StatusCode int
-	Headers    http.Header
-	Body       []byte
-}
-
-func NewProxy(cfg *config.Config, log *zap.Logger) *Proxy {
+func NewProxy(cfg *config.Config, log *zap.Logger) (*Proxy, error) {
 	p := &Proxy{
 		config:          cfg,
 		logger:          log,
-		circuitBreakers: make(map[string]*gobreaker.CircuitBreaker),
+		clients:         make(map[string]*http.Client),
+		circuitBreakers: make(map[string]*circuitBreaker),
+		breakerConfigs:  make(map[string]config.CircuitBreakerConfig),
 		services:        make(map[string]*config.ServiceConfig),
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				MaxConnsPerHost:     10,
-			},
-		},
+		windows:         make(map[string]*serviceWindow),
+		limiters:        make(map[string]*concurrencyLimiter),
+		rewriters:       make(map[string]*pathRewriter),
+		queryFilters:    make(map[string]*queryFilter),
+		retryBudgets:    make(map[string]*retryBudget),
+		balancers:       make(map[string]*balancer),
+		mirrors:         make(map[string]*mirror),
+		canaries:        make(map[string]*canary),
+		transformers:    make(map[string]BodyTransformer),
+		mirrorClient:    &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()},
+	}
+
+	// One DNS cache for every upstream transport, mirrors included
+	var dns *proxy.DNSCache
+	if cfg.Upstream.DNSCache.Enabled {
+		dns = proxy.NewDNSCache(cfg.Upstream.DNSCache.TTL, net.DefaultResolver)
+		p.mirrorClient.Transport.(*http.Transport).DialContext = dns.DialContext
+	}
+
+	shared, err := proxy.NewTransport("", nil, cfg.Upstream.Pool)
+	if err != nil {
+		return nil, err
+	}
+	if dns != nil {
+		shared.DialContext = dns.DialContext
+	}
+	p.client = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: shared,
 	}
 
 	// Initialize circuit breakers and services map
@@ -55,140 +82,129 @@ func NewProxy(cfg *config.Config, log *zap.Logger) *Proxy {
 		svc := service // Copy for pointer
 		p.services[service.Name] = &svc
 
-		settings := gobreaker.Settings{
-			Name:        service.Name,
-			MaxRequests: 10,
-			Interval:    time.Second,
-			Timeout:     5 * time.Second,
-			ReadyToTrip: func(counts gobreaker.Counts) bool {
-				failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
-				return counts.Requests >= 3 && failureRatio >= 0.6
-			},
-		}
+		breakerCfg := cfg.Upstream.CircuitBreaker.Merge(service.CircuitBreaker)
+		p.breakerConfigs[service.Name] = breakerCfg
+		p.circuitBreakers[service.Name] = newCircuitBreaker(service.Name, breakerCfg, log)
+		p.windows[service.Name] = &serviceWindow{}
+		p.limiters[service.Name] = newConcurrencyLimiter(service.MaxConcurrent,
+			service.QueueTimeout, metrics.UpstreamQueued.WithLabelValues(service.Name))
 
-		p.circuitBreakers[service.Name] = gobreaker.NewCircuitBreaker(settings)
+		rewriter, err := newPathRewriter(service)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", service.Name, err)
+		}
+		p.rewriters[service.Name] = rewriter
+		p.queryFilters[service.Name] = newQueryFilter(service)
+		p.retryBudgets[service.Name] = newRetryBudget(cfg.Upstream.RetryBudgetRatio, cfg.Upstream.RetryBudgetMax)
+		p.balancers[service.Name] = newBalancer(service, cfg.Upstream.Outlier)
+		if p.mirrors[service.Name], err = newMirror(service.Mirror); err != nil {
+			return nil, fmt.Errorf("service %s: mirror: %w", service.Name, err)
+		}
+		p.canaries[service.Name] = newCanary(service.Canary)
+		p.SetTransformer(service.Name, newFieldRenamer(service.Transform))
+
+		// Services with TLS, protocol or pool settings get a dedicated client,
+		// the rest share p.client. So do services with a concurrency limit: as a
+		// bulkhead, their connections, capped at the limit, are kept apart so a
+		// slow service can't hold up the pool others use.
+		if service.TLS != nil || service.Protocol != "" || service.Pool != nil || service.MaxConcurrent > 0 {
+			pool := cfg.Upstream.Pool.Merge(service.Pool)
+			if service.MaxConcurrent > 0 && (pool.MaxConnsPerHost == 0 || pool.MaxConnsPerHost > service.MaxConcurrent) {
+				pool.MaxConnsPerHost = service.MaxConcurrent
+			}
+			client, err := newServiceClient(&svc, pool, dns)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %w", service.Name, err)
+			}
+			p.clients[service.Name] = client
+		}
+		if service.TLS != nil && service.TLS.InsecureSkipVerify {
+			p.logger.Warn("TLS certificate verification disabled for upstream service",
+				zap.String("service", service.Name),
+			)
+		}
 	}
 
 	p.logger.Info("Proxy initialized with services",
 		zap.Int("count", len(cfg.Upstream.Services)),
 	)
 
-	return p
-}
-
-// RouteRequest routes request to appropriate upstream service
-func (p *Proxy) RouteRequest(req *http.Request, serviceName string) (*ProxyResponse, error) {
-	service, exists := p.services[serviceName]
-	if !exists {
-		return nil, fmt.Errorf("service not found: %s", serviceName)
-	}
-
-	cb := p.circuitBreakers[serviceName]
-
-	// Execute with circuit breaker
-	result, err := cb.Execute(func() (interface{}, error) {
-		return p.executeRequest(req, service)
-	})
-
-	if err != nil {
-		p.logger.Error("Request execution failed",
-			zap.String("service", serviceName),
-			zap.Error(err),
-		)
-		return nil, err
-	}
-
-	return result.(*ProxyResponse), nil
+	return p, nil
 }
 
-func (p *Proxy) executeRequest(req *http.Request, service *config.ServiceConfig) (*ProxyResponse, error) {
-	// Build target URL
-	targetURL, err := url.Parse(service.URL)
-	if err != nil {
-		return nil, fmt.Errorf("invalid service URL: %w", err)
-	}
-
-	// Preserve path and query from original request
-	targetURL.Path = req.URL.Path
-	targetURL.RawQuery = req.URL.RawQuery
-
-	// Create new request
-	proxyReq, err := http.NewRequest(req.Method, targetURL.String(), req.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create proxy request: %w", err)
-	}
-
-	// Copy headers from original request
-	p.copyHeaders(req.Header, proxyReq.Header)
-
-	// Set request context and timeout
-	ctx, cancel := context.WithTimeout(req.Context(),
-		time.Duration(service.Timeout)*time.Second)
-	defer cancel()
-	proxyReq = proxyReq.WithContext(ctx)
-
-	// Execute request with retry logic
-	var resp *http.Response
-	for attempt := 0; attempt < service.MaxRetry; attempt++ {
-		resp, err = p.client.Do(proxyReq)
-		if err == nil {
-			break
+// Reload applies a new service list from the service registry. Only instance
+// membership changes at runtime: services not configured at startup are
+// ignored, and an empty instance list keeps the current instances so a
+// service is never left without a target.
+func (p *Proxy) Reload(services []config.ServiceConfig) {
+	for _, service := range services {
+		b, exists := p.balancers[service.Name]
+		if !exists {
+			p.logger.Warn("Ignoring discovered service that is not configured",
+				zap.String("service", service.Name),
+			)
+			continue
+		}
+		if len(service.Instances) == 0 {
+			p.logger.Warn("No instances discovered, keeping the current ones",
+				zap.String("service", service.Name),
+			)
+			continue
 		}
 
-		p.logger.Warn("Request attempt failed",
+		b.mu.Lock()
+		unchanged := slices.Equal(b.instances, service.Instances)
+		b.mu.Unlock()
+		if unchanged {
+			continue
+		}
+		b.setInstances(service.Instances)
+		p.logger.Info("Service instances updated",
 			zap.String("service", service.Name),
-			zap.Int("attempt", attempt+1),
-			zap.Error(err),
+			zap.Strings("instances", service.Instances),
 		)
+	}
+}
 
-		if attempt < service.MaxRetry-1 {
-			time.Sleep(time.Duration((attempt+1)*100) * time.Millisecond)
+// newServiceClient builds a dedicated HTTP client for a service, dialing
+// through dns when it isn't nil
+func newServiceClient(service *config.ServiceConfig, pool config.PoolConfig, dns *proxy.DNSCache) (*http.Client, error) {
+	var tlsConfig *tls.Config
+	if service.TLS != nil {
+		var err error
+		tlsConfig, err = proxy.LoadTLSConfig(service.TLS)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	transport, err := proxy.NewTransport(service.Protocol, tlsConfig, pool)
 	if err != nil {
-		return nil, fmt.Errorf("all retry attempts failed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+	if dns != nil {
+		transport.DialContext = dns.DialContext
 	}
 
-	p.logger.Debug("Request routed successfully",
-		zap.String("service", service.Name),
-		zap.Int("status_code", resp.StatusCode),
-		zap.Int("response_size", len(body)),
-	)
-
-	return &ProxyResponse{
-		StatusCode: resp.StatusCode,
-		Headers:    resp.Header,
-		Body:       body,
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
 	}, nil
 }
 
-func (p *Proxy) copyHeaders(src http.Header, dst http.Header) {
-	// Headers to skip
-	skipHeaders := map[string]bool{
-		"host":              true,
-		"connection":        true,
-		"content-length":    true,
-		"transfer-encoding": true,
-		"upgrade":           true,
+// clientFor returns the HTTP client to use for a service
+func (p *Proxy) clientFor(serviceName string) *http.Client {
+	if client, exists := p.clients[serviceName]; exists {
+		return client
 	}
+	return p.client
+}
 
-	for key, values := range src {
-		if skipHeaders[strings.ToLower(key)] {
-			continue
-		}
-
-		for _, value := range values {
-			dst.Add(key, value)
-		}
-	}
+// Transport returns the transport requests to a service go through, with its
+// TLS, protocol and pool settings; services without those or a concurrency
+// limit share one
+func (p *Proxy) Transport(serviceName string) http.RoundTripper {
+	return p.clientFor(serviceName).Transport
 }
 
 // GetServiceHealth returns health status of a service
@@ -210,3 +226,12 @@ func (p *Proxy) GetAllServiceStatus() map[string]string {
 	}
 	return status
 }
+
+// GetServiceURL returns the configured upstream URL of a service
+func (p *Proxy) GetServiceURL(serviceName string) string {
+	service, exists := p.services[serviceName]
+	if !exists {
+		return ""
+	}
+	return service.URL
+}
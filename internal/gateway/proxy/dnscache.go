@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsRefreshTimeout bounds a background refresh of a cached host
+const dnsRefreshTimeout = 5 * time.Second
+
+// Resolver looks up the addresses of a host; *net.Resolver implements it
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DNSCache dials upstream hosts through addresses it keeps for a TTL, so new
+// connections don't each wait on the resolver. Entries past half their TTL
+// are still served while they are refreshed in the background; a failed
+// refresh keeps the old addresses until they expire. Expired and unknown
+// hosts are looked up live, as are hosts whose cached addresses all refuse
+// connections.
+type DNSCache struct {
+	ttl      time.Duration
+	resolver Resolver
+	dialer   *net.Dialer
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	addrs      []string
+	resolvedAt time.Time
+	refreshing bool
+}
+
+// NewDNSCache returns a cache keeping the addresses resolver returns for ttl
+func NewDNSCache(ttl time.Duration, resolver Resolver) *DNSCache {
+	return &DNSCache{
+		ttl:      ttl,
+		resolver: resolver,
+		dialer:   &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		now:      time.Now,
+		entries:  make(map[string]*dnsEntry),
+	}
+}
+
+// DialContext connects to addr through the host's cached addresses, for use
+// as an http.Transport's DialContext. IP addresses are dialed directly.
+func (d *DNSCache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, cached, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.dialAny(ctx, network, addrs, port)
+	if err != nil && cached && ctx.Err() == nil {
+		// The host may have moved since it was cached
+		if addrs, err = d.resolve(ctx, host); err != nil {
+			return nil, err
+		}
+		conn, err = d.dialAny(ctx, network, addrs, port)
+	}
+	return conn, err
+}
+
+// lookup returns host's addresses and whether they came from the cache,
+// starting a background refresh once an entry is past half its TTL
+func (d *DNSCache) lookup(ctx context.Context, host string) ([]string, bool, error) {
+	d.mu.Lock()
+	entry, found := d.entries[host]
+	if found {
+		age := d.now().Sub(entry.resolvedAt)
+		if age < d.ttl {
+			if age >= d.ttl/2 && !entry.refreshing {
+				entry.refreshing = true
+				go d.refresh(host)
+			}
+			addrs := entry.addrs
+			d.mu.Unlock()
+			return addrs, true, nil
+		}
+	}
+	d.mu.Unlock()
+
+	addrs, err := d.resolve(ctx, host)
+	return addrs, false, err
+}
+
+// resolve looks host up live and caches the result
+func (d *DNSCache) resolve(ctx context.Context, host string) ([]string, error) {
+	addrs, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+	}
+
+	d.mu.Lock()
+	d.entries[host] = &dnsEntry{addrs: addrs, resolvedAt: d.now()}
+	d.mu.Unlock()
+	return addrs, nil
+}
+
+// refresh looks host up again in the background, keeping the current
+// addresses when the lookup fails
+func (d *DNSCache) refresh(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsRefreshTimeout)
+	defer cancel()
+	addrs, err := d.resolver.LookupHost(ctx, host)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, found := d.entries[host]
+	if !found {
+		return
+	}
+	entry.refreshing = false
+	if err == nil && len(addrs) > 0 {
+		entry.addrs, entry.resolvedAt = addrs, d.now()
+	}
+}
+
+// dialAny connects to the first of addrs that accepts, returning the last
+// error when none does
+func (d *DNSCache) dialAny(ctx context.Context, network string, addrs []string, port string) (net.Conn, error) {
+	err := errors.New("no addresses to dial")
+	for _, addr := range addrs {
+		var conn net.Conn
+		if conn, err = d.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port)); err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers every lookup with addrs, or err, counting lookups
+type fakeResolver struct {
+	mu      sync.Mutex
+	addrs   []string
+	err     error
+	lookups int
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	return r.addrs, r.err
+}
+
+func (r *fakeResolver) set(addrs []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs, r.err = addrs, err
+}
+
+func (r *fakeResolver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+// newDNSTest returns a cache with a settable clock and the port of a local
+// backend it can dial as backend.internal
+func newDNSTest(t *testing.T, resolver *fakeResolver) (*DNSCache, *time.Time, string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	now := time.Now()
+	cache := NewDNSCache(time.Minute, resolver)
+	cache.now = func() time.Time { return now }
+	return cache, &now, port
+}
+
+func dial(t *testing.T, cache *DNSCache, port string) error {
+	t.Helper()
+	conn, err := cache.DialContext(context.Background(), "tcp", net.JoinHostPort("backend.internal", port))
+	if err == nil {
+		conn.Close()
+	}
+	return err
+}
+
+func TestDNSCacheReusesAddresses(t *testing.T) {
+	resolver := &fakeResolver{addrs: []string{"127.0.0.1"}}
+	cache, now, port := newDNSTest(t, resolver)
+
+	for range 3 {
+		if err := dial(t, cache, port); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resolver.count() != 1 {
+		t.Fatalf("%d lookups for three dials, want 1", resolver.count())
+	}
+
+	// Expired entries are looked up again before dialing
+	*now = now.Add(time.Minute)
+	if err := dial(t, cache, port); err != nil {
+		t.Fatal(err)
+	}
+	if resolver.count() != 2 {
+		t.Errorf("%d lookups after the TTL, want 2", resolver.count())
+	}
+}
+
+func TestDNSCacheRefreshesInBackground(t *testing.T) {
+	resolver := &fakeResolver{addrs: []string{"127.0.0.1"}}
+	cache, now, port := newDNSTest(t, resolver)
+	if err := dial(t, cache, port); err != nil {
+		t.Fatal(err)
+	}
+
+	// Past half the TTL the cached addresses are served while a refresh runs
+	*now = now.Add(40 * time.Second)
+	resolver.set(nil, errors.New("resolver down"))
+	if err := dial(t, cache, port); err != nil {
+		t.Fatalf("dial during refresh: %v", err)
+	}
+	waitFor(t, func() bool {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		return !cache.entries["backend.internal"].refreshing
+	})
+	if resolver.count() != 2 {
+		t.Fatalf("%d lookups, want a background refresh", resolver.count())
+	}
+
+	// The failed refresh keeps the addresses until they expire
+	if err := dial(t, cache, port); err != nil {
+		t.Fatalf("dial after a failed refresh: %v", err)
+	}
+	*now = now.Add(30 * time.Second)
+	if err := dial(t, cache, port); err == nil {
+		t.Fatal("dial succeeded with an expired entry and the resolver down")
+	}
+}
+
+func TestDNSCacheLooksUpMovedHosts(t *testing.T) {
+	// A closed port on another loopback address stands in for a host that moved
+	resolver := &fakeResolver{addrs: []string{"127.0.0.2"}}
+	cache, _, port := newDNSTest(t, resolver)
+	if err := dial(t, cache, port); err == nil {
+		t.Skip("127.0.0.2 accepts connections on this host")
+	}
+
+	resolver.set([]string{"127.0.0.1"}, nil)
+	if err := dial(t, cache, port); err != nil {
+		t.Fatalf("dial after the host moved: %v", err)
+	}
+	if resolver.count() != 2 {
+		t.Errorf("%d lookups, want a live lookup after the cached address refused", resolver.count())
+	}
+}
+
+func TestDNSCacheDialsIPsDirectly(t *testing.T) {
+	resolver := &fakeResolver{err: errors.New("resolver down")}
+	cache, _, port := newDNSTest(t, resolver)
+	conn, err := cache.DialContext(context.Background(), "tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if resolver.count() != 0 {
+		t.Errorf("%d lookups for an IP address, want none", resolver.count())
+	}
+}
+
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
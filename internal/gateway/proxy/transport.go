@@ -1 +1,74 @@
 package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"main/internal/config"
+	"net/http"
+	"os"
+)
+
+// NewTransport builds an upstream transport speaking the given protocol:
+// "http1" (default), "h2" (HTTP/2 negotiated over TLS) or "h2c" (cleartext HTTP/2
+// with prior knowledge, for internal services).
+func NewTransport(protocol string, tlsConfig *tls.Config, pool config.PoolConfig) (*http.Transport, error) {
+	transport := &http.Transport{
+		MaxIdleConns:        pool.MaxIdleConns,
+		MaxIdleConnsPerHost: pool.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     pool.MaxConnsPerHost,
+		IdleConnTimeout:     pool.IdleConnTimeout,
+		TLSClientConfig:     tlsConfig,
+	}
+
+	protocols := new(http.Protocols)
+	switch protocol {
+	case "", "http1":
+		protocols.SetHTTP1(true)
+	case "h2":
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		transport.ForceAttemptHTTP2 = true
+	case "h2c":
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("unsupported upstream protocol %q", protocol)
+	}
+	transport.Protocols = protocols
+
+	return transport, nil
+}
+
+// LoadTLSConfig builds the client TLS configuration for an upstream service.
+// Certificate files are read eagerly so a bad path fails at startup.
+func LoadTLSConfig(cfg *config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	// Client certificate for mutual TLS
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %q: %w", cfg.CertFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// Custom CA bundle to verify the upstream
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle %q: %w", cfg.CAFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificates found in CA bundle %q", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"main/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// protoServer answers with the HTTP major version the request arrived over
+func protoServer(t *testing.T, protocols *http.Protocols) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto-Major", strconv.Itoa(r.ProtoMajor))
+	}))
+	server.Config.Protocols = protocols
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewTransportProtocols(t *testing.T) {
+	h2c := new(http.Protocols)
+	h2c.SetHTTP1(true)
+	h2c.SetUnencryptedHTTP2(true)
+	server := protoServer(t, h2c)
+
+	tests := []struct {
+		protocol string
+		want     string
+	}{
+		{"", "1"},
+		{"http1", "1"},
+		{"h2c", "2"},
+	}
+	for _, tt := range tests {
+		transport, err := NewTransport(tt.protocol, nil, config.PoolConfig{})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.protocol, err)
+		}
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.protocol, err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Proto-Major"); got != tt.want {
+			t.Errorf("%q: served over HTTP/%s, want HTTP/%s", tt.protocol, got, tt.want)
+		}
+	}
+}
+
+func TestNewTransportH2OverTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto-Major", strconv.Itoa(r.ProtoMajor))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	transport, err := NewTransport("h2", server.Client().Transport.(*http.Transport).TLSClientConfig, config.PoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Proto-Major"); got != "2" {
+		t.Fatalf("served over HTTP/%s, want HTTP/2", got)
+	}
+}
+
+func TestNewTransportRejectsUnknownProtocol(t *testing.T) {
+	if _, err := NewTransport("spdy", nil, config.PoolConfig{}); err == nil {
+		t.Fatal("expected an error for an unsupported protocol")
+	}
+}
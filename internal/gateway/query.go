@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"net/url"
+	"strings"
+)
+
+// queryFilter drops query parameters a service must not receive
+type queryFilter struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+func newQueryFilter(service config.ServiceConfig) *queryFilter {
+	if len(service.QueryAllow) == 0 && len(service.QueryDeny) == 0 {
+		return nil
+	}
+	return &queryFilter{allow: toSet(service.QueryAllow), deny: toSet(service.QueryDeny)}
+}
+
+// filter keeps the parameters passing the lists, in their original order and
+// encoding. Repeated keys are kept or dropped together.
+func (f *queryFilter) filter(rawQuery string) string {
+	if f == nil || rawQuery == "" {
+		return rawQuery
+	}
+
+	kept := make([]string, 0, strings.Count(rawQuery, "&")+1)
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(param, "=")
+		if decoded, err := url.QueryUnescape(key); err == nil {
+			key = decoded
+		}
+		if f.allows(key) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+func (f *queryFilter) allows(key string) bool {
+	if len(f.allow) > 0 && !f.allow[key] {
+		return false
+	}
+	return !f.deny[key]
+}
+
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// UpstreamQuery returns the query string to send to a service
+func (p *Proxy) UpstreamQuery(serviceName, rawQuery string) string {
+	p.mu.RLock()
+	filter := p.queryFilters[serviceName]
+	p.mu.RUnlock()
+	return filter.filter(rawQuery)
+}
@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"main/internal/metrics"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// retryBudget is a token bucket capping retries to a fraction of requests, so
+// retries can't multiply the load on a failing service. Every request deposits
+// ratio tokens, every retry spends one.
+type retryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+	ratio  float64
+	max    float64
+}
+
+// newRetryBudget starts full so a cold gateway can still retry. A zero ratio
+// disables the budget.
+func newRetryBudget(ratio float64, max int) *retryBudget {
+	if ratio <= 0 {
+		return nil
+	}
+	return &retryBudget{tokens: float64(max), ratio: ratio, max: float64(max)}
+}
+
+// deposit records an original request
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.tokens = min(b.tokens+b.ratio, b.max)
+	b.mu.Unlock()
+}
+
+// withdraw reports whether a retry may be made, spending a token if so
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RecordRequest deposits an original request to the service into its retry budget
+func (p *Proxy) RecordRequest(serviceName string) {
+	p.retryBudgets[serviceName].deposit()
+}
+
+// AllowRetry reports whether the service's retry budget allows another
+// attempt, spending from it if so. Exhaustion is logged and counted.
+func (p *Proxy) AllowRetry(serviceName string) bool {
+	if p.retryBudgets[serviceName].withdraw() {
+		return true
+	}
+	p.logger.Warn("Retry budget exhausted", zap.String("service", serviceName))
+	metrics.RetryBudgetExhausted.WithLabelValues(serviceName).Inc()
+	return false
+}
@@ -0,0 +1,44 @@
+package gateway
+
+import "testing"
+
+func TestRetryBudget(t *testing.T) {
+	b := newRetryBudget(0.5, 2)
+
+	// Starts full so a cold gateway can retry
+	if !b.withdraw() || !b.withdraw() {
+		t.Fatal("a fresh budget should allow max retries")
+	}
+	if b.withdraw() {
+		t.Fatal("an empty budget should refuse retries")
+	}
+
+	// Two requests at ratio 0.5 earn one retry
+	b.deposit()
+	if b.withdraw() {
+		t.Fatal("half a token should not allow a retry")
+	}
+	b.deposit()
+	b.deposit()
+	if !b.withdraw() {
+		t.Fatal("a whole token should allow a retry")
+	}
+
+	// Deposits never exceed max
+	for i := 0; i < 10; i++ {
+		b.deposit()
+	}
+	if b.tokens != 2 {
+		t.Fatalf("tokens = %v, want capped at 2", b.tokens)
+	}
+}
+
+func TestRetryBudgetDisabled(t *testing.T) {
+	b := newRetryBudget(0, 0)
+	b.deposit()
+	for i := 0; i < 5; i++ {
+		if !b.withdraw() {
+			t.Fatal("a zero ratio should not limit retries")
+		}
+	}
+}
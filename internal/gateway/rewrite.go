@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"fmt"
+	"main/internal/config"
+	"regexp"
+	"strings"
+)
+
+// pathRewriter maps a gateway path to the path a service expects
+type pathRewriter struct {
+	stripPrefix string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func newPathRewriter(service config.ServiceConfig) (*pathRewriter, error) {
+	r := &pathRewriter{stripPrefix: strings.TrimSuffix(service.StripPrefix, "/")}
+	if service.RewriteTarget != nil {
+		pattern, err := regexp.Compile(service.RewriteTarget.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite pattern: %w", err)
+		}
+		r.pattern = pattern
+		r.replacement = service.RewriteTarget.Replacement
+	}
+	return r, nil
+}
+
+// rewrite strips the prefix when the path lies under it and applies the rewrite.
+// Paths matching neither are returned unchanged.
+func (r *pathRewriter) rewrite(path string) string {
+	if r.stripPrefix != "" && (path == r.stripPrefix || strings.HasPrefix(path, r.stripPrefix+"/")) {
+		path = strings.TrimPrefix(path, r.stripPrefix)
+		if path == "" {
+			path = "/"
+		}
+	}
+	if r.pattern != nil {
+		path = r.pattern.ReplaceAllString(path, r.replacement)
+	}
+	return path
+}
+
+// UpstreamPath returns the path to request from a service for a gateway path
+func (p *Proxy) UpstreamPath(serviceName, path string) string {
+	p.mu.RLock()
+	rewriter, exists := p.rewriters[serviceName]
+	p.mu.RUnlock()
+	if !exists {
+		return path
+	}
+	return rewriter.rewrite(path)
+}
@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"testing"
+)
+
+func TestPathRewriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		service config.ServiceConfig
+		path    string
+		want    string
+	}{
+		{"no rules", config.ServiceConfig{}, "/api/users/1", "/api/users/1"},
+		{"strip prefix", config.ServiceConfig{StripPrefix: "/api/users"}, "/api/users/1", "/1"},
+		{"strip prefix with trailing slash", config.ServiceConfig{StripPrefix: "/api/users/"}, "/api/users/1", "/1"},
+		{"strip whole path", config.ServiceConfig{StripPrefix: "/api/users"}, "/api/users", "/"},
+		// Only whole segments are stripped
+		{"partial segment kept", config.ServiceConfig{StripPrefix: "/api/users"}, "/api/usersettings", "/api/usersettings"},
+		{"rewrite", config.ServiceConfig{RewriteTarget: &config.RewriteConfig{
+			Pattern: `^/v1/(?P<rest>.*)$`, Replacement: "/internal/${rest}",
+		}}, "/v1/orders/7", "/internal/orders/7"},
+		{"rewrite after strip", config.ServiceConfig{StripPrefix: "/api", RewriteTarget: &config.RewriteConfig{
+			Pattern: `^/orders/(\d+)$`, Replacement: "/order?id=$1",
+		}}, "/api/orders/7", "/order?id=7"},
+		{"rewrite not matching", config.ServiceConfig{RewriteTarget: &config.RewriteConfig{
+			Pattern: `^/v1/`, Replacement: "/",
+		}}, "/v2/orders", "/v2/orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newPathRewriter(tt.service)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.rewrite(tt.path); got != tt.want {
+				t.Fatalf("rewrite(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathRewriterRejectsInvalidPattern(t *testing.T) {
+	_, err := newPathRewriter(config.ServiceConfig{RewriteTarget: &config.RewriteConfig{Pattern: "(unclosed"}})
+	if err == nil {
+		t.Fatal("expected an error for an invalid rewrite pattern")
+	}
+}
+
+func TestUpstreamPath(t *testing.T) {
+	p := newTestProxy(t, config.ServiceConfig{Name: "users", URL: "http://users", StripPrefix: "/api/users"})
+	if got := p.UpstreamPath("users", "/api/users/1"); got != "/1" {
+		t.Fatalf("UpstreamPath = %q, want /1", got)
+	}
+	if got := p.UpstreamPath("unknown", "/api/users/1"); got != "/api/users/1" {
+		t.Fatalf("unknown service path = %q, want it unchanged", got)
+	}
+}
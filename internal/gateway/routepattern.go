@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"strings"
+)
+
+// RoutePattern returns the pattern labelling a request for path to service in
+// metrics: the first of its MetricRoutes that matches, else its StripPrefix
+// followed by /*. It reports false when neither applies.
+func RoutePattern(service config.ServiceConfig, path string) (string, bool) {
+	for _, pattern := range service.MetricRoutes {
+		if matchRoutePattern(pattern, path) {
+			return pattern, true
+		}
+	}
+
+	prefix := strings.TrimSuffix(service.StripPrefix, "/")
+	if prefix != "" && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+		return prefix + "/*", true
+	}
+	return "", false
+}
+
+// matchRoutePattern matches path against pattern segment by segment. A
+// ":name" segment matches any one segment and a trailing "*" the rest.
+func matchRoutePattern(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(pathSegments) == len(patternSegments)
+}
@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"testing"
+)
+
+func TestRoutePattern(t *testing.T) {
+	service := config.ServiceConfig{
+		StripPrefix:  "/api/payments/",
+		MetricRoutes: []string{"/api/payments/:id/refund", "/api/payments/reports/*"},
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		matched bool
+	}{
+		{"/api/payments/42/refund", "/api/payments/:id/refund", true},
+		{"/api/payments/reports/2026/10", "/api/payments/reports/*", true},
+		// Falls back to the stripped prefix
+		{"/api/payments/42", "/api/payments/*", true},
+		{"/api/payments", "/api/payments/*", true},
+		{"/api/payments//refund", "/api/payments/*", true},
+		{"/api/paymentsx/42", "", false},
+		{"/api/orders/42", "", false},
+	}
+	for _, tt := range tests {
+		got, matched := RoutePattern(service, tt.path)
+		if got != tt.want || matched != tt.matched {
+			t.Errorf("RoutePattern(%q) = %q, %v; want %q, %v", tt.path, got, matched, tt.want, tt.matched)
+		}
+	}
+}
+
+func TestMatchRoutePattern(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/users/:id", "/users/7", true},
+		{"/users/:id", "/users/7/orders", false},
+		{"/users/:id", "/users", false},
+		{"/users/*", "/users/7/orders", true},
+		{"/users/*", "/users", true},
+		{"/users/:id/orders", "/users/7/orders/", true},
+		{"/users/:id/orders", "/admins/7/orders", false},
+	}
+	for _, tt := range tests {
+		if got := matchRoutePattern(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchRoutePattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"main/internal/models"
+	"sort"
+)
+
+// Routes returns the live routing table: every configured service with its
+// targets, timeouts, retry and circuit breaker settings, sorted by name
+func (p *Proxy) Routes() []models.RouteInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	routes := make([]models.RouteInfo, 0, len(p.services))
+	for name, service := range p.services {
+		route := RouteInfo(*service)
+		if cb, exists := p.circuitBreakers[name]; exists {
+			breaker := p.breakerConfigs[name]
+			route.CircuitBreaker = &models.CircuitBreakerInfo{
+				State:           cb.State().String(),
+				Override:        cb.Override(),
+				MaxRequests:     uint32(breaker.MaxRequests),
+				IntervalSeconds: breaker.Interval.Seconds(),
+				TimeoutSeconds:  breaker.Timeout.Seconds(),
+				MinRequests:     uint32(breaker.MinRequests),
+				FailureRatio:    breaker.FailureRatio,
+			}
+		}
+		routes = append(routes, route)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Service < routes[j].Service
+	})
+	return routes
+}
+
+// RouteInfo describes a service's forwarding settings, without breaker state
+func RouteInfo(service config.ServiceConfig) models.RouteInfo {
+	protocol := service.Protocol
+	if protocol == "" {
+		protocol = "http1"
+	}
+	route := models.RouteInfo{
+		Service:        service.Name,
+		Type:           service.Type,
+		GRPCServices:   service.GRPCServices,
+		Targets:        service.Instances,
+		TimeoutSeconds: service.Timeout.Seconds(),
+		MaxRetry:       service.MaxRetry,
+		Protocol:       protocol,
+		MaxConcurrent:  service.MaxConcurrent,
+		QueueTimeoutMs: service.QueueTimeout.Milliseconds(),
+		StripPrefix:    service.StripPrefix,
+		QueryAllow:     service.QueryAllow,
+		QueryDeny:      service.QueryDeny,
+		AllowedMethods: service.AllowedMethods,
+		Affinity:       service.Affinity,
+	}
+	if len(route.Targets) == 0 {
+		route.Targets = []string{service.URL}
+	}
+	if service.RewriteTarget != nil {
+		route.RewritePattern = service.RewriteTarget.Pattern
+		route.RewriteReplacement = service.RewriteTarget.Replacement
+	}
+	return route
+}
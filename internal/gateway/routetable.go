@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"cmp"
+	"main/internal/config"
+	"slices"
+	"strings"
+)
+
+// RouteTable matches requests against the configured routes, trying longer
+// paths first so the most specific route wins
+type RouteTable struct {
+	routes []config.RouteConfig
+}
+
+// NewRouteTable compiles routes into a table
+func NewRouteTable(routes []config.RouteConfig) *RouteTable {
+	sorted := slices.Clone(routes)
+	slices.SortStableFunc(sorted, func(a, b config.RouteConfig) int {
+		return cmp.Compare(len(b.Path), len(a.Path))
+	})
+	return &RouteTable{routes: sorted}
+}
+
+// Routes returns the table's routes in the order they are tried
+func (t *RouteTable) Routes() []*config.RouteConfig {
+	routes := make([]*config.RouteConfig, len(t.routes))
+	for i := range t.routes {
+		routes[i] = &t.routes[i]
+	}
+	return routes
+}
+
+// Match returns the route for a request. When routes cover the path but none
+// takes the method, it returns nil and the methods they take instead.
+func (t *RouteTable) Match(method, path string) (*config.RouteConfig, []string) {
+	var allowed []string
+	for i := range t.routes {
+		route := &t.routes[i]
+		if !matchRoutePath(*route, path) {
+			continue
+		}
+		if len(route.Methods) == 0 || slices.Contains(route.Methods, method) {
+			return route, nil
+		}
+		for _, m := range route.Methods {
+			if !slices.Contains(allowed, m) {
+				allowed = append(allowed, m)
+			}
+		}
+	}
+	return nil, allowed
+}
+
+// matchRoutePath matches path against the route's pattern, or its prefix
+// on a segment boundary
+func matchRoutePath(route config.RouteConfig, path string) bool {
+	if route.IsPattern() {
+		return matchRoutePattern(route.Path, path)
+	}
+	prefix := strings.TrimSuffix(route.Path, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
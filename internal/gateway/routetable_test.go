@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"slices"
+	"testing"
+)
+
+func TestRouteTableMatch(t *testing.T) {
+	table := NewRouteTable([]config.RouteConfig{
+		{Path: "/", Service: "default"},
+		{Path: "/api/orders", Service: "orders"},
+		{Path: "/api/orders/:id/refund", Methods: []string{"POST"}, Service: "payments"},
+		{Path: "/api/files/*", Methods: []string{"GET", "HEAD"}, Service: "files"},
+		{Path: "/api/files/*", Methods: []string{"PUT"}, Service: "uploads"},
+	})
+
+	tests := []struct {
+		method, path string
+		service      string
+		allowed      []string
+	}{
+		{"GET", "/api/orders", "orders", nil},
+		{"GET", "/api/orders/7", "orders", nil},
+		// The longer pattern wins over the prefix
+		{"POST", "/api/orders/7/refund", "payments", nil},
+		// and falls back to it for other methods
+		{"GET", "/api/orders/7/refund", "orders", nil},
+		// Prefixes match on segment boundaries
+		{"GET", "/api/ordersummary", "default", nil},
+		{"PUT", "/api/files/a/b", "uploads", nil},
+		{"HEAD", "/api/files/a", "files", nil},
+	}
+	for _, tt := range tests {
+		route, allowed := table.Match(tt.method, tt.path)
+		if route == nil || route.Service != tt.service || !slices.Equal(allowed, tt.allowed) {
+			t.Errorf("%s %s = %v %v, want %s", tt.method, tt.path, route, allowed, tt.service)
+		}
+	}
+
+	// Without a catch-all, a covered path with another method is refused
+	table = NewRouteTable([]config.RouteConfig{
+		{Path: "/api/files/*", Methods: []string{"GET", "HEAD"}, Service: "files"},
+		{Path: "/api/files/*", Methods: []string{"PUT"}, Service: "uploads"},
+	})
+	if route, allowed := table.Match("DELETE", "/api/files/a"); route != nil || !slices.Equal(allowed, []string{"GET", "HEAD", "PUT"}) {
+		t.Errorf("DELETE = %v %v, want the allowed methods", route, allowed)
+	}
+	if route, allowed := table.Match("GET", "/api/other"); route != nil || allowed != nil {
+		t.Errorf("unrouted path = %v %v", route, allowed)
+	}
+}
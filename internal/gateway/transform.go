@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"main/internal/config"
+	"mime"
+	"strings"
+)
+
+// BodyTransformer rewrites a service's request bodies on their way to the
+// backend and its response bodies on their way back. Only JSON bodies are
+// passed to it; an error fails the request with 502.
+type BodyTransformer interface {
+	TransformRequest(body []byte) ([]byte, error)
+	TransformResponse(body []byte) ([]byte, error)
+}
+
+// Transformer returns the body transformer of a service, or nil when its
+// bodies pass through untouched
+func (p *Proxy) Transformer(serviceName string) BodyTransformer {
+	return p.transformers[serviceName]
+}
+
+// SetTransformer replaces the body transformer of a service, or removes it
+// when t is nil. Set it before the proxy serves requests.
+func (p *Proxy) SetTransformer(serviceName string, t BodyTransformer) {
+	if t == nil {
+		delete(p.transformers, serviceName)
+		return
+	}
+	p.transformers[serviceName] = t
+}
+
+// IsJSON reports whether a Content-Type names JSON, including +json types
+// such as application/problem+json
+func IsJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// fieldRenamer is the BodyTransformer of a service's TransformConfig
+type fieldRenamer struct {
+	request  map[string]string
+	response map[string]string
+}
+
+func newFieldRenamer(cfg *config.TransformConfig) BodyTransformer {
+	if cfg == nil {
+		return nil
+	}
+	return &fieldRenamer{request: cfg.RequestRenames, response: cfg.ResponseRenames}
+}
+
+func (r *fieldRenamer) TransformRequest(body []byte) ([]byte, error) {
+	return renameFields(body, r.request)
+}
+
+func (r *fieldRenamer) TransformResponse(body []byte) ([]byte, error) {
+	return renameFields(body, r.response)
+}
+
+// renameFields renames the object fields of a JSON document at any depth.
+// A renamed field replaces one already present under the new name.
+func renameFields(body []byte, renames map[string]string) ([]byte, error) {
+	if len(renames) == 0 || len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(rename(doc, renames)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+func rename(value any, renames map[string]string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		renamed := make(map[string]any, len(v))
+		for key, field := range v {
+			if _, moved := renames[key]; !moved {
+				renamed[key] = rename(field, renames)
+			}
+		}
+		for key, field := range v {
+			if to, moved := renames[key]; moved {
+				renamed[to] = rename(field, renames)
+			}
+		}
+		return renamed
+	case []any:
+		for i, item := range v {
+			v[i] = rename(item, renames)
+		}
+	}
+	return value
+}
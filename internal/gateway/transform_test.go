@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"main/internal/config"
+	"testing"
+)
+
+func TestFieldRenamer(t *testing.T) {
+	renamer := newFieldRenamer(&config.TransformConfig{
+		RequestRenames: map[string]string{"userName": "user_name", "total": "amount"},
+	})
+
+	tests := []struct {
+		name, body, want string
+		wantErr          bool
+	}{
+		{"nested objects and arrays", `{"userName":"ann","lines":[{"userName":"bob"}],"meta":{"userName":null}}`,
+			`{"lines":[{"user_name":"bob"}],"meta":{"user_name":null},"user_name":"ann"}`, false},
+		{"numbers kept exact", `{"total":12345678901234567890.10}`, `{"amount":12345678901234567890.10}`, false},
+		{"renamed field wins", `{"total":1,"amount":2}`, `{"amount":1}`, false},
+		{"values are not renamed", `["userName"]`, `["userName"]`, false},
+		{"HTML left unescaped", `{"userName":"<b>&"}`, `{"user_name":"<b>&"}`, false},
+		{"empty body", ``, ``, false},
+		{"invalid JSON", `{"userName":`, ``, true},
+	}
+	for _, tt := range tests {
+		got, err := renamer.TransformRequest([]byte(tt.body))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && string(got) != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Without response renames responses pass through as sent
+	if got, _ := renamer.TransformResponse([]byte(`{"user_name" : 1}`)); string(got) != `{"user_name" : 1}` {
+		t.Errorf("TransformResponse = %s, want the body unchanged", got)
+	}
+}
+
+func TestIsJSON(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"application/problem+json":        true,
+		"text/plain":                      false,
+		"":                                false,
+	} {
+		if got := IsJSON(contentType); got != want {
+			t.Errorf("IsJSON(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
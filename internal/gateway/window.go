@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"main/internal/models"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// Outcomes are kept for the last windowSeconds in one-second buckets, each
+// holding at most windowSamples latencies for the quantiles
+const (
+	windowSeconds = 60
+	windowSamples = 128
+)
+
+type windowBucket struct {
+	second    int64
+	success   int64
+	failure   int64
+	latencies []time.Duration
+}
+
+// serviceWindow tracks a service's recent outcomes and its last error
+type serviceWindow struct {
+	mu      sync.Mutex
+	buckets [windowSeconds]windowBucket
+
+	lastFailed  bool
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (w *serviceWindow) record(now time.Time, d time.Duration, failure error) {
+	second := now.Unix()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	bucket := &w.buckets[second%windowSeconds]
+	if bucket.second != second {
+		*bucket = windowBucket{second: second, latencies: bucket.latencies[:0]}
+	}
+	if failure != nil {
+		bucket.failure++
+		w.lastError = failure.Error()
+		w.lastErrorAt = now
+	} else {
+		bucket.success++
+	}
+	if len(bucket.latencies) < windowSamples {
+		bucket.latencies = append(bucket.latencies, d)
+	}
+	w.lastFailed = failure != nil
+}
+
+// fill sets the window's numbers on info
+func (w *serviceWindow) fill(now time.Time, info *models.ServiceInfo) {
+	oldest := now.Unix() - windowSeconds + 1
+
+	w.mu.Lock()
+	var success, failure int64
+	var latencies []time.Duration
+	for i := range w.buckets {
+		bucket := &w.buckets[i]
+		if bucket.second < oldest {
+			continue
+		}
+		success += bucket.success
+		failure += bucket.failure
+		latencies = append(latencies, bucket.latencies...)
+	}
+	info.LastError = w.lastError
+	info.LastErrorAt = w.lastErrorAt
+	lastFailed := w.lastFailed
+	w.mu.Unlock()
+
+	info.Requests = success + failure
+	info.SuccessRate = 1
+	if info.Requests > 0 {
+		info.SuccessRate = float64(success) / float64(info.Requests)
+	}
+	if lastFailed {
+		info.Healthy = false
+	}
+
+	slices.Sort(latencies)
+	info.LatencyP50Ms = latencyQuantile(latencies, 0.5)
+	info.LatencyP95Ms = latencyQuantile(latencies, 0.95)
+	info.LatencyP99Ms = latencyQuantile(latencies, 0.99)
+}
+
+// latencyQuantile picks the nearest-rank quantile of sorted latencies, in ms
+func latencyQuantile(sorted []time.Duration, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return float64(sorted[max(rank, 0)]) / float64(time.Millisecond)
+}
+
+// Observe records the outcome of a request forwarded to a service. failure is
+// nil on success; transport errors and 5xx responses are failures.
+func (p *Proxy) Observe(serviceName string, d time.Duration, failure error) {
+	if w, exists := p.windows[serviceName]; exists {
+		w.record(time.Now(), d, failure)
+	}
+}
+
+// Dependencies reports every service's breaker state, instances and outcomes
+// over the last minute, sorted by name
+func (p *Proxy) Dependencies() []models.ServiceInfo {
+	now := time.Now()
+	services := make([]models.ServiceInfo, 0, len(p.circuitBreakers))
+	for name, cb := range p.circuitBreakers {
+		state := cb.State()
+		info := models.ServiceInfo{
+			Name:      name,
+			Status:    state.String(),
+			URL:       p.GetServiceURL(name),
+			InFlight:  p.InFlight(name),
+			Queued:    p.Queued(name),
+			Instances: 1,
+		}
+		info.HealthyInstances = 1
+		if b, exists := p.balancers[name]; exists {
+			info.Instances = b.size()
+			info.HealthyInstances = len(b.available())
+		}
+		info.Healthy = state == gobreaker.StateClosed && info.HealthyInstances > 0
+		if w, exists := p.windows[name]; exists {
+			w.fill(now, &info)
+		}
+		services = append(services, info)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
@@ -0,0 +1,31 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DatabaseProbe pings PostgreSQL at dsn on a connection of its own, so probes
+// don't compete with the audit writer for its pool. It connects lazily: an
+// unreachable database fails the probe, not the gateway's startup.
+func DatabaseProbe(dsn string) func(ctx context.Context) error {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		err = fmt.Errorf("invalid database config: %w", err)
+		return func(ctx context.Context) error {
+			return err
+		}
+	}
+	cfg.MaxConns = 1
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		return func(ctx context.Context) error {
+			return err
+		}
+	}
+	return func(ctx context.Context) error {
+		return pool.Ping(ctx)
+	}
+}
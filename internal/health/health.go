@@ -0,0 +1,248 @@
+// Package health decides whether the gateway is ready for traffic. Dependencies
+// are probed in the background so readiness checks answer from cached results.
+package health
+
+import (
+	"context"
+	"fmt"
+	"main/internal/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dependency statuses reported in models.DependencyStatus
+const (
+	StatusUp      = "up"
+	StatusDown    = "down"
+	StatusPending = "pending"
+)
+
+// Check is a dependency probed for readiness. Critical dependencies that are
+// down, or not probed yet, make the gateway unready.
+//
+// The first probe settles a pending dependency either way. After that it
+// turns down after UnhealthyThreshold consecutive failed probes and up after
+// HealthyThreshold consecutive passes. Zero Interval, Timeout and thresholds
+// take the checker's interval and timeout, and a threshold of 1.
+type Check struct {
+	Name     string
+	Critical bool
+	Probe    func(ctx context.Context) error
+
+	Interval           time.Duration
+	Timeout            time.Duration
+	HealthyThreshold   int
+	UnhealthyThreshold int
+}
+
+// Checker probes each check every interval and keeps the latest results
+type Checker struct {
+	checks []Check
+
+	mu      sync.RWMutex
+	results map[string]models.DependencyStatus
+	streaks map[string]int // consecutive probes disagreeing with the status
+}
+
+func NewChecker(checks []Check, interval, timeout time.Duration) *Checker {
+	c := &Checker{
+		checks:  make([]Check, len(checks)),
+		results: make(map[string]models.DependencyStatus, len(checks)),
+		streaks: make(map[string]int, len(checks)),
+	}
+	for i, check := range checks {
+		if check.Interval == 0 {
+			check.Interval = interval
+		}
+		if check.Timeout == 0 {
+			check.Timeout = timeout
+		}
+		check.HealthyThreshold = max(check.HealthyThreshold, 1)
+		check.UnhealthyThreshold = max(check.UnhealthyThreshold, 1)
+		c.checks[i] = check
+		c.results[check.Name] = models.DependencyStatus{Status: StatusPending, Critical: check.Critical}
+	}
+	return c
+}
+
+// Start probes every dependency now and then at its interval until ctx is
+// done. Each runs on its own so one slow dependency doesn't delay the others.
+func (c *Checker) Start(ctx context.Context) {
+	for _, check := range c.checks {
+		go func() {
+			ticker := time.NewTicker(check.Interval)
+			defer ticker.Stop()
+			for {
+				c.probe(ctx, check)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// WaitReady probes the critical dependencies not yet up, each under its own
+// timeout, until all are. After a failed round it calls waiting with those
+// still down and waits backoff, doubling it up to maxBackoff. It reports the
+// dependencies still down when ctx ends first.
+func (c *Checker) WaitReady(ctx context.Context, backoff, maxBackoff time.Duration, waiting func(down []string, retryIn time.Duration)) error {
+	for {
+		var wg sync.WaitGroup
+		for _, check := range c.checks {
+			if check.Critical && c.status(check.Name) != StatusUp {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c.probe(ctx, check)
+				}()
+			}
+		}
+		wg.Wait()
+
+		var down []string
+		for _, check := range c.checks {
+			if check.Critical && c.status(check.Name) != StatusUp {
+				down = append(down, check.Name)
+			}
+		}
+		if len(down) == 0 {
+			return nil
+		}
+		if ctx.Err() == nil {
+			waiting(down, backoff)
+		}
+		if !sleepContext(ctx, backoff) {
+			return fmt.Errorf("%s still down: %w", strings.Join(down, ", "), ctx.Err())
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *Checker) status(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.results[name].Status
+}
+
+// sleepContext waits for d, reporting false when ctx ends first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *Checker) probe(ctx context.Context, check Check) {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	result := models.DependencyStatus{
+		Status:    StatusUp,
+		Critical:  check.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: time.Now().UTC(),
+	}
+	threshold := check.HealthyThreshold
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+		threshold = check.UnhealthyThreshold
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.results[check.Name].Status
+	if previous == StatusPending || previous == result.Status {
+		c.streaks[check.Name] = 0
+	} else if c.streaks[check.Name]++; c.streaks[check.Name] < threshold {
+		// Not enough in a row to flip: report this probe under the old status
+		result.Status = previous
+	} else {
+		c.streaks[check.Name] = 0
+	}
+	c.results[check.Name] = result
+}
+
+// Ready reports whether every critical dependency was up at its last probe,
+// with the status of each dependency
+func (c *Checker) Ready() (models.HealthCheckResponse, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ready := true
+	dependencies := make(map[string]models.DependencyStatus, len(c.results))
+	for name, result := range c.results {
+		dependencies[name] = result
+		if result.Critical && result.Status != StatusUp {
+			ready = false
+		}
+	}
+
+	response := models.HealthCheckResponse{
+		Status:       "ready",
+		Timestamp:    time.Now().UTC(),
+		Dependencies: dependencies,
+	}
+	if !ready {
+		response.Status = "not_ready"
+	}
+	return response, ready
+}
+
+// Healthy reports like Ready, except that the checks named in anyOf count as
+// one dependency, group, which is up while any of them is and critical if
+// any of them is. Their own results are listed under Services.
+func (c *Checker) Healthy(group string, anyOf []string) (models.HealthCheckResponse, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	healthy := true
+	dependencies := make(map[string]models.DependencyStatus, len(c.results))
+	services := make(map[string]interface{}, len(anyOf))
+	aggregate := models.DependencyStatus{Status: StatusPending}
+	for name, result := range c.results {
+		if !slices.Contains(anyOf, name) {
+			dependencies[name] = result
+			healthy = healthy && (!result.Critical || result.Status == StatusUp)
+			continue
+		}
+		services[name] = result
+		aggregate.Critical = aggregate.Critical || result.Critical
+		if result.CheckedAt.After(aggregate.CheckedAt) {
+			aggregate.CheckedAt = result.CheckedAt
+		}
+		// Up wins over down, and down over pending
+		if aggregate.Status != StatusUp && result.Status != StatusPending {
+			aggregate.Status = result.Status
+		}
+	}
+	if len(services) > 0 {
+		if aggregate.Status != StatusUp {
+			aggregate.Error = fmt.Sprintf("none of %d up", len(services))
+			healthy = healthy && !aggregate.Critical
+		}
+		dependencies[group] = aggregate
+	}
+
+	response := models.HealthCheckResponse{
+		Status:       "ok",
+		Timestamp:    time.Now().UTC(),
+		Services:     services,
+		Dependencies: dependencies,
+	}
+	if !healthy {
+		response.Status = "unhealthy"
+	}
+	return response, healthy
+}
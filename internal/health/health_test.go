@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckerThresholds(t *testing.T) {
+	var failing bool
+	check := Check{
+		Name:     "orders",
+		Critical: true,
+		Probe: func(context.Context) error {
+			if failing {
+				return errors.New("503")
+			}
+			return nil
+		},
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+	}
+	c := NewChecker([]Check{check}, time.Second, time.Second)
+	check = c.checks[0]
+
+	// steps are whether each probe fails and the status reported after it
+	steps := []struct {
+		fail bool
+		want string
+	}{
+		{false, StatusUp}, // the first probe settles a pending dependency
+		{true, StatusUp},
+		{true, StatusUp},
+		{false, StatusUp}, // a pass breaks the failure streak
+		{true, StatusUp},
+		{true, StatusUp},
+		{true, StatusDown},
+		{false, StatusDown},
+		{false, StatusUp},
+	}
+	for i, step := range steps {
+		failing = step.fail
+		c.probe(context.Background(), check)
+		response, ready := c.Ready()
+		if got := response.Dependencies["orders"].Status; got != step.want || ready != (step.want == StatusUp) {
+			t.Fatalf("after probe %d: %s (ready %v), want %s", i, got, ready, step.want)
+		}
+	}
+}
+
+func TestCheckerHealthy(t *testing.T) {
+	down := map[string]bool{}
+	var checks []Check
+	for _, name := range []string{"orders", "payments", "redis"} {
+		checks = append(checks, Check{Name: name, Critical: true, Probe: func(context.Context) error {
+			if down[name] {
+				return errors.New("refused")
+			}
+			return nil
+		}})
+	}
+	c := NewChecker(checks, time.Second, time.Second)
+	upstreams := []string{"orders", "payments"}
+
+	if response, healthy := c.Healthy("upstreams", upstreams); healthy || response.Dependencies["upstreams"].Status != StatusPending {
+		t.Fatalf("before any probe: healthy %v, upstreams %+v, want unhealthy and pending", healthy, response.Dependencies["upstreams"])
+	}
+
+	tests := []struct {
+		down    []string
+		healthy bool
+	}{
+		{nil, true},
+		// One upstream is enough, though /readyz needs both
+		{[]string{"orders"}, true},
+		{[]string{"orders", "payments"}, false},
+		{[]string{"redis"}, false},
+	}
+	for _, tt := range tests {
+		clear(down)
+		for _, name := range tt.down {
+			down[name] = true
+		}
+		for _, check := range c.checks {
+			c.probe(context.Background(), check)
+		}
+		response, healthy := c.Healthy("upstreams", upstreams)
+		if healthy != tt.healthy || (response.Status == "ok") != tt.healthy {
+			t.Errorf("%v down: healthy %v, status %s, want healthy %v", tt.down, healthy, response.Status, tt.healthy)
+		}
+		if len(response.Services) != 2 || response.Dependencies["orders"].Status != "" {
+			t.Errorf("%v down: upstreams listed as %v and %v, want under services only", tt.down, response.Services, response.Dependencies)
+		}
+	}
+}
+
+func TestCheckerProbeTimeout(t *testing.T) {
+	// A dependency that never answers is down after its own timeout
+	c := NewChecker([]Check{{
+		Name:     "slow",
+		Critical: true,
+		Probe: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Timeout: 10 * time.Millisecond,
+	}}, time.Second, time.Minute)
+
+	start := time.Now()
+	c.probe(context.Background(), c.checks[0])
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("probe took %v, want about its 10ms timeout", elapsed)
+	}
+	if response, _ := c.Ready(); response.Dependencies["slow"].Status != StatusDown {
+		t.Errorf("slow = %+v, want down", response.Dependencies["slow"])
+	}
+}
+
+func TestCheckerWaitReady(t *testing.T) {
+	var calls atomic.Int32
+	c := NewChecker([]Check{
+		// Up on its third probe
+		{Name: "redis", Critical: true, Probe: func(context.Context) error {
+			if calls.Add(1) < 3 {
+				return errors.New("refused")
+			}
+			return nil
+		}},
+		// Never waited on
+		{Name: "reports", Probe: func(context.Context) error { return errors.New("refused") }},
+	}, time.Second, time.Second)
+
+	var waits []time.Duration
+	err := c.WaitReady(context.Background(), time.Millisecond, 3*time.Millisecond, func(down []string, retryIn time.Duration) {
+		if !slices.Equal(down, []string{"redis"}) {
+			t.Errorf("waiting on %v, want redis", down)
+		}
+		waits = append(waits, retryIn)
+	})
+	if err != nil {
+		t.Fatalf("WaitReady: %v", err)
+	}
+	if want := []time.Duration{time.Millisecond, 2 * time.Millisecond}; !slices.Equal(waits, want) {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+	// The results are kept for readiness
+	if response, ready := c.Ready(); !ready || response.Dependencies["redis"].Status != StatusUp {
+		t.Errorf("after waiting: %+v, ready %v", response.Dependencies, ready)
+	}
+
+	// A dependency that stays down is reported when the wait runs out
+	c = NewChecker([]Check{{Name: "database", Critical: true, Probe: func(context.Context) error {
+		return errors.New("refused")
+	}}}, time.Second, time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = c.WaitReady(ctx, time.Millisecond, 5*time.Millisecond, func([]string, time.Duration) {})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "database still down") {
+		t.Errorf("WaitReady = %v, want database still down past the deadline", err)
+	}
+}
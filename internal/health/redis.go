@@ -0,0 +1,23 @@
+package health
+
+import (
+	"context"
+	"main/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisProbe pings Redis on a connection of its own, so probes don't compete
+// with the cache and rate limiter for their tightly sized pools
+func RedisProbe(cfg config.RedisConfig) func(ctx context.Context) error {
+	client := redis.NewClient(&redis.Options{
+		Addr:       cfg.Host + ":" + cfg.Port,
+		Password:   cfg.Password,
+		DB:         cfg.DB,
+		PoolSize:   1,
+		MaxRetries: -1,
+	})
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
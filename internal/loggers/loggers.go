@@ -1,14 +1,26 @@
 package loggers
 
 import (
+	"fmt"
+	"main/internal/config"
+	"main/internal/redact"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func NewLogger() (*zap.Logger, error) {
-	level := getLogLevel()
+// NewLogger builds the logger described by cfg: JSON or console output at
+// cfg.Level (info when empty), written to stdout or to a rotated cfg.File.
+// Entries are also published to stream when it is not nil. The returned level
+// changes the logger's level at runtime.
+func NewLogger(cfg config.LoggingConfig, stream *Broadcaster) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+	atomicLevel := zap.NewAtomicLevelAt(level)
 
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "timestamp",
@@ -25,35 +37,52 @@ func NewLogger() (*zap.Logger, error) {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	config := zap.Config{
-		Level:             zap.NewAtomicLevelAt(level),
-		Development:       false,
-		DisableCaller:     false,
-		DisableStacktrace: false,
-		Sampling:          nil,
-		Encoding:          "json",
-		EncoderConfig:     encoderConfig,
-		OutputPaths:       []string{"stdout"},
-		ErrorOutputPaths:  []string{"stderr"},
+	var encoder zapcore.Encoder
+	if cfg.JSONFormat {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	} else {
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
+
+	output := zapcore.Lock(os.Stdout)
+	if cfg.File != "" {
+		output = zapcore.AddSync(&lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.FileMaxSizeMB,
+			MaxAge:     cfg.FileMaxAgeDays,
+			MaxBackups: cfg.FileMaxBackups,
+		})
+	}
+
+	core := zapcore.NewCore(encoder, output, atomicLevel)
+	if stream != nil {
+		core = zapcore.NewTee(core, newStreamCore(stream, atomicLevel))
 	}
 
-	return config.Build(zap.AddCallerSkip(1))
+	// Every field is redacted here, so no log site or stream client can leak credentials
+	core = redact.NewCore(core, cfg.BodyLogRedactFields)
+	return zap.New(core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	), atomicLevel, nil
 }
 
-func getLogLevel() zapcore.Level {
-	logLevel := os.Getenv("LOG_LEVEL")
+// ParseLevel returns the zap level named by LOG_LEVEL; empty means info
+func ParseLevel(logLevel string) (zapcore.Level, error) {
 	switch logLevel {
 	case "debug":
-		return zapcore.DebugLevel
-	case "info":
-		return zapcore.InfoLevel
+		return zapcore.DebugLevel, nil
+	case "", "info":
+		return zapcore.InfoLevel, nil
 	case "warn":
-		return zapcore.WarnLevel
+		return zapcore.WarnLevel, nil
 	case "error":
-		return zapcore.ErrorLevel
+		return zapcore.ErrorLevel, nil
 	case "fatal":
-		return zapcore.FatalLevel
+		return zapcore.FatalLevel, nil
 	default:
-		return zapcore.InfoLevel
+		return zapcore.InfoLevel, fmt.Errorf("unknown log level %q", logLevel)
 	}
 }
@@ -0,0 +1,56 @@
+package loggers
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelReverter restores a log level some time after a temporary change,
+// so debug logging switched on in production doesn't stay on
+type LevelReverter struct {
+	level zap.AtomicLevel
+	log   *zap.Logger
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	previous zapcore.Level
+}
+
+func NewLevelReverter(level zap.AtomicLevel, log *zap.Logger) *LevelReverter {
+	return &LevelReverter{level: level, log: log}
+}
+
+// Changed records that the level was changed from previous. With d > 0 the level
+// returns after d to where it was before the first pending change; otherwise
+// the change is permanent and any pending revert is cancelled.
+func (r *LevelReverter) Changed(previous zapcore.Level, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+		previous = r.previous
+	}
+	if d <= 0 {
+		return
+	}
+
+	r.previous = previous
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		// A later change may have replaced this revert
+		if r.timer != timer {
+			return
+		}
+		r.timer = nil
+		r.level.SetLevel(r.previous)
+		r.log.Info("Log level reverted", zap.Stringer("level", r.previous))
+	})
+	r.timer = timer
+}
@@ -0,0 +1,153 @@
+package loggers
+
+import (
+	"main/internal/metrics"
+	"main/internal/models"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Broadcaster fans log entries out to live subscribers, such as the clients
+// of /admin/logs/stream. Publishing never blocks: a subscriber whose buffer is
+// full is dropped rather than slowing down the logger.
+type Broadcaster struct {
+	buffer int
+
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	// count lets the logger skip encoding entries nobody is reading
+	count atomic.Int32
+}
+
+// Subscription receives published entries until it is closed or dropped
+type Subscription struct {
+	entries chan models.LogEntry
+	dropped atomic.Bool
+}
+
+// NewBroadcaster buffers up to buffer entries per subscriber
+func NewBroadcaster(buffer int) *Broadcaster {
+	return &Broadcaster{
+		buffer:      buffer,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe starts receiving entries; call Unsubscribe when done
+func (b *Broadcaster) Subscribe() *Subscription {
+	s := &Subscription{entries: make(chan models.LogEntry, b.buffer)}
+
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.count.Add(1)
+	b.mu.Unlock()
+	return s
+}
+
+// Unsubscribe stops s receiving entries and closes its channel
+func (b *Broadcaster) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(s)
+}
+
+// remove must be called with mu held
+func (b *Broadcaster) remove(s *Subscription) {
+	if _, ok := b.subscribers[s]; !ok {
+		return
+	}
+	delete(b.subscribers, s)
+	b.count.Add(-1)
+	close(s.entries)
+}
+
+// Publish sends entry to every subscriber, dropping those that are full
+func (b *Broadcaster) Publish(entry models.LogEntry) {
+	var slow []*Subscription
+
+	b.mu.RLock()
+	for s := range b.subscribers {
+		select {
+		case s.entries <- entry:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range slow {
+		if _, ok := b.subscribers[s]; ok {
+			s.dropped.Store(true)
+			b.remove(s)
+			metrics.LogStreamClientsDropped.Inc()
+		}
+	}
+}
+
+// Entries is closed when the subscription ends
+func (s *Subscription) Entries() <-chan models.LogEntry {
+	return s.entries
+}
+
+// Dropped reports whether the subscription ended because it fell behind
+func (s *Subscription) Dropped() bool {
+	return s.dropped.Load()
+}
+
+// streamCore publishes every entry it writes to a Broadcaster as a models.LogEntry
+type streamCore struct {
+	zapcore.LevelEnabler
+	stream *Broadcaster
+	fields []zapcore.Field
+}
+
+func newStreamCore(stream *Broadcaster, level zapcore.LevelEnabler) zapcore.Core {
+	return &streamCore{LevelEnabler: level, stream: stream}
+}
+
+func (c *streamCore) With(fields []zapcore.Field) zapcore.Core {
+	return &streamCore{
+		LevelEnabler: c.LevelEnabler,
+		stream:       c.stream,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *streamCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.stream.count.Load() > 0 && c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *streamCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(enc)
+	}
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	if entry.Caller.Defined {
+		enc.Fields["caller"] = entry.Caller.TrimmedPath()
+	}
+
+	c.stream.Publish(models.LogEntry{
+		Timestamp: entry.Time.UTC(),
+		Level:     entry.Level.String(),
+		Message:   entry.Message,
+		Fields:    enc.Fields,
+	})
+	return nil
+}
+
+func (c *streamCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"main/internal/version"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Registry holds the gateway's collectors and is served at /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RequestsTotal counts handled requests; service is empty for requests
+	// answered by the gateway itself
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_requests_total",
+		Help: "Requests handled by the gateway",
+	}, []string{"method", "route", "status", "service"})
+
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_request_duration_seconds",
+		Help:    "Request latency including upstream time",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "service"})
+
+	RequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_requests_in_flight",
+		Help: "Requests currently being handled",
+	})
+
+	UpstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_errors_total",
+		Help: "Failed upstream calls by error code",
+	}, []string{"service", "code"})
+
+	// CircuitBreakerState is 0 when closed, 1 when half-open and 2 when open
+	CircuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_circuit_breaker_state",
+		Help: "Circuit breaker state per service (0 closed, 1 half-open, 2 open)",
+	}, []string{"service"})
+
+	// CircuitBreakerProbes counts requests let through a half-open circuit
+	CircuitBreakerProbes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_circuit_breaker_probes_total",
+		Help: "Half-open circuit breaker probes by result (success, failure)",
+	}, []string{"service", "result"})
+
+	OutlierEjections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_outlier_ejections_total",
+		Help: "Instances ejected from load balancing by outlier detection",
+	}, []string{"service"})
+
+	OutlierEjected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_outlier_ejected_instances",
+		Help: "Instances currently ejected by outlier detection",
+	}, []string{"service"})
+
+	// MirrorRequests counts mirrored requests by result: sent, failed, or
+	// dropped because too many were in flight
+	MirrorRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_mirror_requests_total",
+		Help: "Requests mirrored to shadow backends by result",
+	}, []string{"service", "result"})
+
+	// CanaryRequests counts requests to services with a canary by the track
+	// that served them: canary or stable
+	CanaryRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_canary_requests_total",
+		Help: "Requests to services with a canary by track",
+	}, []string{"service", "track"})
+
+	// UpstreamQueued is the number of requests waiting for a free slot under a
+	// service's concurrency limit
+	UpstreamQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_upstream_queued_requests",
+		Help: "Requests queued for a slot under the service's concurrency limit",
+	}, []string{"service"})
+
+	// UpstreamRetries counts the retries made under each service's retry
+	// policy, by the failure retried: connection_error or the status
+	UpstreamRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_retries_total",
+		Help: "Upstream calls retried by the failure retried",
+	}, []string{"service", "reason"})
+
+	RetryBudgetExhausted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_retry_budget_exhausted_total",
+		Help: "Retries skipped because the service's retry budget was spent",
+	}, []string{"service"})
+
+	BytesIn = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_request_bytes_total",
+		Help: "Request body bytes forwarded upstream",
+	}, []string{"service"})
+
+	BytesOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_response_bytes_total",
+		Help: "Response body bytes received from upstream",
+	}, []string{"service"})
+
+	// ResponsesTooLarge counts upstream responses over their size limit, by
+	// whether they were rejected on their Content-Length ("rejected") or cut
+	// off once read past it ("aborted")
+	ResponsesTooLarge = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_responses_too_large_total",
+		Help: "Upstream responses over their size limit",
+	}, []string{"service", "outcome"})
+
+	// AuditEventsDropped counts audit events lost because the queue was full
+	// ("queue_full") or the database rejected the batch ("write_failed")
+	AuditEventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_audit_events_dropped_total",
+		Help: "Audit events that were not persisted",
+	}, []string{"reason"})
+
+	// BuildInfo is always 1; its labels identify the running build
+	BuildInfo = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_build_info",
+		Help: "Build of the running gateway",
+		ConstLabels: prometheus.Labels{
+			"version":    version.Version,
+			"commit":     version.Commit,
+			"build_time": version.BuildTime,
+			"goversion":  version.Get().GoVersion,
+		},
+	})
+
+	// LogStreamClientsDropped counts /admin/logs/stream clients disconnected
+	// for falling behind
+	LogStreamClientsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gateway_log_stream_clients_dropped_total",
+		Help: "Log stream clients disconnected for reading too slowly",
+	})
+)
+
+func init() {
+	BuildInfo.Set(1)
+	Registry.MustRegister(
+		RequestsTotal,
+		RequestDuration,
+		RequestsInFlight,
+		UpstreamErrors,
+		CircuitBreakerState,
+		CircuitBreakerProbes,
+		OutlierEjections,
+		OutlierEjected,
+		MirrorRequests,
+		CanaryRequests,
+		UpstreamQueued,
+		UpstreamRetries,
+		RetryBudgetExhausted,
+		BytesIn,
+		BytesOut,
+		ResponsesTooLarge,
+		AuditEventsDropped,
+		LogStreamClientsDropped,
+		BuildInfo,
+		Proxy,
+		// Goroutines, heap and GC pause distributions, scheduler latency
+		collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+			collectors.MetricsGC, collectors.MetricsMemory, collectors.MetricsScheduler,
+		)),
+		// CPU, resident memory and open file descriptors
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Summary aggregates the request collectors for the JSON monitoring endpoint
+type Summary struct {
+	RequestsTotal  int64
+	RequestsFailed int64
+	AvgLatencyMs   float64
+	UpstreamErrors int64
+}
+
+// Summarize reads the current values of the request collectors. Failed requests
+// are those answered with a 5xx status.
+func Summarize() (Summary, error) {
+	families, err := Registry.Gather()
+	if err != nil {
+		return Summary{}, err
+	}
+
+	var summary Summary
+	var latencySum float64
+	var latencyCount uint64
+	for _, family := range families {
+		switch family.GetName() {
+		case "gateway_requests_total":
+			for _, m := range family.GetMetric() {
+				value := int64(m.GetCounter().GetValue())
+				summary.RequestsTotal += value
+				if strings.HasPrefix(label(m, "status"), "5") {
+					summary.RequestsFailed += value
+				}
+			}
+		case "gateway_request_duration_seconds":
+			for _, m := range family.GetMetric() {
+				latencySum += m.GetHistogram().GetSampleSum()
+				latencyCount += m.GetHistogram().GetSampleCount()
+			}
+		case "gateway_upstream_errors_total":
+			for _, m := range family.GetMetric() {
+				summary.UpstreamErrors += int64(m.GetCounter().GetValue())
+			}
+		}
+	}
+	if latencyCount > 0 {
+		summary.AvgLatencyMs = latencySum / float64(latencyCount) * 1000
+	}
+	return summary, nil
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, pair := range m.GetLabel() {
+		if pair.GetName() == name {
+			return pair.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import "testing"
+
+func TestRuntimeMetricsRegistered(t *testing.T) {
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	for _, name := range []string{"go_gc_pauses_seconds", "go_sched_latencies_seconds", "go_memory_classes_heap_objects_bytes", "process_open_fds"} {
+		if !names[name] {
+			t.Errorf("%s is not exported", name)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"main/internal/models"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Latency buckets grow by latencyGrowth from latencyMin, so quantiles are
+// estimated to within about 5% without keeping samples
+const (
+	latencyMin     = 100 * time.Microsecond
+	latencyGrowth  = 1.1
+	latencyBuckets = 160 // up to about 3.5 minutes
+)
+
+var proxyQuantiles = []float64{0.5, 0.95, 0.99}
+
+// proxyStats aggregates forwarded requests with atomic counters only
+type proxyStats struct {
+	success    atomic.Int64
+	failure    atomic.Int64
+	latencySum atomic.Int64 // nanoseconds
+	buckets    [latencyBuckets]atomic.Int64
+}
+
+func (s *proxyStats) record(d time.Duration, failed bool) {
+	if failed {
+		s.failure.Add(1)
+	} else {
+		s.success.Add(1)
+	}
+	s.latencySum.Add(int64(d))
+	s.buckets[latencyBucket(d)].Add(1)
+}
+
+func latencyBucket(d time.Duration) int {
+	if d <= latencyMin {
+		return 0
+	}
+	bucket := int(math.Ceil(math.Log(float64(d)/float64(latencyMin)) / math.Log(latencyGrowth)))
+	return min(bucket, latencyBuckets-1)
+}
+
+// bucketLatency is the geometric middle of a bucket's latency range
+func bucketLatency(bucket int) time.Duration {
+	if bucket == 0 {
+		return latencyMin
+	}
+	return time.Duration(float64(latencyMin) * math.Pow(latencyGrowth, float64(bucket)-0.5))
+}
+
+// quantiles estimates the given quantiles from the bucket counts
+func (s *proxyStats) quantiles(qs []float64) []time.Duration {
+	var counts [latencyBuckets]int64
+	var total int64
+	for i := range s.buckets {
+		counts[i] = s.buckets[i].Load()
+		total += counts[i]
+	}
+
+	out := make([]time.Duration, len(qs))
+	if total == 0 {
+		return out
+	}
+	for i, q := range qs {
+		rank := int64(math.Ceil(q * float64(total)))
+		var seen int64
+		for bucket, count := range counts {
+			seen += count
+			if seen >= rank {
+				out[i] = bucketLatency(bucket)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (s *proxyStats) snapshot() *models.ProxyMetrics {
+	success, failure := s.success.Load(), s.failure.Load()
+	m := &models.ProxyMetrics{
+		TotalRequests:   success + failure,
+		SuccessRequests: success,
+		FailedRequests:  failure,
+		LatencySum:      time.Duration(s.latencySum.Load()),
+	}
+	if m.TotalRequests > 0 {
+		m.AverageLatency = m.LatencySum / time.Duration(m.TotalRequests)
+	}
+	q := s.quantiles(proxyQuantiles)
+	m.LatencyP50, m.LatencyP95, m.LatencyP99 = q[0], q[1], q[2]
+	return m
+}
+
+// ProxyCollector counts forwarded requests and their latency per upstream
+// service and overall. Counters live until the process exits. It is also a
+// Prometheus collector, so /metrics reports the same numbers.
+type ProxyCollector struct {
+	overall  proxyStats
+	services sync.Map // service name -> *proxyStats
+
+	requestsDesc *prometheus.Desc
+	latencyDesc  *prometheus.Desc
+}
+
+// Proxy is the collector the gateway records forwarded requests in
+var Proxy = NewProxyCollector()
+
+func NewProxyCollector() *ProxyCollector {
+	return &ProxyCollector{
+		requestsDesc: prometheus.NewDesc("gateway_proxy_requests_total",
+			"Requests forwarded upstream by outcome", []string{"service", "outcome"}, nil),
+		latencyDesc: prometheus.NewDesc("gateway_proxy_latency_seconds",
+			"Upstream latency of forwarded requests", []string{"service"}, nil),
+	}
+}
+
+// Record adds a forwarded request; failed is true for transport errors and 5xx responses
+func (p *ProxyCollector) Record(service string, d time.Duration, failed bool) {
+	stats, ok := p.services.Load(service)
+	if !ok {
+		stats, _ = p.services.LoadOrStore(service, &proxyStats{})
+	}
+	stats.(*proxyStats).record(d, failed)
+	p.overall.record(d, failed)
+}
+
+// Snapshot returns the overall numbers with a breakdown per service
+func (p *ProxyCollector) Snapshot() models.ProxyMetrics {
+	m := p.overall.snapshot()
+	m.Services = make(map[string]*models.ProxyMetrics)
+	p.services.Range(func(name, stats any) bool {
+		m.Services[name.(string)] = stats.(*proxyStats).snapshot()
+		return true
+	})
+	m.Timestamp = time.Now()
+	return *m
+}
+
+func (p *ProxyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.requestsDesc
+	ch <- p.latencyDesc
+}
+
+func (p *ProxyCollector) Collect(ch chan<- prometheus.Metric) {
+	var names []string
+	p.services.Range(func(name, _ any) bool {
+		names = append(names, name.(string))
+		return true
+	})
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats, _ := p.services.Load(name)
+		s := stats.(*proxyStats)
+		success, failure := s.success.Load(), s.failure.Load()
+		ch <- prometheus.MustNewConstMetric(p.requestsDesc, prometheus.CounterValue, float64(success), name, "success")
+		ch <- prometheus.MustNewConstMetric(p.requestsDesc, prometheus.CounterValue, float64(failure), name, "failure")
+
+		quantiles := make(map[float64]float64, len(proxyQuantiles))
+		for i, d := range s.quantiles(proxyQuantiles) {
+			quantiles[proxyQuantiles[i]] = d.Seconds()
+		}
+		ch <- prometheus.MustNewConstSummary(p.latencyDesc, uint64(success+failure),
+			time.Duration(s.latencySum.Load()).Seconds(), quantiles, name)
+	}
+}
@@ -2,10 +2,82 @@ package models
 
 import "time"
 
+// ErrorCode is a machine-readable error identifier clients can switch on
+type ErrorCode string
+
+const (
+	ErrCodeBadRequest             ErrorCode = "BAD_REQUEST"
+	ErrCodeUnauthorized           ErrorCode = "UNAUTHORIZED"
+	ErrCodeAuthMissingToken       ErrorCode = "AUTH_MISSING_TOKEN"
+	ErrCodeAuthInvalidToken       ErrorCode = "AUTH_INVALID_TOKEN"
+	ErrCodeAuthInvalidAPIKey      ErrorCode = "AUTH_INVALID_API_KEY"
+	ErrCodeForbidden              ErrorCode = "FORBIDDEN"
+	ErrCodeNotFound               ErrorCode = "NOT_FOUND"
+	ErrCodeMethodNotAllowed       ErrorCode = "METHOD_NOT_ALLOWED"
+	ErrCodeBodyTooLarge           ErrorCode = "BODY_TOO_LARGE"
+	ErrCodeHeadersTooLarge        ErrorCode = "HEADERS_TOO_LARGE"
+	ErrCodeRateLimited            ErrorCode = "RATE_LIMITED"
+	ErrCodeClientClosedRequest    ErrorCode = "CLIENT_CLOSED_REQUEST"
+	ErrCodeInternal               ErrorCode = "INTERNAL_ERROR"
+	ErrCodeUpstreamUnavailable    ErrorCode = "UPSTREAM_UNAVAILABLE"
+	ErrCodeUpstreamBadResponse    ErrorCode = "UPSTREAM_BAD_RESPONSE"
+	ErrCodeResponseTooLarge       ErrorCode = "UPSTREAM_RESPONSE_TOO_LARGE"
+	ErrCodeUpstreamRefused        ErrorCode = "UPSTREAM_CONNECTION_REFUSED"
+	ErrCodeUpstreamDNS            ErrorCode = "UPSTREAM_DNS_FAILURE"
+	ErrCodeUpstreamTLS            ErrorCode = "UPSTREAM_TLS_FAILURE"
+	ErrCodeUpstreamTimeout        ErrorCode = "UPSTREAM_TIMEOUT"
+	ErrCodeTransformFailed        ErrorCode = "TRANSFORM_FAILED"
+	ErrCodeDeadlineExceeded       ErrorCode = "DEADLINE_EXCEEDED"
+	ErrCodeGatewayOverloaded      ErrorCode = "GATEWAY_OVERLOADED"
+	ErrCodeServiceBusy            ErrorCode = "SERVICE_BUSY"
+	ErrCodeMaintenance            ErrorCode = "MAINTENANCE"
+	ErrCodeRateLimiterUnavailable ErrorCode = "RATE_LIMITER_UNAVAILABLE"
+	ErrCodeCacheUnavailable       ErrorCode = "CACHE_UNAVAILABLE"
+	ErrCodeServiceUnavailable     ErrorCode = "SERVICE_UNAVAILABLE"
+	ErrCodeCircuitOpen            ErrorCode = "CIRCUIT_OPEN"
+	ErrCodeGatewayTimeout         ErrorCode = "GATEWAY_TIMEOUT"
+	ErrCodeInvalidConfig          ErrorCode = "INVALID_CONFIG"
+)
+
+// ErrorCodeForStatus returns the generic code for an HTTP status
+func ErrorCodeForStatus(status int) ErrorCode {
+	switch status {
+	case 400:
+		return ErrCodeBadRequest
+	case 401:
+		return ErrCodeUnauthorized
+	case 403:
+		return ErrCodeForbidden
+	case 404:
+		return ErrCodeNotFound
+	case 405:
+		return ErrCodeMethodNotAllowed
+	case 413:
+		return ErrCodeBodyTooLarge
+	case 429:
+		return ErrCodeRateLimited
+	case 431:
+		return ErrCodeHeadersTooLarge
+	case 502:
+		return ErrCodeUpstreamBadResponse
+	case 503:
+		return ErrCodeServiceUnavailable
+	case 504:
+		return ErrCodeGatewayTimeout
+	}
+	if status >= 400 && status < 500 {
+		return ErrCodeBadRequest
+	}
+	return ErrCodeInternal
+}
+
+// ErrorResponse is the body of every error the gateway itself returns
 type ErrorResponse struct {
-	Error     string `json:"error"`
-	Status    int    `json:"status"`
-	RequestID string `json:"request_id,omitempty"`
+	Error     string                 `json:"error"`
+	Code      ErrorCode              `json:"code"`
+	Status    int                    `json:"status"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
 }
 
 type SuccessResponse struct {
@@ -16,15 +88,143 @@ type SuccessResponse struct {
 }
 
 type HealthCheckResponse struct {
-	Status    string                 `json:"status"`
-	Timestamp time.Time              `json:"timestamp"`
-	Services  map[string]interface{} `json:"services,omitempty"`
+	Status       string                      `json:"status"`
+	Timestamp    time.Time                   `json:"timestamp"`
+	Services     map[string]interface{}      `json:"services,omitempty"`
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// VersionInfo identifies the running build of the gateway
+type VersionInfo struct {
+	Version       string  `json:"version"`
+	Commit        string  `json:"commit"`
+	BuildTime     string  `json:"build_time"`
+	GoVersion     string  `json:"go_version"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// DependencyStatus is the latest background probe result for a dependency
+type DependencyStatus struct {
+	Status    string    `json:"status"` // up, down or pending
+	Critical  bool      `json:"critical"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
 }
 
 type ServiceInfo struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
-	URL    string `json:"url"`
+	Name             string    `json:"name"`
+	Status           string    `json:"status"` // circuit breaker state
+	URL              string    `json:"url"`
+	Healthy          bool      `json:"healthy"`
+	Instances        int       `json:"instances"`
+	HealthyInstances int       `json:"healthy_instances"`
+	LastError        string    `json:"last_error,omitempty"`
+	LastErrorAt      time.Time `json:"last_error_at,omitzero"`
+	InFlight         int64     `json:"in_flight"`
+	Queued           int64     `json:"queued"`
+	// Requests, SuccessRate and the latencies cover the last minute; the
+	// success rate is 1 when there were no requests
+	Requests     int64   `json:"requests"`
+	SuccessRate  float64 `json:"success_rate"`
+	LatencyP50Ms float64 `json:"latency_p50_ms"`
+	LatencyP95Ms float64 `json:"latency_p95_ms"`
+	LatencyP99Ms float64 `json:"latency_p99_ms"`
+}
+
+// DependenciesResponse is the body of /monitor/dependencies
+type DependenciesResponse struct {
+	Services []ServiceInfo `json:"services"`
+}
+
+// SlowRequest is a request that took longer than the slow request threshold,
+// with its time broken down by phase
+type SlowRequest struct {
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Query       string    `json:"query,omitempty"`
+	Status      int       `json:"status"`
+	Upstream    string    `json:"upstream,omitempty"`
+	RequestID   string    `json:"request_id"`
+	DurationMs  float64   `json:"duration_ms"`
+	QueueMs     float64   `json:"queue_ms"`
+	AuthMs      float64   `json:"auth_ms"`
+	UpstreamMs  float64   `json:"upstream_ms"`
+	SerializeMs float64   `json:"serialize_ms"`
+	Time        time.Time `json:"time"`
+}
+
+// TokenIntrospection describes a JWT and, when it is invalid, why. Header and
+// Claims are decoded without verification, so they are shown for invalid tokens too.
+type TokenIntrospection struct {
+	Valid            bool           `json:"valid"`
+	Reason           string         `json:"reason,omitempty"` // expired, not_yet_valid, bad_signature, wrong_issuer, wrong_audience or malformed
+	Error            string         `json:"error,omitempty"`
+	Header           map[string]any `json:"header,omitempty"`
+	Claims           map[string]any `json:"claims,omitempty"`
+	ExpiresAt        time.Time      `json:"expires_at,omitzero"`
+	ExpiresInSeconds int64          `json:"expires_in_seconds,omitempty"` // negative once expired
+}
+
+// RouteInfo describes a service in the gateway's effective routing table
+type RouteInfo struct {
+	Service            string              `json:"service"`
+	Type               string              `json:"type,omitempty"`
+	GRPCServices       []string            `json:"grpc_services,omitempty"`
+	PathPrefix         string              `json:"path_prefix,omitempty"`
+	Targets            []string            `json:"targets"`
+	TimeoutSeconds     float64             `json:"timeout_seconds"`
+	MaxRetry           int                 `json:"max_retry"`
+	Protocol           string              `json:"protocol"`
+	MaxConcurrent      int                 `json:"max_concurrent"`
+	QueueTimeoutMs     int64               `json:"queue_timeout_ms"`
+	StripPrefix        string              `json:"strip_prefix,omitempty"`
+	RewritePattern     string              `json:"rewrite_pattern,omitempty"`
+	RewriteReplacement string              `json:"rewrite_replacement,omitempty"`
+	QueryAllow         []string            `json:"query_allow,omitempty"`
+	QueryDeny          []string            `json:"query_deny,omitempty"`
+	AllowedMethods     []string            `json:"allowed_methods,omitempty"`
+	Affinity           string              `json:"affinity,omitempty"`
+	CircuitBreaker     *CircuitBreakerInfo `json:"circuit_breaker,omitempty"`
+}
+
+// CircuitBreakerInfo reports a service's breaker state and trip policy
+type CircuitBreakerInfo struct {
+	State           string  `json:"state"`
+	MaxRequests     uint32  `json:"max_requests"`
+	IntervalSeconds float64 `json:"interval_seconds"`
+	TimeoutSeconds  float64 `json:"timeout_seconds"`
+	MinRequests     uint32  `json:"min_requests"`
+	FailureRatio    float64 `json:"failure_ratio"`
+	// Override is the manual action holding the circuit, "open" or "close"
+	Override string `json:"override,omitempty"`
+}
+
+// CacheStats summarises response cache effectiveness and size
+type CacheStats struct {
+	Backend   string  `json:"backend"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Stale     int64   `json:"stale"`
+	Bypass    int64   `json:"bypass"`
+	Coalesced int64   `json:"coalesced"`
+	Negative  int64   `json:"negative_hits"`
+	HitRatio  float64 `json:"hit_ratio"`
+	Entries   int64   `json:"entries"`
+	Bytes     int64   `json:"bytes"`
+	Evictions int64   `json:"evictions"`
+}
+
+type CacheKeyInfo struct {
+	Key        string `json:"key"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+	SizeBytes  int    `json:"size_bytes"`
+}
+
+// CacheKeyList is one page of cached keys; NextCursor is empty on the last page
+type CacheKeyList struct {
+	Keys       []CacheKeyInfo `json:"keys"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
 type RateLimitInfo struct {
@@ -38,7 +238,13 @@ type ProxyMetrics struct {
 	SuccessRequests int64         `json:"success_requests"`
 	FailedRequests  int64         `json:"failed_requests"`
 	AverageLatency  time.Duration `json:"average_latency"`
-	Timestamp       time.Time     `json:"timestamp"`
+	LatencySum      time.Duration `json:"latency_sum"`
+	LatencyP50      time.Duration `json:"latency_p50"`
+	LatencyP95      time.Duration `json:"latency_p95"`
+	LatencyP99      time.Duration `json:"latency_p99"`
+	// Services breaks the totals down per upstream service
+	Services  map[string]*ProxyMetrics `json:"services,omitempty"`
+	Timestamp time.Time                `json:"timestamp,omitzero"`
 }
 
 type LogEntry struct {
@@ -0,0 +1,111 @@
+package redact
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Secrets embedded in free text, such as the URL in a *url.Error message or
+// the header values in a cache key
+var (
+	textParam  = regexp.MustCompile(`(?i)([?&][^=&\s"#]*(?:token|key)=)[^&\s"#|]*`)
+	textBearer = regexp.MustCompile(`(?i)(bearer\s+)([A-Za-z0-9\-._~+/]+=*)`)
+)
+
+// Text masks token and key query parameters and fingerprints bearer
+// credentials found anywhere in s
+func Text(s string) string {
+	s = textParam.ReplaceAllString(s, "${1}"+redactedValue)
+	return textBearer.ReplaceAllStringFunc(s, func(match string) string {
+		parts := textBearer.FindStringSubmatch(match)
+		return parts[1] + Fingerprint(parts[2])
+	})
+}
+
+type core struct {
+	zapcore.Core
+	bodyFields map[string]bool
+}
+
+// NewCore wraps inner so every field written through it is redacted:
+// credentials are fingerprinted, token and key query parameters masked, and
+// bodyFields masked in JSON logged under a *body key. Other strings and errors
+// are scanned for embedded tokens. Log sites need no redaction of their own.
+func NewCore(inner zapcore.Core, bodyFields []string) zapcore.Core {
+	return &core{Core: inner, bodyFields: FieldSet(bodyFields)}
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(c.redact(fields)), bodyFields: c.bodyFields}
+}
+
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.redact(fields))
+}
+
+// redact returns fields with sensitive values masked, copying only when needed
+func (c *core) redact(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, field := range fields {
+		redacted, changed := c.field(field)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		out[i] = redacted
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+func (c *core) field(field zapcore.Field) (zapcore.Field, bool) {
+	key := normalizeKey(field.Key)
+
+	switch {
+	case IsSensitive(key):
+		if field.Type == zapcore.StringType {
+			return zap.String(field.Key, Fingerprint(field.String)), true
+		}
+		return zap.String(field.Key, redactedValue), true
+	case field.Type == zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok {
+			if msg := err.Error(); Text(msg) != msg {
+				return zap.String(field.Key, Text(msg)), true
+			}
+		}
+	case field.Type == zapcore.ReflectType:
+		if h, ok := field.Interface.(http.Header); ok {
+			return zap.Any(field.Key, Headers(h)), true
+		}
+	case field.Type != zapcore.StringType:
+	case key == "query" || key == "raw_query":
+		if masked := Text(Query(field.String)); masked != field.String {
+			return zap.String(field.Key, masked), true
+		}
+	case strings.HasSuffix(key, "body") && len(c.bodyFields) > 0:
+		if masked, ok := JSON([]byte(field.String), c.bodyFields); ok && masked != field.String {
+			return zap.String(field.Key, masked), true
+		}
+	default:
+		if masked := Text(field.String); masked != field.String {
+			return zap.String(field.Key, masked), true
+		}
+	}
+	return field, false
+}
@@ -0,0 +1,178 @@
+// Package redact masks credentials, tokens and sensitive body fields before
+// they reach the logs. NewCore applies it to every field a logger writes.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeys are header names and log field keys whose values are secrets,
+// normalized by normalizeKey
+var sensitiveKeys = map[string]bool{
+	"authorization":       true,
+	"proxy_authorization": true,
+	"cookie":              true,
+	"set_cookie":          true,
+	"x_api_key":           true,
+	"api_key":             true,
+	"token":               true,
+	"token_preview":       true,
+	"access_token":        true,
+	"refresh_token":       true,
+	"password":            true,
+	"secret":              true,
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "-", "_")
+}
+
+// IsSensitive reports whether a header or field named key holds a secret
+func IsSensitive(key string) bool {
+	return sensitiveKeys[normalizeKey(key)]
+}
+
+// fingerprintKey keys Fingerprint. It is random per process, so a fingerprint
+// can't be checked against guessed passwords offline.
+var fingerprintKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// Fingerprint identifies a secret without revealing it, so log lines about the
+// same credential can still be correlated. Fingerprints are an HMAC under a
+// per-process key: they match within one process only, not across restarts
+// or instances.
+func Fingerprint(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, fingerprintKey)
+	mac.Write([]byte(secret))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// Header returns value, or its fingerprint when the header name is sensitive
+func Header(name, value string) string {
+	if IsSensitive(name) {
+		return Fingerprint(value)
+	}
+	return value
+}
+
+// Headers returns a copy of h with sensitive values fingerprinted
+func Headers(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		for _, value := range values {
+			out[name] = append(out[name], Header(name, value))
+		}
+	}
+	return out
+}
+
+// sensitiveParam reports whether a query parameter carries a token or key
+func sensitiveParam(name string) bool {
+	name = normalizeKey(name)
+	return name == "token" || name == "key" ||
+		strings.HasSuffix(name, "_token") || strings.HasSuffix(name, "_key") || IsSensitive(name)
+}
+
+// Query masks the values of token and key parameters in a raw query string,
+// leaving the rest untouched
+func Query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		name, _, hasValue := strings.Cut(part, "=")
+		decoded, err := url.QueryUnescape(name)
+		if err != nil {
+			decoded = name
+		}
+		if hasValue && sensitiveParam(decoded) {
+			parts[i] = name + "=" + redactedValue
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// FieldSet builds the set of body fields to mask from their names
+func FieldSet(names []string) map[string]bool {
+	fields := make(map[string]bool, len(names))
+	for _, name := range names {
+		fields[name] = true
+	}
+	return fields
+}
+
+// Body renders body for logging. JSON bodies under maxBytes have the given
+// fields (by key name or dotted key path) masked; other bodies are omitted.
+func Body(body []byte, contentType string, fields map[string]bool, maxBytes int) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if len(body) > maxBytes {
+		return "[omitted: body exceeds size cap]"
+	}
+	if !strings.Contains(contentType, "json") {
+		return "[omitted: non-JSON body]"
+	}
+
+	out, ok := JSON(body, fields)
+	if !ok {
+		return "[omitted: invalid JSON body]"
+	}
+	return out
+}
+
+// JSON masks the given fields of a JSON document; ok is false when it doesn't parse
+func JSON(body []byte, fields map[string]bool) (string, bool) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", false
+	}
+
+	redactValue(value, "", fields)
+
+	out, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+// redactValue masks matching keys of a decoded JSON value in place
+func redactValue(value interface{}, path string, fields map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			if fields[key] || fields[childPath] {
+				v[key] = redactedValue
+				continue
+			}
+			redactValue(child, childPath, fields)
+		}
+	case []interface{}:
+		for _, child := range v {
+			redactValue(child, path, fields)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint("") != "" {
+		t.Error("empty secret fingerprinted")
+	}
+	first := Fingerprint("hunter2")
+	if first != Fingerprint("hunter2") {
+		t.Error("same secret fingerprinted differently")
+	}
+	if first == Fingerprint("hunter3") {
+		t.Error("different secrets share a fingerprint")
+	}
+	if strings.Contains(first, "hunter2") {
+		t.Errorf("fingerprint %s reveals the secret", first)
+	}
+	// An unkeyed hash of a guessed password must not match
+	sum := sha256.Sum256([]byte("hunter2"))
+	if strings.Contains(first, hex.EncodeToString(sum[:6])) {
+		t.Errorf("fingerprint %s is an unkeyed hash", first)
+	}
+}
@@ -0,0 +1,85 @@
+package redact
+
+import (
+	"reflect"
+	"strings"
+)
+
+// secretFieldSuffixes name the struct fields Struct fingerprints
+var secretFieldSuffixes = []string{"Key", "Secret", "Password", "Token"}
+
+// IsSecretField reports whether a struct field named name holds a secret by
+// naming convention: Key, or a name ending in Key, Secret, Password or Token
+func IsSecretField(name string) bool {
+	for _, suffix := range secretFieldSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Struct returns a deep copy of v with the string fields IsSecretField picks
+// replaced by their fingerprints, at any depth. Fields are found by name, so
+// a new setting is redacted as soon as it is named like a secret.
+func Struct[T any](v T) T {
+	src := reflect.ValueOf(&v).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	redactCopy(dst, src)
+	return dst.Interface().(T)
+}
+
+// redactCopy copies src into dst, fingerprinting secret string fields
+func redactCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		// Unexported fields are copied as they are
+		dst.Set(src)
+		t := src.Type()
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Type.Kind() == reflect.String && IsSecretField(field.Name) {
+				dst.Field(i).SetString(Fingerprint(src.Field(i).String()))
+				continue
+			}
+			redactCopy(dst.Field(i), src.Field(i))
+		}
+	case reflect.Pointer:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		redactCopy(dst.Elem(), src.Elem())
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := range src.Len() {
+			redactCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Array:
+		for i := range src.Len() {
+			redactCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			redactCopy(value, iter.Value())
+			dst.SetMapIndex(iter.Key(), value)
+		}
+	default:
+		dst.Set(src)
+	}
+}
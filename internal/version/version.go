@@ -0,0 +1,51 @@
+// Package version identifies the running build of the gateway. The values are
+// set at build time:
+//
+//	go build -ldflags "-X main/internal/version.Version=1.4.0 \
+//	  -X main/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X main/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them the commit and build time come from the VCS information the Go
+// toolchain embeds, when available.
+package version
+
+import (
+	"main/internal/models"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+var started = time.Now()
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && Commit == "":
+			Commit = setting.Value
+		case setting.Key == "vcs.time" && BuildTime == "":
+			BuildTime = setting.Value
+		}
+	}
+}
+
+// Get describes the running build
+func Get() models.VersionInfo {
+	return models.VersionInfo{
+		Version:       Version,
+		Commit:        Commit,
+		BuildTime:     BuildTime,
+		GoVersion:     runtime.Version(),
+		UptimeSeconds: time.Since(started).Seconds(),
+	}
+}
@@ -1,75 +1,159 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"main/internal/api/middleware"
 	"main/internal/api/router"
+	"main/internal/audit"
 	"main/internal/auth"
 	"main/internal/config"
+	"main/internal/discovery"
+	"main/internal/gateway"
+	"main/internal/health"
 	"main/internal/loggers"
+	"main/internal/models"
+	"main/internal/tracing"
+	"main/internal/version"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
 
 func main() {
+	envFile := flag.String("env-file", "", "env file to load (default $ENV_FILE, then .env if present)")
+	validateOnly := flag.Bool("validate-config", false, "load and validate the configuration, print it with secrets masked, then exit")
+	flag.Parse()
+
 	// Load environment variables
-	godotenv.Load()
+	if err := config.LoadEnvFile(*envFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load environment: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Initialize logger
-	log, err := loggers.NewLogger()
+	// Load configuration; the logger is built from it
+	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
 		os.Exit(1)
 	}
-	defer log.Sync()
+	// Refuse to start on a configuration that can't work, listing every problem
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *validateOnly {
+		// The effective configuration on stdout, so environments can be diffed
+		var out bytes.Buffer
+		if err := json.Indent(&out, []byte(cfg.String()), "", "  "); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to render configuration: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(out.String())
+		fmt.Fprintln(os.Stderr, "Configuration is valid")
+		return
+	}
 
-	// Load configuration
-	cfg, err := config.Load()
+	// Initialize logger
+	// Log entries are also streamed to /admin/logs/stream clients
+	logStream := loggers.NewBroadcaster(cfg.Logging.StreamBuffer)
+	log, logLevel, err := loggers.NewLogger(cfg.Logging, logStream)
 	if err != nil {
-		log.Fatal("Failed to load configuration", zap.Error(err))
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
 	}
+	defer log.Sync()
 
 	log.Info("Starting Fiber Gateway",
+		zap.String("version", version.Version),
+		zap.String("commit", version.Commit),
+		zap.String("build_time", version.BuildTime),
 		zap.String("environment", cfg.Environment),
 		zap.String("port", cfg.Server.Port),
 		zap.String("nestjs_backend", "http://localhost:3000"),
 	)
+	log.Info("Configuration loaded", zap.Stringer("config", cfg))
+	if malformed := cfg.MalformedEnv(); len(malformed) > 0 {
+		log.Warn("Ignoring malformed environment variables, their settings keep their defaults",
+			zap.Errors("errors", malformed))
+	}
 
-	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		AppName: "JanusCopy Gateway",
-		Prefork: cfg.Environment == "production",
-	})
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+
+	// Create Fiber app with the server's timeouts and limits
+	app := fiber.New(router.AppConfig(cfg))
 
 	// Initialize JWT validator
 	tokenValidator := auth.NewTokenValidator(cfg, log)
 
-	// Setup all routes (core + optional features as needed)
-	router.SetupRouter(app, cfg, log, tokenValidator)
+	// Initialize upstream proxy (circuit breakers per service)
+	proxy, err := gateway.NewProxy(cfg, log)
+	if err != nil {
+		log.Fatal("Failed to initialize proxy", zap.Error(err))
+	}
+
+	// Keep service instances in step with the service registry
+	registry, err := discovery.New(cfg, log)
+	if err != nil {
+		log.Fatal("Failed to initialize service discovery", zap.Error(err))
+	}
+	discoveryCtx, stopDiscovery := context.WithCancel(context.Background())
+	go func() {
+		if err := registry.Watch(discoveryCtx, proxy.Reload); err != nil && !errors.Is(err, context.Canceled) {
+			log.Error("Service discovery stopped", zap.Error(err))
+		}
+	}()
+
+	// Audit log, written in the background; nil when disabled
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		auditLog, err = audit.New(context.Background(), cfg.Audit, cfg.GetDatabaseDSN(), log)
+		if err != nil {
+			log.Fatal("Failed to initialize audit log", zap.Error(err))
+		}
+	}
+
+	// Setup all routes (core + optional features as needed). The reloader
+	// applies configuration changes on SIGHUP and POST /admin/reload.
+	reloader := router.NewReloader(cfg, log)
+	checker := router.NewHealthChecker(cfg, log, proxy)
+	router.SetupRouter(app, reloader, log, logLevel, logStream, tokenValidator, proxy, checker, auditLog)
 
 	// Uncomment features as needed:
-	// api.setupRateLimitingRoutes(app, cfg, log)
 	// api.setupCircuitBreakerRoutes(app, cfg, log)
 	// api.setupCachingRoutes(app, cfg, log)
-	router.SetupMonitoringRoutes(app, cfg, log)
 	// api.setupMetricsRoutes(app, cfg, log)
 
 	// 404 handler for undefined routes
 	app.Use(func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "route not found",
-			"path":  c.Path(),
-		})
+		middleware.SetRouteUnmatched(c)
+		return middleware.NewError(fiber.StatusNotFound, models.ErrCodeNotFound, "route not found").
+			WithDetails(fiber.Map{"path": c.Path()})
 	})
 
+	// In compose-style deployments dependencies may still be starting: hold
+	// off listening until the critical ones answer, if configured to
+	if wait := cfg.Health.StartupWait; wait.Timeout > 0 {
+		waitForDependencies(checker, wait, log)
+	}
+	checker.Start(context.Background())
+
 	// Start server in a goroutine
-	addr := ":" + cfg.Server.Port
+	addr := net.JoinHostPort(cfg.Server.Host, cfg.Server.Port)
 	go func() {
 		log.Info("Server starting", zap.String("addr", addr))
 		if err := app.Listen(addr); err != nil && err != fiber.ErrNotFound {
@@ -77,12 +161,53 @@ func main() {
 		}
 	}()
 
+	// gRPC services get their own cleartext HTTP/2 listener. With prefork only
+	// the master binds it; every process watches discovery, probes health and
+	// writes audit events for the requests it serves.
+	var grpcServer *http.Server
+	if cfg.Server.GRPCPort != "" && !fiber.IsChild() {
+		var keys *auth.APIKeyValidator
+		if cfg.APIKeys.Enabled {
+			keys = auth.NewAPIKeyValidator(cfg, log)
+		}
+		grpcProxy, err := gateway.NewGRPCProxy(cfg, proxy, tokenValidator, keys, log)
+		if err != nil {
+			log.Fatal("Failed to initialize gRPC proxy", zap.Error(err))
+		}
+
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		grpcServer = &http.Server{
+			Addr:      net.JoinHostPort(cfg.Server.Host, cfg.Server.GRPCPort),
+			Handler:   grpcProxy,
+			Protocols: protocols,
+		}
+		go func() {
+			log.Info("gRPC server starting", zap.String("addr", grpcServer.Addr))
+			if err := grpcServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal("gRPC server error", zap.Error(err))
+			}
+		}()
+	}
+
+	// Reload the configuration on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := reloader.Reload("SIGHUP"); err != nil {
+				log.Error("Configuration reload failed, keeping the current configuration", zap.Error(err))
+			}
+		}
+	}()
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	<-quit
 	log.Info("Shutting down server...")
+	stopDiscovery()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -90,6 +215,41 @@ func main() {
 	if err := app.ShutdownWithContext(ctx); err != nil {
 		log.Fatal("Server shutdown error", zap.Error(err))
 	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctx); err != nil {
+			log.Warn("gRPC server shutdown error", zap.Error(err))
+		}
+	}
+	if err := auditLog.Close(ctx); err != nil {
+		log.Warn("Failed to flush audit log", zap.Error(err))
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Warn("Failed to flush traces", zap.Error(err))
+	}
 
 	log.Info("Server stopped gracefully")
 }
+
+// waitForDependencies probes the critical dependencies until they are all up
+// or wait.Timeout passes, then exits unless wait.ContinueDegraded
+func waitForDependencies(checker *health.Checker, wait config.StartupWaitConfig, log *zap.Logger) {
+	log.Info("Waiting for dependencies", zap.Duration("timeout", wait.Timeout))
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), wait.Timeout)
+	defer cancel()
+	err := checker.WaitReady(ctx, wait.BackoffBase, wait.BackoffCap, func(down []string, retryIn time.Duration) {
+		log.Info("Dependencies not up yet",
+			zap.Strings("down", down),
+			zap.Duration("retry_in", retryIn),
+			zap.Duration("waited", time.Since(start)),
+		)
+	})
+	switch {
+	case err == nil:
+		log.Info("Dependencies up", zap.Duration("waited", time.Since(start)))
+	case wait.ContinueDegraded:
+		log.Warn("Dependencies not up in time, starting degraded", zap.Error(err))
+	default:
+		log.Fatal("Dependencies not up in time", zap.Error(err))
+	}
+}